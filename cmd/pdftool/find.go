@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/pkg/pdfops"
+)
+
+func newFindCommand() *command {
+	pagesExpr := ""
+	format := formatText
+	findOpts := pdfops.FindOptions{}
+
+	return &command{
+		name:  "find",
+		args:  "input.pdf pattern",
+		short: "Search the text for a string or regular expression and print the position of each match",
+		setFlags: func(fs *flag.FlagSet) {
+			addPagesFlag(fs, &pagesExpr)
+			addFormatFlag(fs, &format)
+			fs.BoolVar(&findOpts.Regexp, "regex", false, "The pattern is a regular expression (RE2 syntax)")
+			fs.BoolVar(&findOpts.IgnoreCase, "i", false, "Ignore case")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 2); err != nil {
+				return err
+			}
+			if err := checkFormat(format); err != nil {
+				return err
+			}
+			pages, err := parsePages(pagesExpr)
+			if err != nil {
+				return err
+			}
+			findOpts.Pages = pages
+
+			if _, err := pdfops.CompilePattern(args[1], findOpts); err != nil {
+				return newUsageError("%v", err)
+			}
+
+			matches, err := pdfops.FindText(args[0], args[1], findOpts, g.pdfopsOptions())
+			if err != nil {
+				return err
+			}
+
+			if format != formatText {
+				return writeReport(os.Stdout, format, "find", matches)
+			}
+
+			for _, match := range matches {
+				fmt.Printf("page %d: [%.2f %.2f %.2f %.2f] %q\n", match.Page,
+					match.DisplayBBox[0], match.DisplayBBox[1], match.DisplayBBox[2], match.DisplayBBox[3], match.Text)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unidoc-examples/pkg/pdfops"
+)
+
+// Output formats of the inspection commands.
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatYAML = "yaml"
+)
+
+// addFormatFlag registers the --format option on `fs`, storing the format in `format`.
+func addFormatFlag(fs *flag.FlagSet, format *string) {
+	fs.StringVar(format, "format", formatText, "Output format: text, json or yaml")
+}
+
+// checkFormat returns a usage error if `format` is not a known output format.
+func checkFormat(format string) error {
+	switch format {
+	case formatText, formatJSON, formatYAML:
+		return nil
+	}
+	return newUsageError("invalid format %q", format)
+}
+
+// report is the envelope of the structured output of the inspection commands.
+type report struct {
+	SchemaVersion int         `json:"schema_version"`
+	Command       string      `json:"command"`
+	Results       interface{} `json:"results"`
+}
+
+// writeReport writes `results` of command `name` to `w` in structured format `format` (json or yaml).
+func writeReport(w io.Writer, format, name string, results interface{}) error {
+	data, err := json.MarshalIndent(report{
+		SchemaVersion: pdfops.SchemaVersion,
+		Command:       name,
+		Results:       results,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if format == formatYAML {
+		return writeYAML(w, data)
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// yamlEntry is a key/value pair of an object, kept in order.
+type yamlEntry struct {
+	key   string
+	value interface{}
+}
+
+// writeYAML writes JSON document `data` to `w` as YAML.  Going through JSON keeps the field names and the order of
+// the fields the same in both formats.
+func writeYAML(w io.Writer, data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	value, err := decodeOrdered(dec)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	emitYAML(&buf, value, 0, false)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// decodeOrdered decodes the next JSON value from `dec`, representing objects as []yamlEntry to keep their order.
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			entries := []yamlEntry{}
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				entries = append(entries, yamlEntry{key: keyTok.(string), value: value})
+			}
+			_, err = dec.Token() // '}'
+			return entries, err
+		case '[':
+			values := []interface{}{}
+			for dec.More() {
+				value, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, value)
+			}
+			_, err = dec.Token() // ']'
+			return values, err
+		}
+	}
+
+	return tok, nil
+}
+
+// emitYAML writes `value` at indentation level `indent`.  `inList` is true if the value directly follows a "- "
+// list item marker, in which case the first line must not be indented.
+func emitYAML(buf *bytes.Buffer, value interface{}, indent int, inList bool) {
+	pad := strings.Repeat("  ", indent)
+
+	switch t := value.(type) {
+	case []yamlEntry:
+		if len(t) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		for i, e := range t {
+			if i > 0 || !inList {
+				buf.WriteString(pad)
+			}
+			buf.WriteString(yamlScalar(e.key) + ":")
+			emitYAMLValue(buf, e.value, indent+1)
+		}
+	case []interface{}:
+		if len(t) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		for i, v := range t {
+			if i > 0 || !inList {
+				buf.WriteString(pad)
+			}
+			buf.WriteString("-")
+			switch v.(type) {
+			case []yamlEntry, []interface{}:
+				if isEmptyYAML(v) {
+					emitYAML(buf, v, indent+1, true)
+				} else {
+					buf.WriteString(" ")
+					emitYAML(buf, v, indent+1, true)
+				}
+			default:
+				buf.WriteString(" " + yamlScalarValue(v) + "\n")
+			}
+		}
+	default:
+		buf.WriteString(pad + yamlScalarValue(t) + "\n")
+	}
+}
+
+// emitYAMLValue writes `value` after a "key:".
+func emitYAMLValue(buf *bytes.Buffer, value interface{}, indent int) {
+	switch value.(type) {
+	case []yamlEntry, []interface{}:
+		if isEmptyYAML(value) {
+			emitYAML(buf, value, indent, false)
+			return
+		}
+		buf.WriteString("\n")
+		emitYAML(buf, value, indent, false)
+	default:
+		buf.WriteString(" " + yamlScalarValue(value) + "\n")
+	}
+}
+
+func isEmptyYAML(value interface{}) bool {
+	switch t := value.(type) {
+	case []yamlEntry:
+		return len(t) == 0
+	case []interface{}:
+		return len(t) == 0
+	}
+	return false
+}
+
+// yamlScalarValue formats JSON scalar `value` (string, json.Number, bool or nil) as YAML.
+func yamlScalarValue(value interface{}) string {
+	switch t := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(t)
+	case json.Number:
+		return t.String()
+	case string:
+		return yamlScalar(t)
+	}
+	return fmt.Sprint(value)
+}
+
+// yamlScalar returns string `s`, quoted if it could be read as something other than that string.
+func yamlScalar(s string) string {
+	needsQuotes := s == "" || strings.ContainsAny(s, ":#{}[],&*!|>'\"%@`\n\t\\") ||
+		strings.TrimSpace(s) != s || strings.HasPrefix(s, "-") || strings.HasPrefix(s, "?")
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "y", "n", "on", "off", "null", "~", ".inf", ".nan":
+		needsQuotes = true
+	}
+	// Numbers, including hexadecimal and octal integers.
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		needsQuotes = true
+	}
+	if _, err := strconv.ParseInt(s, 0, 64); err == nil {
+		needsQuotes = true
+	}
+
+	if !needsQuotes {
+		return s
+	}
+	// JSON strings are valid YAML double quoted strings.
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
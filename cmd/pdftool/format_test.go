@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestYAMLScalar(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"two words", "two words"},
+		{"", `""`},
+		{"key: value", `"key: value"`},
+		{"a # comment", `"a # comment"`},
+		{" leading", `" leading"`},
+		{"trailing ", `"trailing "`},
+		{"yes", `"yes"`},
+		{"No", `"No"`},
+		{"true", `"true"`},
+		{"null", `"null"`},
+		{"~", `"~"`},
+		{"y", `"y"`},
+		{"12", `"12"`},
+		{"1.5e3", `"1.5e3"`},
+		{"0x1F", `"0x1F"`},
+		{".inf", `".inf"`},
+		{"-item", `"-item"`},
+		{"[list]", `"[list]"`},
+		{"line\nbreak", `"line\nbreak"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{"D:20180102150405Z", `"D:20180102150405Z"`},
+	}
+
+	for _, test := range tests {
+		if got := yamlScalar(test.in); got != test.want {
+			t.Errorf("yamlScalar(%q) = %s, want %s", test.in, got, test.want)
+		}
+	}
+}
+
+func TestWriteYAML(t *testing.T) {
+	tests := []struct {
+		name, json, want string
+	}{
+		{
+			"scalars",
+			`{"a": 1, "b": "x: y", "c": true, "d": null, "e": ""}`,
+			"a: 1\nb: \"x: y\"\nc: true\nd: null\ne: \"\"\n",
+		},
+		{
+			"nested maps and lists",
+			`{"c": [1, "no"], "d": {"e": null, "f": []}, "g": {}}`,
+			"c:\n  - 1\n  - \"no\"\nd:\n  e: null\n  f: []\ng: {}\n",
+		},
+		{
+			"lists of maps and lists",
+			`[{"a": 1, "b": {"c": 2}}, [1, 2], []]`,
+			"- a: 1\n  b:\n    c: 2\n- - 1\n  - 2\n- []\n",
+		},
+		{
+			"key order and quoted keys",
+			`{"z": 1, "key: x": "v", "a": 2}`,
+			"z: 1\n\"key: x\": v\na: 2\n",
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := writeYAML(&buf, []byte(test.json)); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/unidoc/unidoc-examples/pkg/pdfops"
+)
+
+func newInfoCommand() *command {
+	format := formatText
+
+	return &command{
+		name:  "info",
+		args:  "input.pdf [input2.pdf] ...",
+		short: "Print the number of pages, encryption status, version and document information of PDF files",
+		setFlags: func(fs *flag.FlagSet) {
+			addFormatFlag(fs, &format)
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 1); err != nil {
+				return err
+			}
+			if err := checkFormat(format); err != nil {
+				return err
+			}
+
+			results := []*pdfops.PdfProperties{}
+			for _, inputPath := range args {
+				ret, err := pdfops.GetPdfProperties(inputPath, g.pdfopsOptions())
+				if err != nil {
+					return err
+				}
+				results = append(results, ret)
+			}
+
+			if format != formatText {
+				return writeReport(os.Stdout, format, "info", results)
+			}
+
+			for _, ret := range results {
+				fmt.Printf("Input file: %s\n", ret.Path)
+				fmt.Printf(" PDF version: %s\n", ret.PdfVersion)
+				fmt.Printf(" Num Pages: %d\n", ret.NumPages)
+				fmt.Printf(" Is Encrypted: %t\n", ret.IsEncrypted)
+				fmt.Printf(" Is Viewable: %t\n", ret.CanView)
+				if ret.CanView && ret.IsEncrypted {
+					fmt.Printf(" Opened with: %s\n", ret.Auth)
+				}
+				if ret.Encryption != nil {
+					fmt.Printf(" Encryption method: %s\n", ret.Encryption.Method)
+					printPermissions(ret.Encryption.Permissions)
+				}
+
+				keys := []string{}
+				for key := range ret.Info {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				for _, key := range keys {
+					fmt.Printf(" %s: %s\n", key, ret.Info[key])
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+// printPermissions prints `perms` in text format.
+func printPermissions(perms *pdfops.Permissions) {
+	if perms == nil {
+		return
+	}
+	fmt.Printf(" Permissions:\n")
+	fmt.Printf("  Print: %t (high quality: %t)\n", perms.Print, perms.PrintHighQuality)
+	fmt.Printf("  Modify: %t\n", perms.Modify)
+	fmt.Printf("  Extract: %t (for accessibility: %t)\n", perms.ExtractGraphics, perms.ExtractAccessibility)
+	fmt.Printf("  Annotate: %t\n", perms.Annotate)
+	fmt.Printf("  Fill forms: %t\n", perms.FillForms)
+	fmt.Printf("  Assemble: %t\n", perms.Assemble)
+}
+
+// pageInfoResult is the structured output of the pageinfo command for one file.
+type pageInfoResult struct {
+	Path  string                  `json:"path"`
+	Pages []pdfops.PageProperties `json:"pages"`
+}
+
+func newPageInfoCommand() *command {
+	format := formatText
+
+	return &command{
+		name:  "pageinfo",
+		args:  "input.pdf [page num]",
+		short: "Print the page boxes and rotation of pages (all pages if no page is given)",
+		setFlags: func(fs *flag.FlagSet) {
+			addFormatFlag(fs, &format)
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 1); err != nil {
+				return err
+			}
+			if err := checkFormat(format); err != nil {
+				return err
+			}
+
+			pageNum := 0
+			if len(args) > 1 {
+				num, err := strconv.Atoi(args[1])
+				if err != nil {
+					return newUsageError("invalid page num: %v", err)
+				}
+				pageNum = num
+			}
+
+			props, err := pdfops.GetPageProperties(args[0], pageNum, g.pdfopsOptions())
+			if err != nil {
+				return err
+			}
+
+			if format != formatText {
+				return writeReport(os.Stdout, format, "pageinfo", []pageInfoResult{{Path: args[0], Pages: props}})
+			}
+
+			fmt.Printf("Input file: %s\n", args[0])
+			for _, p := range props {
+				fmt.Printf("-- Page %d\n", p.PageNum)
+				fmt.Printf(" Page rotation: %d\n", p.Rotate)
+				fmt.Printf(" Page mediabox: %v\n", p.MediaBox)
+				fmt.Printf(" Page cropbox: %v\n", p.CropBox)
+				fmt.Printf(" Page height: %f\n", p.Height)
+				fmt.Printf(" Page width: %f\n", p.Width)
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+
+	"github.com/unidoc/unidoc-examples/pkg/pdfops"
+)
+
+// addImpositionFlags registers the flags shared by nup and booklet on `fs`, setting `impOpts` and `paper`.
+func addImpositionFlags(fs *flag.FlagSet, impOpts *pdfops.ImpositionOptions, paper *string) {
+	fs.StringVar(paper, "paper", "", "Sheet size: A3, A4, A5, Letter, Legal, Tabloid or WxH[pt|in|mm|cm] "+
+		"(default the size of the first page)")
+	fs.Float64Var(&impOpts.Margin, "margin", 0, "Space around the edges of the sheets")
+	fs.Float64Var(&impOpts.Gutter, "gutter", 0, "Space between pages")
+	fs.Float64Var(&impOpts.Border, "border", 0, "Width of a line around each page (default none)")
+}
+
+// parseImpositionFlags completes `impOpts` with the values of the flags registered by addImpositionFlags.
+func parseImpositionFlags(impOpts *pdfops.ImpositionOptions, paper, pagesExpr string) error {
+	var err error
+	if impOpts.Pages, err = parsePages(pagesExpr); err != nil {
+		return err
+	}
+	if paper != "" {
+		if impOpts.Paper, err = pdfops.ParsePaperSize(paper); err != nil {
+			return newUsageError("%v", err)
+		}
+	}
+	if impOpts.Margin < 0 || impOpts.Gutter < 0 || impOpts.Border < 0 {
+		return newUsageError("-margin, -gutter and -border must not be negative")
+	}
+	return nil
+}
+
+func newNupCommand() *command {
+	pagesExpr := ""
+	paper := ""
+	order := "across"
+	nupOpts := pdfops.NupOptions{}
+
+	return &command{
+		name:  "nup",
+		args:  "input.pdf <pages per sheet>",
+		short: "Place 2, 4, 6, 9 or 16 pages on each sheet",
+		setFlags: func(fs *flag.FlagSet) {
+			addPagesFlag(fs, &pagesExpr)
+			addImpositionFlags(fs, &nupOpts.ImpositionOptions, &paper)
+			fs.StringVar(&order, "order", order, "Order of the pages on a sheet: across, down, across-rtl or down-rtl")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 2); err != nil {
+				return err
+			}
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+			if err := parseImpositionFlags(&nupOpts.ImpositionOptions, paper, pagesExpr); err != nil {
+				return err
+			}
+			if nupOpts.Order, err = pdfops.ParseNupOrder(order); err != nil {
+				return newUsageError("%v", err)
+			}
+			if nupOpts.N, err = strconv.Atoi(args[1]); err != nil {
+				return newUsageError("invalid number of pages per sheet: %v", err)
+			}
+
+			return pdfops.NupPdf(args[0], outputPath, nupOpts, g.pdfopsOptions())
+		},
+	}
+}
+
+func newBookletCommand() *command {
+	pagesExpr := ""
+	paper := ""
+	impOpts := pdfops.ImpositionOptions{}
+
+	return &command{
+		name:  "booklet",
+		args:  "input.pdf",
+		short: "Arrange pages two to a sheet side for printing double sided and folding into a booklet",
+		setFlags: func(fs *flag.FlagSet) {
+			addPagesFlag(fs, &pagesExpr)
+			addImpositionFlags(fs, &impOpts, &paper)
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 1); err != nil {
+				return err
+			}
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+			if err := parseImpositionFlags(&impOpts, paper, pagesExpr); err != nil {
+				return err
+			}
+
+			return pdfops.BookletPdf(args[0], outputPath, impOpts, g.pdfopsOptions())
+		},
+	}
+}
+
+func newResizeCommand() *command {
+	pagesExpr := ""
+	mode := "fit"
+	resizeOpts := pdfops.ResizeOptions{}
+
+	return &command{
+		name:  "resize",
+		args:  "input.pdf <paper size>",
+		short: "Scale pages to a paper size such as A4 or Letter",
+		setFlags: func(fs *flag.FlagSet) {
+			addPagesFlag(fs, &pagesExpr)
+			fs.StringVar(&mode, "mode", mode,
+				"fit (scale to fit), fill (scale to cover, cutting off the rest) or center (don't scale)")
+			fs.BoolVar(&resizeOpts.Stretch, "stretch", false,
+				"Scale width and height independently with -mode fit or fill, not keeping the aspect ratio")
+			fs.Float64Var(&resizeOpts.Margin, "margin", 0, "Space left around the page contents")
+			fs.BoolVar(&resizeOpts.KeepOrientation, "keep-orientation", false,
+				"Use the paper size as given instead of turning it to the orientation of each page")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 2); err != nil {
+				return err
+			}
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+			if resizeOpts.Pages, err = parsePages(pagesExpr); err != nil {
+				return err
+			}
+			if resizeOpts.Paper, err = pdfops.ParsePaperSize(args[1]); err != nil {
+				return newUsageError("%v", err)
+			}
+			if resizeOpts.Mode, err = pdfops.ParseResizeMode(mode); err != nil {
+				return newUsageError("%v", err)
+			}
+			if resizeOpts.Margin < 0 {
+				return newUsageError("-margin must not be negative")
+			}
+
+			return pdfops.ResizePdf(args[0], outputPath, resizeOpts, g.pdfopsOptions())
+		},
+	}
+}
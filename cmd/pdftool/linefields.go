@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/pkg/pdfops"
+)
+
+func newLineFieldsCommand() *command {
+	pagesExpr := ""
+	format := formatText
+	fieldType := "sig"
+	lineOpts := pdfops.LineFieldOptions{}
+
+	return &command{
+		name:  "linefields",
+		args:  "input.pdf",
+		short: "Create signature or text form fields on the \"______\" lines of a document",
+		setFlags: func(fs *flag.FlagSet) {
+			addPagesFlag(fs, &pagesExpr)
+			addFormatFlag(fs, &format)
+			fs.StringVar(&fieldType, "type", "sig", "Type of the fields: sig or text")
+			fs.StringVar(&lineOpts.Pattern, "pattern", pdfops.DefaultLinePattern,
+				"Regular expression of the lines to put fields on")
+			fs.StringVar(&lineOpts.NameTemplate, "name", "",
+				"Field names with placeholders {n} (field number) and {page} (default signature_{n} or text_{n})")
+			fs.Float64Var(&lineOpts.Height, "height", 0,
+				"Height of the fields in points (default the height of the line's text, twice that for signatures)")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 1); err != nil {
+				return err
+			}
+			if err := checkFormat(format); err != nil {
+				return err
+			}
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+			pages, err := parsePages(pagesExpr)
+			if err != nil {
+				return err
+			}
+			lineOpts.Pages = pages
+
+			switch fieldType {
+			case "sig":
+				lineOpts.Type = pdfops.LineFieldSignature
+			case "text":
+				lineOpts.Type = pdfops.LineFieldText
+			default:
+				return newUsageError("invalid field type %q", fieldType)
+			}
+			if _, err := pdfops.CompilePattern(lineOpts.Pattern, pdfops.FindOptions{Regexp: true}); err != nil {
+				return newUsageError("%v", err)
+			}
+
+			fields, err := pdfops.AddLineFields(args[0], outputPath, lineOpts, g.pdfopsOptions())
+			if err != nil {
+				return err
+			}
+
+			if format != formatText {
+				return writeReport(os.Stdout, format, "linefields", fields)
+			}
+			for _, field := range fields {
+				fmt.Printf("%s: page %d [%.2f %.2f %.2f %.2f]\n", field.Name, field.Page,
+					field.Rect[0], field.Rect[1], field.Rect[2], field.Rect[3])
+			}
+			fmt.Printf("%d field(s) created\n", len(fields))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,326 @@
+/*
+ * pdftool bundles the PDF examples of this repository into a single binary with subcommands.
+ *
+ * Run as: pdftool [global options] <command> [options] [arguments]
+ * Global options can also be given after the command name.  Run "pdftool help <command>" for the usage of a command.
+ *
+ * Passwords for encrypted inputs are taken from --password/--owner-password, else from --password-file, else from
+ * the PDFTOOL_PASSWORD/PDFTOOL_OWNER_PASSWORD environment variables.
+ *
+ * Exit codes: 0 on success, 1 if the command failed, 2 for invalid command lines, 3 if an input could not be
+ * decrypted with the given passwords and 4 if an input is not a valid PDF file.
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	unicommon "github.com/unidoc/unidoc/common"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+	"github.com/unidoc/unidoc-examples/pkg/pdfops"
+)
+
+const (
+	exitOK            = 0
+	exitError         = 1
+	exitUsage         = 2
+	exitWrongPassword = 3
+	exitCorrupt       = 4
+)
+
+// globalOptions are the options shared by all commands.
+type globalOptions struct {
+	password      string
+	ownerPassword string
+	passwordFile  string
+	repair        bool
+	logLevel      string
+	out           string
+}
+
+// resolvePasswords fills in the passwords that were not given on the command line from the password file or
+// the environment.
+func (g *globalOptions) resolvePasswords() error {
+	if g.password != "" || g.ownerPassword != "" {
+		return nil
+	}
+
+	if g.passwordFile != "" {
+		userPassword, ownerPassword, err := opener.ReadPasswordFile(g.passwordFile)
+		if err != nil {
+			return err
+		}
+		g.password, g.ownerPassword = userPassword, ownerPassword
+		return nil
+	}
+
+	g.password, g.ownerPassword = opener.PasswordsFromEnv()
+	return nil
+}
+
+// pdfopsOptions returns the library options corresponding to the global options.
+func (g *globalOptions) pdfopsOptions() pdfops.Options {
+	return pdfops.Options{
+		Open: opener.Options{
+			UserPassword:  g.password,
+			OwnerPassword: g.ownerPassword,
+			Repair:        g.repair,
+		},
+	}
+}
+
+// requireOut returns the output path, or a usage error if --out was not specified.
+func (g *globalOptions) requireOut() (string, error) {
+	if g.out == "" {
+		return "", newUsageError("--out is required")
+	}
+	return g.out, nil
+}
+
+// addFlags registers the global options on `fs`.  The current values are used as defaults so that options given
+// before the command name are retained.
+func (g *globalOptions) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(&g.password, "password", g.password, "User password for encrypted input files")
+	fs.StringVar(&g.ownerPassword, "owner-password", g.ownerPassword, "Owner password for encrypted input files")
+	fs.StringVar(&g.passwordFile, "password-file", g.passwordFile,
+		"File with the user password on the first line and optionally the owner password on the second")
+	fs.BoolVar(&g.repair, "repair", g.repair, "Try to repair input files that cannot be parsed")
+	fs.StringVar(&g.logLevel, "log-level", g.logLevel,
+		"Log level: trace, debug, info, notice, warning or error (default no logging)")
+	fs.StringVar(&g.out, "out", g.out, "Output file path")
+}
+
+// command is a pdftool subcommand.
+type command struct {
+	name  string
+	args  string // Synopsis of the positional arguments.
+	short string // One line description.
+
+	// setFlags registers the command specific options.  Can be nil.
+	setFlags func(fs *flag.FlagSet)
+
+	// run executes the command with the positional arguments in `args`.
+	run func(g *globalOptions, args []string) error
+}
+
+// usageError is returned by commands when the command line is invalid.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+func newUsageError(format string, a ...interface{}) error {
+	return usageError{msg: fmt.Sprintf(format, a...)}
+}
+
+// commands returns all available commands.
+func commands() []*command {
+	return []*command{
+		newMergeCommand(),
+		newInsertCommand(),
+		newSplitCommand(),
+		newPagesCommand(),
+		newCropCommand(),
+		newRotateCommand(),
+		newResizeCommand(),
+		newNupCommand(),
+		newBookletCommand(),
+		newStampCommand(),
+		newBatesCommand(),
+		newWatermarkCommand(),
+		newStripLayerCommand(),
+		newBarcodeCommand(),
+		newProtectCommand(),
+		newUnlockCommand(),
+		newTextCommand(),
+		newFindCommand(),
+		newLineFieldsCommand(),
+		newRedactCommand(),
+		newInfoCommand(),
+		newPageInfoCommand(),
+		newMetaCommand(),
+		newSecurityInfoCommand(),
+	}
+}
+
+// addPagesFlag registers the --pages option on `fs`, storing the expression in `expr`.
+func addPagesFlag(fs *flag.FlagSet, expr *string) {
+	fs.StringVar(expr, "pages", "",
+		"Pages to process, e.g. 1-3,7,10-end, odd, even, last, !5 or 1-end:landscape (default all pages)")
+}
+
+// parsePages parses the page selection expression `expr` given with --pages.
+func parsePages(expr string) (*pagerange.Selection, error) {
+	sel, err := pagerange.Parse(expr)
+	if err != nil {
+		return nil, newUsageError("%v", err)
+	}
+	return sel, nil
+}
+
+// checkArgs returns a usage error if there are less than `min` positional arguments.
+func checkArgs(args []string, min int) error {
+	if len(args) < min {
+		return newUsageError("requires at least %d argument(s), got %d", min, len(args))
+	}
+	return nil
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func printUsage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: pdftool [global options] <command> [options] [arguments]\n\n")
+	fmt.Fprintf(w, "Commands:\n")
+
+	cmds := commands()
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].name < cmds[j].name })
+	for _, cmd := range cmds {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.short)
+	}
+
+	fmt.Fprintf(w, "\nGlobal options:\n")
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+	fmt.Fprintf(w, "\nRun \"pdftool help <command>\" for the usage of a command.\n")
+}
+
+// newCommandFlagSet returns the flag set for `cmd`, including the global options.
+func newCommandFlagSet(cmd *command, g *globalOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	g.addFlags(fs)
+	if cmd.setFlags != nil {
+		cmd.setFlags(fs)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pdftool %s [options] %s\n%s\n\nOptions:\n", cmd.name, cmd.args, cmd.short)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// setLogLevel installs a console logger at level `name`.  Logging is disabled if `name` is empty.
+func setLogLevel(name string) error {
+	levels := map[string]unicommon.LogLevel{
+		"trace":   unicommon.LogLevelTrace,
+		"debug":   unicommon.LogLevelDebug,
+		"info":    unicommon.LogLevelInfo,
+		"notice":  unicommon.LogLevelNotice,
+		"warning": unicommon.LogLevelWarning,
+		"error":   unicommon.LogLevelError,
+	}
+
+	if name == "" {
+		unicommon.SetLogger(unicommon.DummyLogger{})
+		return nil
+	}
+
+	level, ok := levels[strings.ToLower(name)]
+	if !ok {
+		return newUsageError("invalid log level %q", name)
+	}
+	unicommon.SetLogger(unicommon.NewConsoleLogger(level))
+	return nil
+}
+
+func run(args []string) int {
+	g := &globalOptions{}
+
+	fs := flag.NewFlagSet("pdftool", flag.ContinueOnError)
+	g.addFlags(fs)
+	fs.Usage = func() {
+		printUsage(os.Stderr, fs)
+	}
+
+	err := fs.Parse(args)
+	if err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+
+	if fs.NArg() == 0 {
+		printUsage(os.Stderr, fs)
+		return exitUsage
+	}
+
+	name := fs.Arg(0)
+	if name == "help" {
+		if fs.NArg() < 2 {
+			printUsage(os.Stdout, fs)
+			return exitOK
+		}
+		cmd := findCommand(fs.Arg(1))
+		if cmd == nil {
+			fmt.Fprintf(os.Stderr, "Unknown command: %s\n", fs.Arg(1))
+			return exitUsage
+		}
+		cfs := newCommandFlagSet(cmd, g)
+		cfs.SetOutput(os.Stdout)
+		cfs.Usage()
+		return exitOK
+	}
+
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", name)
+		printUsage(os.Stderr, fs)
+		return exitUsage
+	}
+
+	cfs := newCommandFlagSet(cmd, g)
+	err = cfs.Parse(fs.Args()[1:])
+	if err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+
+	err = setLogLevel(g.logLevel)
+	if err == nil {
+		err = g.resolvePasswords()
+	}
+	if err == nil {
+		err = cmd.run(g, cfs.Args())
+	}
+
+	var uerr usageError
+	if errors.As(err, &uerr) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		cfs.Usage()
+		return exitUsage
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		switch {
+		case opener.IsWrongPassword(err):
+			return exitWrongPassword
+		case opener.IsCorrupt(err):
+			return exitCorrupt
+		}
+		return exitError
+	}
+
+	return exitOK
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/unidoc/unidoc-examples/pkg/pdfops"
+)
+
+// stringsFlag is a flag that can be given several times.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func newMetaCommand() *command {
+	format := formatText
+	sets := stringsFlag{}
+	deletes := stringsFlag{}
+	jsonPath := ""
+	keepModDate := false
+	showXMP := false
+
+	return &command{
+		name:  "meta",
+		args:  "input.pdf",
+		short: "Print or change the document information (Title, Author, ...) and XMP metadata",
+		setFlags: func(fs *flag.FlagSet) {
+			addFormatFlag(fs, &format)
+			fs.Var(&sets, "set", "Set an entry, e.g. -set Title=Report (can be repeated)")
+			fs.Var(&deletes, "delete", "Delete an entry, e.g. -delete Keywords (can be repeated)")
+			fs.StringVar(&jsonPath, "json", "",
+				"Apply the entries of a JSON object file, e.g. {\"Title\": \"Report\", \"Keywords\": null}; "+
+					"null deletes an entry")
+			fs.BoolVar(&keepModDate, "keep-moddate", false, "Do not set ModDate to the current time")
+			fs.BoolVar(&showXMP, "xmp", false, "Also print the XMP metadata packet (text format)")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 1); err != nil {
+				return err
+			}
+			if err := checkFormat(format); err != nil {
+				return err
+			}
+			inputPath := args[0]
+
+			if len(sets) == 0 && len(deletes) == 0 && jsonPath == "" {
+				return printMetadata(inputPath, format, showXMP, g)
+			}
+
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+
+			update := pdfops.MetadataUpdate{Set: map[string]string{}, KeepModDate: keepModDate}
+			if jsonPath != "" {
+				f, err := os.Open(jsonPath)
+				if err != nil {
+					return err
+				}
+				update, err = pdfops.ParseMetadataJSON(f)
+				f.Close()
+				if err != nil {
+					return err
+				}
+				update.KeepModDate = keepModDate
+			}
+			// Command line entries take precedence over the JSON file.
+			for _, kv := range sets {
+				i := strings.Index(kv, "=")
+				if i <= 0 {
+					return newUsageError("invalid -set %q, expected key=value", kv)
+				}
+				update.Set[kv[:i]] = kv[i+1:]
+			}
+			update.Delete = append(update.Delete, deletes...)
+
+			return pdfops.UpdateMetadata(inputPath, outputPath, update, g.pdfopsOptions())
+		},
+	}
+}
+
+// printMetadata prints the metadata of `inputPath` in `format`.
+func printMetadata(inputPath, format string, showXMP bool, g *globalOptions) error {
+	meta, err := pdfops.GetMetadata(inputPath, g.pdfopsOptions())
+	if err != nil {
+		return err
+	}
+
+	if format != formatText {
+		return writeReport(os.Stdout, format, "meta", []*pdfops.Metadata{meta})
+	}
+
+	fmt.Printf("Input file: %s\n", meta.Path)
+	keys := []string{}
+	for key := range meta.Info {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf(" %s: %s\n", key, meta.Info[key])
+	}
+	if showXMP && meta.XMP != "" {
+		fmt.Printf(" XMP:\n%s\n", meta.XMP)
+	}
+
+	return nil
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unidoc-examples/pkg/pdfops"
+)
+
+func newMergeCommand() *command {
+	mergeOpts := pdfops.MergeOptions{}
+	mode := "concat"
+	fieldNames := "rename"
+	xfa := "keep-first"
+
+	return &command{
+		name:  "merge",
+		args:  "input1.pdf input2.pdf ...",
+		short: "Merge PDF files, including form field data and bookmarks",
+		setFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&mode, "mode", mode,
+				"Page order: concat, interleave (a page from each input in turn), shuffle (duplex scans: fronts "+
+					"from the first input, backs from the second in reverse order), reverse or collate (-n pages "+
+					"from each input in turn)")
+			fs.IntVar(&mergeOpts.CollateN, "n", 0, "Number of pages taken from each input in turn for -mode collate")
+			fs.BoolVar(&mergeOpts.DropOutlines, "drop-outlines", false, "Do not copy the bookmarks of the inputs")
+			fs.BoolVar(&mergeOpts.NestOutlines, "nest-outlines", false,
+				"Put the bookmarks of each input below a new bookmark named after the input file")
+			fs.StringVar(&fieldNames, "fields", fieldNames,
+				"Handling of same-named form fields: rename (rename colliding fields), prefix (prefix all fields "+
+					"with docN_) or merge (join same-named fields so that they share a value)")
+			fs.StringVar(&xfa, "xfa", xfa, "Handling of XFA forms: keep-first, drop or fail")
+			fs.BoolVar(&mergeOpts.KeepDuplicateResources, "keep-duplicates", false,
+				"Do not share identical fonts and images of the inputs (faster, but larger output)")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 2); err != nil {
+				return err
+			}
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+
+			switch mode {
+			case "concat":
+				mergeOpts.Mode = pdfops.MergeConcatenate
+			case "interleave":
+				mergeOpts.Mode = pdfops.MergeInterleave
+			case "shuffle":
+				if len(args) != 2 {
+					return newUsageError("-mode shuffle requires exactly two inputs")
+				}
+				mergeOpts.Mode = pdfops.MergeShuffle
+			case "reverse":
+				mergeOpts.Mode = pdfops.MergeReverse
+			case "collate":
+				if mergeOpts.CollateN < 1 {
+					return newUsageError("-mode collate requires -n")
+				}
+				mergeOpts.Mode = pdfops.MergeCollate
+			default:
+				return newUsageError("invalid merge mode %q", mode)
+			}
+			if mergeOpts.FieldNames, err = parseFieldNames(fieldNames); err != nil {
+				return err
+			}
+			switch xfa {
+			case "keep-first":
+				mergeOpts.XFA = pdfops.XFAKeepFirst
+			case "drop":
+				mergeOpts.XFA = pdfops.XFADrop
+			case "fail":
+				mergeOpts.XFA = pdfops.XFAFail
+			default:
+				return newUsageError("invalid -xfa value %q", xfa)
+			}
+
+			return pdfops.MergePdf(args, outputPath, mergeOpts, g.pdfopsOptions())
+		},
+	}
+}
+
+// parseFieldNames parses the -fields option of merge and insert.
+func parseFieldNames(s string) (pdfops.FieldNameStrategy, error) {
+	switch s {
+	case "rename":
+		return pdfops.FieldsRenameColliding, nil
+	case "prefix":
+		return pdfops.FieldsPrefixAll, nil
+	case "merge":
+		return pdfops.FieldsMergeSameNamed, nil
+	}
+	return 0, newUsageError("invalid -fields value %q", s)
+}
+
+func newInsertCommand() *command {
+	pagesExpr := ""
+	replace := ""
+	fieldNames := "rename"
+	insertOpts := pdfops.InsertOptions{}
+
+	return &command{
+		name:  "insert",
+		args:  "target.pdf source.pdf",
+		short: "Insert pages of another PDF file after a page, or in place of pages",
+		setFlags: func(fs *flag.FlagSet) {
+			addPagesFlag(fs, &pagesExpr)
+			fs.IntVar(&insertOpts.After, "after", 0, "Insert after this page of the target (default before the first)")
+			fs.StringVar(&replace, "replace", "", "Pages of the target to replace, e.g. 5 or 5-7")
+			fs.StringVar(&fieldNames, "fields", fieldNames,
+				"Handling of form fields of the source whose names are taken: rename, prefix (with doc2_) or merge")
+			fs.BoolVar(&insertOpts.KeepDuplicateResources, "keep-duplicates", false,
+				"Do not share identical fonts and images of the files (faster, but larger output)")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 2); err != nil {
+				return err
+			}
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+			if insertOpts.Pages, err = parsePages(pagesExpr); err != nil {
+				return err
+			}
+			if insertOpts.FieldNames, err = parseFieldNames(fieldNames); err != nil {
+				return err
+			}
+
+			if replace != "" {
+				if insertOpts.After != 0 {
+					return newUsageError("-after and -replace cannot be combined")
+				}
+				from, to := replace, replace
+				if i := strings.Index(replace, "-"); i >= 0 {
+					from, to = replace[:i], replace[i+1:]
+				}
+				first, err := strconv.Atoi(strings.TrimSpace(from))
+				last, err2 := strconv.Atoi(strings.TrimSpace(to))
+				if err != nil || err2 != nil || first < 1 || last < first {
+					return newUsageError("invalid -replace pages %q", replace)
+				}
+				insertOpts.After, insertOpts.Replace = first-1, last-first+1
+			}
+			if insertOpts.After < 0 {
+				return newUsageError("-after must not be negative")
+			}
+
+			return pdfops.InsertPdf(args[0], args[1], outputPath, insertOpts, g.pdfopsOptions())
+		},
+	}
+}
+
+func newSplitCommand() *command {
+	pagesExpr := ""
+	mode := "range"
+	everyN := 0
+	maxSize := ""
+	dropBlanks := false
+	maxInk := 0.0
+
+	return &command{
+		name:  "split",
+		args:  "input.pdf [<page_from> <page_to>]",
+		short: "Extract pages to a new PDF file, or split a PDF file into several files",
+		setFlags: func(fs *flag.FlagSet) {
+			addPagesFlag(fs, &pagesExpr)
+			fs.StringVar(&mode, "mode", mode,
+				"range: write the selected pages to --out, burst: one file per page, every: a file every -n pages, "+
+					"bookmarks: a file per top level bookmark, size: files of at most -max-size bytes, "+
+					"blank: a file at each blank (separator) page")
+			fs.IntVar(&everyN, "n", 0, "Number of pages per file for -mode every")
+			fs.StringVar(&maxSize, "max-size", "", "Maximum file size for -mode size, e.g. 500k or 10M")
+			fs.BoolVar(&dropBlanks, "drop-blank", false, "Remove the blank pages for -mode blank")
+			fs.Float64Var(&maxInk, "max-ink", pdfops.DefaultMaxInkRatio,
+				"Fraction of image samples that may differ from the background of a blank page, for -mode blank")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 1); err != nil {
+				return err
+			}
+
+			// The page range can also be given as in pdf_split.go.
+			if len(args) == 2 {
+				return newUsageError("<page_from> requires <page_to>")
+			} else if len(args) >= 3 {
+				if pagesExpr != "" {
+					return newUsageError("specify either --pages or <page_from> <page_to>")
+				}
+				pagesExpr = args[1] + "-" + args[2]
+			}
+
+			pages, err := parsePages(pagesExpr)
+			if err != nil {
+				return err
+			}
+
+			if mode == "range" {
+				outputPath, err := g.requireOut()
+				if err != nil {
+					return err
+				}
+				return pdfops.SplitPdf(args[0], outputPath, pages, g.pdfopsOptions())
+			}
+
+			// For the multi-file modes --out is the file name template.
+			splitOpts := pdfops.SplitOptions{Template: g.out, Pages: pages}
+			switch mode {
+			case "burst":
+				splitOpts.Mode = pdfops.SplitBurst
+			case "every":
+				if everyN < 1 {
+					return newUsageError("-mode every requires -n")
+				}
+				splitOpts.Mode = pdfops.SplitEveryN
+				splitOpts.N = everyN
+			case "bookmarks":
+				splitOpts.Mode = pdfops.SplitAtBookmarks
+			case "size":
+				size, err := parseSize(maxSize)
+				if err != nil {
+					return err
+				}
+				splitOpts.Mode = pdfops.SplitBySize
+				splitOpts.MaxSize = size
+			case "blank":
+				splitOpts.Mode = pdfops.SplitAtBlanks
+				splitOpts.DropBlanks = dropBlanks
+				splitOpts.MaxInkRatio = maxInk
+			default:
+				return newUsageError("invalid split mode %q", mode)
+			}
+
+			outputPaths, err := pdfops.SplitMultiPdf(args[0], splitOpts, g.pdfopsOptions())
+			for _, outputPath := range outputPaths {
+				fmt.Println(outputPath)
+			}
+			return err
+		},
+	}
+}
+
+// parseSize parses a size in bytes with an optional k, M or G suffix.
+func parseSize(str string) (int64, error) {
+	if str == "" {
+		return 0, newUsageError("-max-size is required")
+	}
+
+	multiplier := int64(1)
+	switch strings.ToUpper(str[len(str)-1:]) {
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		str = str[:len(str)-1]
+	}
+
+	size, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, newUsageError("invalid size %q", str)
+	}
+	return size * multiplier, nil
+}
+
+func newPagesCommand() *command {
+	return &command{
+		name:  "pages",
+		args:  "input.pdf <order>",
+		short: "Reorder, delete, duplicate pages and insert blank pages, e.g. 3,1-2,blank:A4,5-end",
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 2); err != nil {
+				return err
+			}
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+			order, err := pdfops.ParsePageOrder(args[1])
+			if err != nil {
+				return newUsageError("%v", err)
+			}
+
+			return pdfops.ReorderPagesPdf(args[0], outputPath, order, g.pdfopsOptions())
+		},
+	}
+}
+
+func newCropCommand() *command {
+	pagesExpr := ""
+	boxExprs := map[string]*string{}
+	boxOpts := pdfops.PageBoxesOptions{}
+
+	return &command{
+		name:  "crop",
+		args:  "input.pdf [percentage]",
+		short: "Crop pages by a percentage, to their contents or to given boxes, and set their trim, bleed and art boxes",
+		setFlags: func(fs *flag.FlagSet) {
+			addPagesFlag(fs, &pagesExpr)
+			for _, name := range []string{"mediabox", "cropbox", "bleedbox", "trimbox", "artbox"} {
+				boxExprs[name] = fs.String(name, "",
+					"New "+name+": llx,lly,urx,ury, margins:l,b,r,t, margins:m, auto (the contents) or none")
+			}
+			fs.Float64Var(&boxOpts.Padding, "padding", 0, "Space left around the contents with auto")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 1); err != nil {
+				return err
+			}
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+			pages, err := parsePages(pagesExpr)
+			if err != nil {
+				return err
+			}
+
+			boxes := map[string]**pdfops.BoxSpec{
+				"mediabox": &boxOpts.MediaBox,
+				"cropbox":  &boxOpts.CropBox,
+				"bleedbox": &boxOpts.BleedBox,
+				"trimbox":  &boxOpts.TrimBox,
+				"artbox":   &boxOpts.ArtBox,
+			}
+			numBoxes := 0
+			for name, expr := range boxExprs {
+				if *expr == "" {
+					continue
+				}
+				spec, err := pdfops.ParseBoxSpec(*expr)
+				if err != nil {
+					return newUsageError("-%s: %v", name, err)
+				}
+				*boxes[name] = &spec
+				numBoxes++
+			}
+
+			if numBoxes > 0 {
+				if len(args) > 1 {
+					return newUsageError("a percentage cannot be combined with box options")
+				}
+				boxOpts.Pages = pages
+				return pdfops.SetPageBoxesPdf(args[0], outputPath, boxOpts, g.pdfopsOptions())
+			}
+
+			if err := checkArgs(args, 2); err != nil {
+				return err
+			}
+			percentage, err := strconv.ParseInt(args[1], 10, 32)
+			if err != nil {
+				return newUsageError("invalid percentage: %v", err)
+			}
+
+			return pdfops.CropPdf(args[0], outputPath, percentage, pages, g.pdfopsOptions())
+		},
+	}
+}
+
+func newRotateCommand() *command {
+	flatten := false
+	absolute := false
+	pagesExpr := ""
+	ranges := stringsFlag{}
+
+	return &command{
+		name:  "rotate",
+		args:  "input.pdf [angle|auto]",
+		short: "Rotate pages by a multiple of 90 degrees or upright by their text, or flatten their rotation",
+		setFlags: func(fs *flag.FlagSet) {
+			addPagesFlag(fs, &pagesExpr)
+			fs.Var(&ranges, "range",
+				"Rotate a page range by its own angle, e.g. 1-3=90 or 5-end=auto (can be repeated, the last match wins)")
+			fs.BoolVar(&absolute, "absolute", false, "Set the rotation of the pages to the angle instead of adding it")
+			fs.BoolVar(&flatten, "flatten", false,
+				"Rotate the page contents instead of setting the page's Rotate entry, which is removed")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 1); err != nil {
+				return err
+			}
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+
+			rotateOpts := pdfops.RotateOptions{Flatten: flatten}
+			if len(args) > 1 {
+				pages, err := parsePages(pagesExpr)
+				if err != nil {
+					return err
+				}
+				rule, err := parseRotation(args[1], absolute)
+				if err != nil {
+					return err
+				}
+				rule.Pages = pages
+				rotateOpts.Rules = append(rotateOpts.Rules, rule)
+			} else if pagesExpr != "" && !flatten {
+				return newUsageError("--pages requires an angle")
+			}
+
+			for _, r := range ranges {
+				i := strings.LastIndex(r, "=")
+				if i < 0 {
+					return newUsageError("invalid range %q, expected <pages>=<angle>", r)
+				}
+				pages, err := parsePages(r[:i])
+				if err != nil {
+					return err
+				}
+				rule, err := parseRotation(r[i+1:], absolute)
+				if err != nil {
+					return err
+				}
+				rule.Pages = pages
+				rotateOpts.Rules = append(rotateOpts.Rules, rule)
+			}
+
+			if len(rotateOpts.Rules) == 0 && !flatten {
+				return newUsageError("requires an angle, -range or -flatten")
+			}
+
+			// Flatten only the pages given with --pages, even without an angle.
+			if len(rotateOpts.Rules) == 0 && pagesExpr != "" {
+				pages, err := parsePages(pagesExpr)
+				if err != nil {
+					return err
+				}
+				rotateOpts.Rules = append(rotateOpts.Rules, pdfops.RotationRule{Pages: pages})
+			}
+
+			return pdfops.RotatePagesPdf(args[0], outputPath, rotateOpts, g.pdfopsOptions())
+		},
+	}
+}
+
+// parseRotation parses the angle `s` given to rotate, a multiple of 90 or auto.
+func parseRotation(s string, absolute bool) (pdfops.RotationRule, error) {
+	if strings.EqualFold(s, "auto") {
+		return pdfops.RotationRule{Auto: true}, nil
+	}
+	degrees, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || degrees%90 != 0 {
+		return pdfops.RotationRule{}, newUsageError("invalid angle %q, expected a multiple of 90 or auto", s)
+	}
+	return pdfops.RotationRule{Angle: degrees, Absolute: absolute}, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unidoc-examples/pkg/pdfops"
+)
+
+func newRedactCommand() *command {
+	pagesExpr := ""
+	format := formatText
+	terms := stringsFlag{}
+	regexps := stringsFlag{}
+	rects := stringsFlag{}
+	color := "black"
+	redactOpts := pdfops.RedactOptions{}
+
+	return &command{
+		name:  "redact",
+		args:  "input.pdf",
+		short: "Remove text and image areas from the pages and scrub them from annotations and metadata",
+		setFlags: func(fs *flag.FlagSet) {
+			addPagesFlag(fs, &pagesExpr)
+			addFormatFlag(fs, &format)
+			fs.Var(&terms, "term", "Text to redact (can be repeated)")
+			fs.Var(&regexps, "regex", "Regular expression of text to redact (can be repeated)")
+			fs.BoolVar(&redactOpts.IgnoreCase, "i", false, "Ignore case in -term and -regex")
+			fs.Var(&rects, "rect",
+				"Area to redact as [page:]llx,lly,urx,ury in points, on all pages if no page is given (can be repeated)")
+			fs.StringVar(&color, "color", color, "Fill color of the redacted areas, #rrggbb or a name")
+			fs.StringVar(&redactOpts.Replacement, "replacement", "",
+				"Text that replaces matches in annotations, form fields, bookmarks and metadata")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 1); err != nil {
+				return err
+			}
+			if err := checkFormat(format); err != nil {
+				return err
+			}
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+			if redactOpts.Pages, err = parsePages(pagesExpr); err != nil {
+				return err
+			}
+			if redactOpts.FillColor, err = pdfops.ParseRGB(color); err != nil {
+				return newUsageError("%v", err)
+			}
+			for _, rect := range rects {
+				r, err := parseRedactRect(rect)
+				if err != nil {
+					return err
+				}
+				redactOpts.Rects = append(redactOpts.Rects, r)
+			}
+			redactOpts.Terms = terms
+			redactOpts.Regexps = regexps
+			if len(terms) == 0 && len(regexps) == 0 && len(rects) == 0 {
+				return newUsageError("requires at least one -term, -regex or -rect")
+			}
+
+			redactions, err := pdfops.RedactPdf(args[0], outputPath, redactOpts, g.pdfopsOptions())
+			if err != nil {
+				return err
+			}
+
+			if format != formatText {
+				return writeReport(os.Stdout, format, "redact", redactions)
+			}
+			for _, r := range redactions {
+				fmt.Printf("page %d: [%.2f %.2f %.2f %.2f]", r.Page, r.BBox[0], r.BBox[1], r.BBox[2], r.BBox[3])
+				if r.Text != "" {
+					fmt.Printf(" %q", r.Text)
+				}
+				fmt.Println()
+			}
+			fmt.Printf("%d area(s) redacted\n", len(redactions))
+			return nil
+		},
+	}
+}
+
+// parseRedactRect parses a -rect value, [page:]llx,lly,urx,ury.
+func parseRedactRect(s string) (pdfops.RedactRect, error) {
+	r := pdfops.RedactRect{}
+	coords := s
+	if i := strings.Index(s, ":"); i >= 0 {
+		page, err := strconv.Atoi(s[:i])
+		if err != nil || page < 1 {
+			return r, newUsageError("invalid page in -rect %q", s)
+		}
+		r.Page = page
+		coords = s[i+1:]
+	}
+
+	parts := strings.Split(coords, ",")
+	if len(parts) != 4 {
+		return r, newUsageError("invalid -rect %q, expected [page:]llx,lly,urx,ury", s)
+	}
+	for i, part := range parts {
+		val, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return r, newUsageError("invalid -rect %q: %v", s, err)
+		}
+		r.BBox[i] = val
+	}
+	if r.BBox[0] >= r.BBox[2] || r.BBox[1] >= r.BBox[3] {
+		return r, newUsageError("invalid -rect %q: the lower left corner must be below and left of the upper right", s)
+	}
+	return r, nil
+}
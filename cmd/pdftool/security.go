@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/pkg/pdfops"
+)
+
+func newProtectCommand() *command {
+	userPassword := ""
+	ownerPassword := ""
+
+	return &command{
+		name:  "protect",
+		args:  "input.pdf",
+		short: "Protect a PDF file with a user and owner password",
+		setFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&userPassword, "user-password", "",
+				"Password required to view the file (empty: anyone can view it with restricted permissions)")
+			fs.StringVar(&ownerPassword, "owner-password", "", "Password required for full access to the file")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 1); err != nil {
+				return err
+			}
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+			if ownerPassword == "" {
+				return newUsageError("--owner-password is required")
+			}
+
+			return pdfops.ProtectPdf(args[0], outputPath, userPassword, ownerPassword,
+				pdfops.DefaultPermissions(), g.pdfopsOptions())
+		},
+	}
+}
+
+func newUnlockCommand() *command {
+	return &command{
+		name:  "unlock",
+		args:  "input.pdf",
+		short: "Decrypt a PDF file with the given password and write an unprotected copy",
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 1); err != nil {
+				return err
+			}
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+
+			return pdfops.UnlockPdf(args[0], outputPath, g.pdfopsOptions())
+		},
+	}
+}
+
+func newSecurityInfoCommand() *command {
+	format := formatText
+
+	return &command{
+		name:  "secinfo",
+		args:  "input.pdf [input2.pdf] ...",
+		short: "Print protection information about PDF files",
+		setFlags: func(fs *flag.FlagSet) {
+			addFormatFlag(fs, &format)
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 1); err != nil {
+				return err
+			}
+			if err := checkFormat(format); err != nil {
+				return err
+			}
+
+			results := []*pdfops.SecurityInfo{}
+			for _, inputPath := range args {
+				info, err := pdfops.GetSecurityInfo(inputPath, g.pdfopsOptions())
+				if err != nil {
+					return err
+				}
+				results = append(results, info)
+			}
+
+			if format != formatText {
+				return writeReport(os.Stdout, format, "secinfo", results)
+			}
+
+			for _, info := range results {
+				fmt.Printf("Input file %s\n", info.Path)
+				if !info.IsEncrypted {
+					fmt.Printf(" - is not encrypted\n")
+					continue
+				}
+				if info.HasOpenPassword {
+					fmt.Printf(" - has an opening password\n")
+				}
+				fmt.Printf(" - Method: %s\n", info.Method)
+				printPermissions(info.Permissions)
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/unidoc/unidoc-examples/pkg/pdfops"
+)
+
+func newStampCommand() *command {
+	pagesExpr := ""
+	color := "black"
+	position := "bottom"
+	margin := 36.0
+	stampOpts := pdfops.TextStampOptions{}
+
+	return &command{
+		name:  "stamp",
+		args:  "input.pdf text",
+		short: "Add text such as page numbers, headers and footers to pages",
+		setFlags: func(fs *flag.FlagSet) {
+			addPagesFlag(fs, &pagesExpr)
+			fs.StringVar(&stampOpts.Font, "font", pdfops.DefaultStampFont,
+				"TrueType font file (.ttf) or name of a standard 14 font")
+			fs.Float64Var(&stampOpts.FontSize, "size", 12, "Font size")
+			fs.StringVar(&color, "color", color, "Text color, #rrggbb or a name")
+			fs.Float64Var(&stampOpts.Opacity, "opacity", 1, "Opacity of the text, 0 - 1")
+			fs.Float64Var(&stampOpts.Angle, "angle", 0, "Counterclockwise rotation of the text in degrees")
+			fs.StringVar(&position, "position", position,
+				"Position of the text: top-left, top, top-right, left, center, right, bottom-left, bottom or bottom-right")
+			fs.Float64Var(&margin, "margin", margin, "Distance of the text from the edges of the page")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 2); err != nil {
+				return err
+			}
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+			if stampOpts.Pages, err = parsePages(pagesExpr); err != nil {
+				return err
+			}
+			if stampOpts.Color, err = pdfops.ParseRGB(color); err != nil {
+				return newUsageError("%v", err)
+			}
+			if stampOpts.Position, err = pdfops.ParsePosition(position); err != nil {
+				return newUsageError("%v", err)
+			}
+			if stampOpts.Opacity <= 0 || stampOpts.Opacity > 1 {
+				return newUsageError("-opacity must be greater than 0 and at most 1")
+			}
+			stampOpts.MarginX, stampOpts.MarginY = margin, margin
+			// The shell doesn't make it easy to pass line breaks.
+			stampOpts.Text = strings.Replace(args[1], `\n`, "\n", -1)
+
+			return pdfops.StampTextPdf(args[0], outputPath, stampOpts, g.pdfopsOptions())
+		},
+	}
+}
+
+func newBatesCommand() *command {
+	color := "black"
+	position := "bottom-right"
+	margin := 36.0
+	logPath := ""
+	outputDir := ""
+	batesOpts := pdfops.BatesOptions{}
+
+	return &command{
+		name:  "bates",
+		args:  "input1.pdf input2.pdf ...",
+		short: "Stamp Bates numbers that continue across files and log the range of each file as CSV",
+		setFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&batesOpts.Prefix, "prefix", "", "Text before the number")
+			fs.StringVar(&batesOpts.Suffix, "suffix", "", "Text after the number")
+			fs.IntVar(&batesOpts.Digits, "digits", 6, "Number of digits, the number is zero padded")
+			fs.IntVar(&batesOpts.Start, "start", 1, "First number")
+			fs.StringVar(&batesOpts.Stamp.Text, "text", pdfops.DefaultBatesTemplate,
+				"Stamp text with {bates} for the number, see the stamp command for other placeholders")
+			fs.StringVar(&batesOpts.Stamp.Font, "font", pdfops.DefaultStampFont,
+				"TrueType font file (.ttf) or name of a standard 14 font")
+			fs.Float64Var(&batesOpts.Stamp.FontSize, "size", 10, "Font size")
+			fs.StringVar(&color, "color", color, "Text color, #rrggbb or a name")
+			fs.StringVar(&position, "position", position,
+				"Position of the number: top-left, top, top-right, left, center, right, bottom-left, bottom or bottom-right")
+			fs.Float64Var(&margin, "margin", margin, "Distance of the number from the edges of the page")
+			fs.StringVar(&logPath, "log", "", "CSV file for the number range of each file (default stdout)")
+			fs.StringVar(&outputDir, "out-dir", "", "Directory the numbered files are written to (required)")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 1); err != nil {
+				return err
+			}
+			// Every input gives an output file, so they go to a directory rather than the --out file.
+			if g.out != "" {
+				return newUsageError("bates writes one file per input, use -out-dir instead of --out")
+			}
+			if outputDir == "" {
+				return newUsageError("-out-dir is required")
+			}
+			if fi, err := os.Stat(outputDir); err == nil && !fi.IsDir() {
+				return newUsageError("-out-dir %s is a file", outputDir)
+			}
+
+			var err error
+			if batesOpts.Stamp.Color, err = pdfops.ParseRGB(color); err != nil {
+				return newUsageError("%v", err)
+			}
+			if batesOpts.Stamp.Position, err = pdfops.ParsePosition(position); err != nil {
+				return newUsageError("%v", err)
+			}
+			if batesOpts.Start < 0 || batesOpts.Digits < 1 {
+				return newUsageError("-start must not be negative and -digits must be at least 1")
+			}
+			batesOpts.Stamp.MarginX, batesOpts.Stamp.MarginY = margin, margin
+
+			// The numbered files keep their names, so they must be distinct.
+			outputPaths := []string{}
+			seen := map[string]string{}
+			for _, inputPath := range args {
+				name := filepath.Base(inputPath)
+				if other, ok := seen[name]; ok {
+					return newUsageError("%s and %s would both be written to %s", other, inputPath, name)
+				}
+				seen[name] = inputPath
+				outputPaths = append(outputPaths, filepath.Join(outputDir, name))
+			}
+			// Inputs are read while the outputs are written, so no output may overwrite an input.
+			for _, outputPath := range outputPaths {
+				out, err := os.Stat(outputPath)
+				if err != nil {
+					continue
+				}
+				for _, inputPath := range args {
+					if in, err := os.Stat(inputPath); err == nil && os.SameFile(in, out) {
+						return newUsageError("%s would be overwritten, choose another -out-dir", inputPath)
+					}
+				}
+			}
+			if err := os.MkdirAll(outputDir, 0755); err != nil {
+				return err
+			}
+
+			ranges, err := pdfops.BatesNumberPdfs(args, outputPaths, batesOpts, g.pdfopsOptions())
+			if err != nil {
+				return err
+			}
+
+			if logPath == "" {
+				return pdfops.WriteBatesLog(os.Stdout, ranges)
+			}
+			f, err := os.Create(logPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return pdfops.WriteBatesLog(f, ranges)
+		},
+	}
+}
+
+func newWatermarkCommand() *command {
+	pagesExpr := ""
+	text := ""
+	font := pdfops.DefaultStampFont
+	size := 48.0
+	color := "gray"
+	angle := 45.0
+	tile := false
+	spacing := 0.0
+	layout := "width"
+	position := "center"
+	margin := 0.0
+	scale := 1.0
+	opacity := 0.5
+	behind := false
+	layer := pdfops.Layer{}
+
+	return &command{
+		name:  "watermark",
+		args:  "input.pdf [watermark.jpg]",
+		short: "Add a watermark image or text to pages",
+		setFlags: func(fs *flag.FlagSet) {
+			addPagesFlag(fs, &pagesExpr)
+			fs.StringVar(&text, "text", "", "Watermark text instead of an image, see the stamp command for placeholders")
+			fs.StringVar(&font, "font", font, "Font of the text: TrueType font file (.ttf) or name of a standard 14 font")
+			fs.Float64Var(&size, "size", size, "Font size of the text")
+			fs.StringVar(&color, "color", color, "Color of the text, #rrggbb or a name")
+			fs.Float64Var(&angle, "angle", angle, "Counterclockwise rotation of the text in degrees")
+			fs.BoolVar(&tile, "tile", false, "Repeat the text all over the page")
+			fs.Float64Var(&spacing, "spacing", 0, "Gap between repeated texts (default twice the font size)")
+			fs.StringVar(&layout, "layout", layout, "Placement of the image: width, fit, place or tile")
+			fs.StringVar(&position, "position", position,
+				"Position of the text or, with -layout place, the image: top-left, top, ..., center, ..., bottom-right")
+			fs.Float64Var(&margin, "margin", margin, "Distance from the edges of the page")
+			fs.Float64Var(&scale, "scale", scale, "Size of the image with -layout place or tile, 1 is one point per pixel")
+			fs.Float64Var(&opacity, "opacity", opacity, "Opacity of the watermark, 0 - 1")
+			fs.BoolVar(&behind, "behind", false, "Draw the watermark behind the page contents")
+			fs.StringVar(&layer.Name, "layer", "", "Put the watermark in an optional content layer with this name")
+			fs.BoolVar(&layer.HideOnScreen, "hide-on-screen", false, "Hide the -layer when the document is viewed")
+			fs.BoolVar(&layer.HideInPrint, "hide-in-print", false, "Hide the -layer when the document is printed")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if text == "" {
+				if err := checkArgs(args, 2); err != nil {
+					return err
+				}
+			} else if len(args) != 1 {
+				return newUsageError("requires exactly one argument with -text, got %d", len(args))
+			}
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+			pages, err := parsePages(pagesExpr)
+			if err != nil {
+				return err
+			}
+			pos, err := pdfops.ParsePosition(position)
+			if err != nil {
+				return newUsageError("%v", err)
+			}
+			if opacity <= 0 || opacity > 1 {
+				return newUsageError("-opacity must be greater than 0 and at most 1")
+			}
+			var wmLayer *pdfops.Layer
+			if layer.Name != "" {
+				wmLayer = &layer
+			} else if layer.HideOnScreen || layer.HideInPrint {
+				return newUsageError("-hide-on-screen and -hide-in-print require -layer")
+			}
+
+			if text != "" {
+				stampOpts := pdfops.TextStampOptions{
+					Text:     strings.Replace(text, `\n`, "\n", -1),
+					Font:     font,
+					FontSize: size,
+					Opacity:  opacity,
+					Angle:    angle,
+					Position: pos,
+					MarginX:  margin,
+					MarginY:  margin,
+					Tile:     tile,
+					Spacing:  spacing,
+					Behind:   behind,
+					Layer:    wmLayer,
+					Pages:    pages,
+				}
+				if stampOpts.Color, err = pdfops.ParseRGB(color); err != nil {
+					return newUsageError("%v", err)
+				}
+				return pdfops.StampTextPdf(args[0], outputPath, stampOpts, g.pdfopsOptions())
+			}
+
+			wmOpts := pdfops.ImageWatermarkOptions{
+				Position: pos,
+				Margin:   margin,
+				Scale:    scale,
+				Opacity:  opacity,
+				Behind:   behind,
+				Layer:    wmLayer,
+				Pages:    pages,
+			}
+			if wmOpts.Layout, err = pdfops.ParseImageLayout(layout); err != nil {
+				return newUsageError("%v", err)
+			}
+			if scale <= 0 {
+				return newUsageError("-scale must be greater than 0")
+			}
+			return pdfops.WatermarkImagePdf(args[0], outputPath, args[1], wmOpts, g.pdfopsOptions())
+		},
+	}
+}
+
+func newStripLayerCommand() *command {
+	return &command{
+		name:  "striplayer",
+		args:  "input.pdf <layer>",
+		short: "Remove an optional content layer, such as a watermark added with -layer, and its content",
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 2); err != nil {
+				return err
+			}
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+
+			numPages, err := pdfops.RemoveLayerPdf(args[0], outputPath, args[1], g.pdfopsOptions())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Removed layer %q from %d page(s)\n", args[1], numPages)
+			return nil
+		},
+	}
+}
+
+func newBarcodeCommand() *command {
+	pagesExpr := ""
+	xPos := 0.0
+	yPos := 0.0
+	width := 100.0
+
+	return &command{
+		name:  "barcode",
+		args:  "input.pdf <code>",
+		short: "Add an EAN-8 or EAN-13 barcode to pages",
+		setFlags: func(fs *flag.FlagSet) {
+			addPagesFlag(fs, &pagesExpr)
+			fs.Float64Var(&xPos, "x", 0, "Distance of the barcode from the left edge of the page")
+			fs.Float64Var(&yPos, "y", 0, "Distance of the barcode from the top edge of the page")
+			fs.Float64Var(&width, "width", 100, "Width of the barcode")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 2); err != nil {
+				return err
+			}
+			outputPath, err := g.requireOut()
+			if err != nil {
+				return err
+			}
+			pages, err := parsePages(pagesExpr)
+			if err != nil {
+				return err
+			}
+
+			return pdfops.AddBarcodePdf(args[0], outputPath, args[1], pages, xPos, yPos, width, g.pdfopsOptions())
+		},
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/unidoc/unidoc-examples/pkg/pdfops"
+)
+
+// Text extraction modes.
+const (
+	textModePlain   = "plain"
+	textModeRuns    = "runs"
+	textModeReading = "reading"
+)
+
+// formatTSV is the tab separated output format of the text runs.
+const formatTSV = "tsv"
+
+func newTextCommand() *command {
+	pagesExpr := ""
+	mode := textModePlain
+	format := formatText
+
+	return &command{
+		name:  "text",
+		args:  "input.pdf",
+		short: "Extract the text of each page (to --out or stdout)",
+		setFlags: func(fs *flag.FlagSet) {
+			addPagesFlag(fs, &pagesExpr)
+			fs.StringVar(&mode, "mode", textModePlain,
+				"plain: the text of each page, runs: each text run with its position, font and color, "+
+					"reading: the text in reading order, reconstructing lines and columns")
+			fs.StringVar(&format, "format", formatText,
+				"Output format: text, json or yaml; tsv for runs (text is the same as tsv there)")
+		},
+		run: func(g *globalOptions, args []string) error {
+			if err := checkArgs(args, 1); err != nil {
+				return err
+			}
+			pages, err := parsePages(pagesExpr)
+			if err != nil {
+				return err
+			}
+			if format != formatTSV {
+				if err := checkFormat(format); err != nil {
+					return err
+				}
+			}
+			switch {
+			case mode != textModePlain && mode != textModeRuns && mode != textModeReading:
+				return newUsageError("invalid mode %q", mode)
+			case mode == textModePlain && format != formatText:
+				return newUsageError("-mode plain only supports the text format")
+			case mode == textModeReading && format == formatTSV:
+				return newUsageError("-mode reading does not support the tsv format")
+			}
+
+			w := os.Stdout
+			if g.out != "" {
+				f, err := os.Create(g.out)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+
+			if mode == textModePlain {
+				return pdfops.OutputPdfText(args[0], w, pages, g.pdfopsOptions())
+			}
+
+			runs, err := pdfops.ExtractTextRuns(args[0], pages, g.pdfopsOptions())
+			if err != nil {
+				return err
+			}
+
+			if mode == textModeRuns {
+				if format == formatText || format == formatTSV {
+					return writeRunsTSV(w, runs)
+				}
+				return writeReport(w, format, "text", runs)
+			}
+
+			blocks := pdfops.ReadingOrder(runs)
+			if format == formatText {
+				_, err := io.WriteString(w, pdfops.ReadingOrderText(blocks))
+				return err
+			}
+			return writeReport(w, format, "text", blocks)
+		},
+	}
+}
+
+// writeRunsTSV writes `runs` to `w` as tab separated values with a header line.  Tabs, newlines and backslashes
+// in the text are escaped as \t, \n and \\.
+func writeRunsTSV(w io.Writer, runs []*pdfops.TextRun) error {
+	escaper := strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+	if _, err := fmt.Fprintln(w, "page\tllx\tlly\turx\tury\tfont\tsize\tcolor\ttext"); err != nil {
+		return err
+	}
+	for _, run := range runs {
+		_, err := fmt.Fprintf(w, "%d\t%.2f\t%.2f\t%.2f\t%.2f\t%s\t%.2f\t%s\t%s\n", run.Page,
+			run.BBox[0], run.BBox[1], run.BBox[2], run.BBox[3], run.Font, run.FontSize, run.Color,
+			escaper.Replace(run.Text))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+/*
+ * Package opener opens PDF files for reading, taking care of decryption and of repairing common damage.
+ *
+ * Encrypted documents are decrypted with the owner password, the user password and finally the empty password,
+ * in that order, and the password that authenticated is reported back.  A password is only reported as the owner
+ * password if it grants all access rights, so a user password given as the owner password is reported as such.
+ * Failures are reported as ErrWrongPassword when the document could be parsed but none of the passwords were
+ * accepted, and as a *CorruptFileError when the file could not be parsed as a PDF at all.
+ */
+
+package opener
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+)
+
+// Environment variables that passwords are read from by PasswordsFromEnv.
+const (
+	EnvPassword      = "PDFTOOL_PASSWORD"
+	EnvOwnerPassword = "PDFTOOL_OWNER_PASSWORD"
+)
+
+// ErrWrongPassword is returned when an encrypted document cannot be decrypted with any of the given passwords.
+var ErrWrongPassword = errors.New("wrong password")
+
+// CorruptFileError is returned when a file cannot be parsed as a PDF document.
+type CorruptFileError struct {
+	Path string
+	Err  error
+}
+
+func (e *CorruptFileError) Error() string {
+	return fmt.Sprintf("%s: corrupt or invalid PDF file: %v", e.Path, e.Err)
+}
+
+func (e *CorruptFileError) Unwrap() error {
+	return e.Err
+}
+
+// AuthMethod tells how access to a document was obtained.
+type AuthMethod int
+
+const (
+	AuthNotEncrypted  AuthMethod = iota // The document is not encrypted.
+	AuthEmptyPassword                   // Decrypted with the empty user password.
+	AuthUserPassword                    // Decrypted with the user password.
+	AuthOwnerPassword                   // Decrypted with the owner password.
+)
+
+func (m AuthMethod) String() string {
+	switch m {
+	case AuthNotEncrypted:
+		return "not encrypted"
+	case AuthEmptyPassword:
+		return "empty password"
+	case AuthUserPassword:
+		return "user password"
+	case AuthOwnerPassword:
+		return "owner password"
+	}
+	return fmt.Sprintf("AuthMethod(%d)", int(m))
+}
+
+// MarshalText implements encoding.TextMarshaler, so that AuthMethod is represented by its name in structured
+// output.
+func (m AuthMethod) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// Options specifies how documents are opened.
+type Options struct {
+	UserPassword  string
+	OwnerPassword string
+
+	// Repair enables repairing files that cannot be parsed as they are, see repairPdfData.
+	Repair bool
+}
+
+// Document is an opened PDF document.
+type Document struct {
+	Path     string
+	Reader   *pdf.PdfReader
+	Auth     AuthMethod
+	Password string // The password that was accepted, "" if the document is not encrypted or by the empty one.
+	Repaired bool   // Was the file repaired in order to be read?
+
+	file *os.File
+}
+
+// Close releases the file underlying the document.
+func (d *Document) Close() error {
+	if d.file == nil {
+		return nil
+	}
+	return d.file.Close()
+}
+
+// Open opens the PDF file `path` and decrypts it if needed.  The document needs to be closed by the caller once
+// its reader is no longer used.
+func Open(path string, opts Options) (*Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{Path: path, file: f}
+
+	pdfReader, err := pdf.NewPdfReader(f)
+	if err != nil {
+		if !opts.Repair {
+			f.Close()
+			return nil, &CorruptFileError{Path: path, Err: err}
+		}
+
+		pdfReader, err = openRepaired(f)
+		f.Close()
+		doc.file = nil
+		if err != nil {
+			return nil, &CorruptFileError{Path: path, Err: err}
+		}
+		doc.Repaired = true
+		unicommon.Log.Info("%s: repaired damaged file", path)
+	}
+	doc.Reader = pdfReader
+
+	auth, password, err := decrypt(pdfReader, opts)
+	if err != nil {
+		doc.Close()
+		if err == ErrWrongPassword {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return nil, &CorruptFileError{Path: path, Err: err}
+	}
+	doc.Auth = auth
+	doc.Password = password
+	unicommon.Log.Info("%s: opened (%s)", path, auth)
+
+	return doc, nil
+}
+
+// IsWrongPassword returns true if `err` was caused by a document that could not be decrypted.
+func IsWrongPassword(err error) bool {
+	return errors.Is(err, ErrWrongPassword)
+}
+
+// IsCorrupt returns true if `err` was caused by a file that could not be parsed.
+func IsCorrupt(err error) bool {
+	var cerr *CorruptFileError
+	return errors.As(err, &cerr)
+}
+
+// decrypt authenticates `pdfReader` if it is encrypted, trying the passwords in `opts` from the most to the least
+// privileged one.  Returns how access was obtained and the password that was accepted.
+func decrypt(pdfReader *pdf.PdfReader, opts Options) (AuthMethod, string, error) {
+	isEncrypted, err := pdfReader.IsEncrypted()
+	if err != nil {
+		return AuthNotEncrypted, "", err
+	}
+	if !isEncrypted {
+		return AuthNotEncrypted, "", nil
+	}
+
+	candidates := []struct {
+		password string
+		method   AuthMethod
+	}{
+		{opts.OwnerPassword, AuthOwnerPassword},
+		{opts.UserPassword, AuthUserPassword},
+		{"", AuthEmptyPassword},
+	}
+
+	for _, c := range candidates {
+		if c.password == "" && c.method != AuthEmptyPassword {
+			continue
+		}
+
+		auth, err := pdfReader.Decrypt([]byte(c.password))
+		if err != nil {
+			return AuthNotEncrypted, "", err
+		}
+		if !auth {
+			continue
+		}
+
+		// Decrypt accepts the user password as the owner password too.  Only the owner password grants all rights.
+		if c.method == AuthOwnerPassword {
+			_, perms, err := pdfReader.CheckAccessRights([]byte(c.password))
+			if err != nil {
+				return AuthNotEncrypted, "", err
+			}
+			if !fullAccess(perms) {
+				return AuthUserPassword, c.password, nil
+			}
+		}
+		return c.method, c.password, nil
+	}
+
+	return AuthNotEncrypted, "", ErrWrongPassword
+}
+
+// fullAccess returns true if `perms` grant every right, as the owner password does.
+func fullAccess(perms pdfcore.AccessPermissions) bool {
+	return perms.Printing && perms.FullPrintQuality && perms.Modify && perms.Annotate && perms.FillForms &&
+		perms.RotateInsert && perms.ExtractGraphics && perms.DisabilityExtract
+}
+
+// openRepaired reads the contents of `f`, repairs them and returns a reader for the repaired data.
+func openRepaired(f *os.File) (*pdf.PdfReader, error) {
+	_, err := f.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	repaired, changed := repairPdfData(data)
+	if !changed {
+		return nil, errors.New("Unable to repair file")
+	}
+
+	return pdf.NewPdfReader(bytes.NewReader(repaired))
+}
+
+// repairPdfData fixes the kinds of damage commonly introduced when PDF files are transferred: junk before the
+// %PDF- header (e.g. mail or HTTP headers) and trailing data after the last %%EOF marker.  The parser itself
+// reconstructs broken cross reference tables, but relies on the header being at offset 0 and the trailer at the
+// end of the file.
+// Returns the repaired data and whether anything was changed.
+func repairPdfData(data []byte) ([]byte, bool) {
+	changed := false
+
+	start := bytes.Index(data, []byte("%PDF-"))
+	if start > 0 {
+		data = data[start:]
+		changed = true
+	}
+
+	end := bytes.LastIndex(data, []byte("%%EOF"))
+	if end >= 0 {
+		end += len("%%EOF")
+		if end < len(data) && len(bytes.TrimSpace(data[end:])) > 0 {
+			data = append(data[:end:end], '\n')
+			changed = true
+		}
+	} else {
+		data = append(data[:len(data):len(data)], []byte("\n%%EOF\n")...)
+		changed = true
+	}
+
+	return data, changed
+}
+
+// PasswordsFromEnv returns the user and owner passwords set in the EnvPassword and EnvOwnerPassword environment
+// variables.
+func PasswordsFromEnv() (userPassword, ownerPassword string) {
+	return os.Getenv(EnvPassword), os.Getenv(EnvOwnerPassword)
+}
+
+// ReadPasswordFile reads the passwords in file `path`.  The first line holds the user password and the optional
+// second line the owner password.  Trailing line endings are not part of the passwords.
+func ReadPasswordFile(path string) (userPassword, ownerPassword string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() && len(lines) < 2 {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+
+	if len(lines) > 0 {
+		userPassword = lines[0]
+	}
+	if len(lines) > 1 {
+		ownerPassword = lines[1]
+	}
+
+	return userPassword, ownerPassword, nil
+}
@@ -0,0 +1,309 @@
+/*
+ * Package pagerange implements the page selection expressions accepted by the page-oriented pdftool commands.
+ *
+ * An expression is a comma separated list of terms.  Each term selects a set of pages:
+ *   7         a single page
+ *   1-3       a range of pages (inclusive), "end" can be used for the last page, e.g. 10-end
+ *   odd, even odd or even pages
+ *   last      the last page
+ *   all       all pages
+ * A term can be restricted by a qualifier following a colon: landscape, portrait, odd or even, e.g. 1-end:landscape.
+ * Terms prefixed with ! exclude pages, e.g. 1-10,!5 or !last.  If an expression only consists of exclusions, they
+ * apply to all pages.
+ *
+ * Selected pages are always returned in ascending order without duplicates.
+ */
+
+package pagerange
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	pdf "github.com/unidoc/unidoc/pdf/model"
+)
+
+// qualifier restricts the pages selected by a term.
+type qualifier int
+
+const (
+	qualNone qualifier = iota
+	qualLandscape
+	qualPortrait
+	qualOdd
+	qualEven
+)
+
+var qualifierNames = map[string]qualifier{
+	"landscape": qualLandscape,
+	"portrait":  qualPortrait,
+	"odd":       qualOdd,
+	"even":      qualEven,
+}
+
+// Page numbers with a special meaning in a term.  Resolved once the number of pages is known.
+const lastPage = -1
+
+// term is a single element of a selection expression.
+type term struct {
+	exclude bool
+	from    int // First page, or lastPage.
+	to      int // Last page, or lastPage.
+	step    int // 1 for ranges, 2 for odd/even.
+	qual    qualifier
+}
+
+// Selection is a parsed page selection expression.  A nil Selection selects all pages.
+type Selection struct {
+	expr  string
+	terms []term
+}
+
+// Parse parses page selection expression `expr`.  The empty expression selects all pages.
+func Parse(expr string) (*Selection, error) {
+	sel := &Selection{expr: expr}
+
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		t, err := parseTerm(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page selection %q: %v", part, err)
+		}
+		sel.terms = append(sel.terms, t)
+	}
+
+	return sel, nil
+}
+
+// MustParse is like Parse but panics if `expr` is invalid.  Intended for constant expressions.
+func MustParse(expr string) *Selection {
+	sel, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return sel
+}
+
+func (s *Selection) String() string {
+	if s == nil || s.expr == "" {
+		return "all"
+	}
+	return s.expr
+}
+
+func parseTerm(str string) (term, error) {
+	t := term{step: 1}
+
+	if strings.HasPrefix(str, "!") {
+		t.exclude = true
+		str = strings.TrimSpace(str[1:])
+	}
+
+	if i := strings.Index(str, ":"); i >= 0 {
+		q, ok := qualifierNames[strings.ToLower(strings.TrimSpace(str[i+1:]))]
+		if !ok {
+			return t, fmt.Errorf("unknown qualifier %q", str[i+1:])
+		}
+		t.qual = q
+		str = strings.TrimSpace(str[:i])
+	}
+
+	switch strings.ToLower(str) {
+	case "all":
+		t.from, t.to = 1, lastPage
+		return t, nil
+	case "odd":
+		t.from, t.to, t.step = 1, lastPage, 2
+		return t, nil
+	case "even":
+		t.from, t.to, t.step = 2, lastPage, 2
+		return t, nil
+	case "last", "end":
+		t.from, t.to = lastPage, lastPage
+		return t, nil
+	}
+
+	var err error
+	if i := strings.Index(str, "-"); i >= 0 {
+		t.from, err = parsePageNum(str[:i])
+		if err != nil {
+			return t, err
+		}
+		t.to, err = parsePageNum(str[i+1:])
+		if err != nil {
+			return t, err
+		}
+		if t.to != lastPage && (t.from == lastPage || t.from > t.to) {
+			return t, fmt.Errorf("range start after range end")
+		}
+		return t, nil
+	}
+
+	t.from, err = parsePageNum(str)
+	t.to = t.from
+	return t, err
+}
+
+func parsePageNum(str string) (int, error) {
+	str = strings.TrimSpace(str)
+	switch strings.ToLower(str) {
+	case "end", "last":
+		return lastPage, nil
+	}
+
+	n, err := strconv.Atoi(str)
+	if err != nil {
+		return 0, fmt.Errorf("invalid page number %q", str)
+	}
+	if n < 1 {
+		return 0, fmt.Errorf("page numbers start at 1 (got %d)", n)
+	}
+	return n, nil
+}
+
+// NeedsOrientation returns true if the selection depends on the orientation of the pages.
+func (s *Selection) NeedsOrientation() bool {
+	if s == nil {
+		return false
+	}
+	for _, t := range s.terms {
+		if t.qual == qualLandscape || t.qual == qualPortrait {
+			return true
+		}
+	}
+	return false
+}
+
+// OrientationFunc returns true if page `pageNum` is in landscape orientation.
+type OrientationFunc func(pageNum int) (bool, error)
+
+// Pages returns the page numbers selected in a document of `numPages` pages.  `isLandscape` is only called for
+// terms with a landscape or portrait qualifier, and may be nil if NeedsOrientation is false.
+// Pages outside the document are ignored.
+func (s *Selection) Pages(numPages int, isLandscape OrientationFunc) ([]int, error) {
+	selected := map[int]bool{}
+	excluded := map[int]bool{}
+
+	hasIncludes := false
+	if s != nil {
+		for _, t := range s.terms {
+			if !t.exclude {
+				hasIncludes = true
+			}
+		}
+	}
+	if !hasIncludes {
+		for i := 1; i <= numPages; i++ {
+			selected[i] = true
+		}
+	}
+
+	if s != nil {
+		for _, t := range s.terms {
+			target := selected
+			if t.exclude {
+				target = excluded
+			}
+
+			from, to := resolve(t.from, numPages), resolve(t.to, numPages)
+			for i := from; i <= to && i <= numPages; i += t.step {
+				match, err := t.matches(i, isLandscape)
+				if err != nil {
+					return nil, err
+				}
+				if match {
+					target[i] = true
+				}
+			}
+		}
+	}
+
+	pages := []int{}
+	for i := range selected {
+		if !excluded[i] {
+			pages = append(pages, i)
+		}
+	}
+	sort.Ints(pages)
+
+	return pages, nil
+}
+
+// Select returns the page numbers of `pdfReader` that are selected.
+func (s *Selection) Select(pdfReader *pdf.PdfReader) ([]int, error) {
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Pages(numPages, func(pageNum int) (bool, error) {
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return false, err
+		}
+		return IsLandscape(page)
+	})
+}
+
+// SelectSet is like Select but returns the selected pages as a set.
+func (s *Selection) SelectSet(pdfReader *pdf.PdfReader) (map[int]bool, error) {
+	pages, err := s.Select(pdfReader)
+	if err != nil {
+		return nil, err
+	}
+
+	set := map[int]bool{}
+	for _, pageNum := range pages {
+		set[pageNum] = true
+	}
+	return set, nil
+}
+
+// IsLandscape returns true if `page` is wider than it is high as displayed, i.e. taking its Rotate entry
+// into account.
+func IsLandscape(page *pdf.PdfPage) (bool, error) {
+	mbox, err := page.GetMediaBox()
+	if err != nil {
+		return false, err
+	}
+
+	width := mbox.Urx - mbox.Llx
+	height := mbox.Ury - mbox.Lly
+	if page.Rotate != nil && (*page.Rotate/90)%2 != 0 {
+		width, height = height, width
+	}
+
+	return width > height, nil
+}
+
+func resolve(pageNum, numPages int) int {
+	if pageNum == lastPage {
+		return numPages
+	}
+	return pageNum
+}
+
+func (t term) matches(pageNum int, isLandscape OrientationFunc) (bool, error) {
+	switch t.qual {
+	case qualOdd:
+		return pageNum%2 == 1, nil
+	case qualEven:
+		return pageNum%2 == 0, nil
+	case qualLandscape, qualPortrait:
+		if isLandscape == nil {
+			return false, fmt.Errorf("page orientation is not available")
+		}
+		landscape, err := isLandscape(pageNum)
+		if err != nil {
+			return false, err
+		}
+		return landscape == (t.qual == qualLandscape), nil
+	}
+	return true, nil
+}
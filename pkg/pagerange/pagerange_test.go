@@ -0,0 +1,79 @@
+package pagerange
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPages(t *testing.T) {
+	// Pages 2 and 3 are in landscape orientation.
+	isLandscape := func(pageNum int) (bool, error) {
+		return pageNum == 2 || pageNum == 3, nil
+	}
+
+	tests := []struct {
+		expr     string
+		numPages int
+		want     []int
+	}{
+		{"", 3, []int{1, 2, 3}},
+		{"all", 3, []int{1, 2, 3}},
+		{"7", 10, []int{7}},
+		{"3, 1, 3", 5, []int{1, 3}},
+		{"1-3,10-end", 12, []int{1, 2, 3, 10, 11, 12}},
+		{"8-20", 10, []int{8, 9, 10}},
+		{"12", 10, []int{}},
+		{"odd", 5, []int{1, 3, 5}},
+		{"even", 5, []int{2, 4}},
+		{"last", 4, []int{4}},
+		{"end", 4, []int{4}},
+		{"2-last", 4, []int{2, 3, 4}},
+		{"1-10,!5", 10, []int{1, 2, 3, 4, 6, 7, 8, 9, 10}},
+		{"!5", 6, []int{1, 2, 3, 4, 6}},
+		{"!last", 3, []int{1, 2}},
+		{"!1, !even", 6, []int{3, 5}},
+		{"1-end:even", 6, []int{2, 4, 6}},
+		{"odd:EVEN", 6, []int{}},
+		{"1-end:landscape", 5, []int{2, 3}},
+		{"all:portrait", 5, []int{1, 4, 5}},
+		{"!3:landscape", 4, []int{1, 2, 4}},
+	}
+
+	for _, test := range tests {
+		sel, err := Parse(test.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.expr, err)
+			continue
+		}
+		got, err := sel.Pages(test.numPages, isLandscape)
+		if err != nil {
+			t.Errorf("%q: %v", test.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q of %d pages: got %v, want %v", test.expr, test.numPages, got, test.want)
+		}
+	}
+}
+
+func TestNilSelection(t *testing.T) {
+	var sel *Selection
+	got, err := sel.Pages(3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if sel.String() != "all" || sel.NeedsOrientation() {
+		t.Errorf("nil selection is not all pages")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"0", "x", "3-1", "end-1", "last-2", "-3", "1-", "2:sideways", "1,,0"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package pdfops
+
+/*
+ * NOTE: The barcode operations depend on github.com/boombuler/barcode, MIT licensed.
+ */
+
+import (
+	"fmt"
+	goimage "image"
+	"math"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/ean"
+
+	"github.com/unidoc/unidoc/pdf/creator"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// Prepare the barcode. The oversampling ratio specifies how many pixels/point to use.  The default resolution of
+// PDFs is 72PPI (points per inch). A higher PPI allows higher resolution QR code generation which is particularly
+// important if the document is scaled (zoom in).
+func makeBarcode(codeStr string, width float64, oversampling int) (goimage.Image, error) {
+	bcode, err := ean.Encode(codeStr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prepare the code image.
+	pixelWidth := oversampling * int(math.Ceil(width))
+	bcodeImg, err := barcode.Scale(bcode, pixelWidth, pixelWidth)
+	if err != nil {
+		return nil, err
+	}
+
+	return bcodeImg, err
+}
+
+// AddBarcodePdf adds an EAN barcode for `codeStr` to the pages of `inputPath` selected by `pages` and writes the
+// result to `outputPath`.  xPos and yPos define the upper left corner of the image location relative to the upper
+// left corner of the page, and width is the width of the image in PDF coordinates (height/width ratio is maintained).
+func AddBarcodePdf(inputPath string, outputPath string, codeStr string, pages *pagerange.Selection,
+	xPos float64, yPos float64, width float64, opts Options) error {
+	allowedLengths := map[int]bool{7: true, 8: true, 12: true, 13: true}
+	if _, ok := allowedLengths[len(codeStr)]; !ok {
+		return fmt.Errorf("Code must be 7 or 8 characters long (EAN-8) or 12 or 13 characters long (EAN-13) " +
+			"without or with checksum")
+	}
+
+	bcodeImg, err := makeBarcode(codeStr, width, 5)
+	if err != nil {
+		return err
+	}
+
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return err
+	}
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return err
+	}
+
+	selected, err := pages.SelectSet(pdfReader)
+	if err != nil {
+		return err
+	}
+
+	// Make a new PDF creator.
+	c := creator.New()
+
+	// Load the pages and add to creator.  Apply the barcode to the selected pages.
+	for i := 0; i < numPages; i++ {
+		page, err := pdfReader.GetPage(i + 1)
+		if err != nil {
+			return err
+		}
+
+		err = c.AddPage(page)
+		if err != nil {
+			return err
+		}
+
+		if !selected[i+1] {
+			continue
+		}
+
+		img, err := creator.NewImageFromGoImage(bcodeImg)
+		if err != nil {
+			return err
+		}
+		img.ScaleToWidth(width)
+		img.SetPos(xPos, yPos)
+		err = c.Draw(img)
+		if err != nil {
+			return err
+		}
+
+		// Add the code below.
+		p := creator.NewParagraph(codeStr)
+		p.SetWidth(width)
+		p.SetTextAlignment(creator.TextAlignmentCenter)
+		p.SetPos(xPos, yPos+img.Height())
+		err = c.Draw(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.WriteToFile(outputPath)
+}
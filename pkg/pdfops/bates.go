@@ -0,0 +1,103 @@
+package pdfops
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	unicommon "github.com/unidoc/unidoc/common"
+)
+
+// DefaultBatesTemplate is the stamp text of Bates numbering when none is specified.
+const DefaultBatesTemplate = "{bates}"
+
+// BatesOptions are the options of BatesNumberPdfs.
+type BatesOptions struct {
+	Prefix, Suffix string // Text before and after the number, e.g. "ABC".
+	Digits         int    // The number is zero padded to this many digits, 6 if 0.
+	Start          int    // First number.
+
+	// Stamp sets the font, color, position, etc. of the numbers.  Its Text is DefaultBatesTemplate if empty, and
+	// can contain the placeholder {bates} for the Bates number besides those of StampTextPdf.  Its Pages are
+	// ignored: every page is numbered.
+	Stamp TextStampOptions
+}
+
+// BatesRange is the range of Bates numbers given to the pages of a file.
+type BatesRange struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	First  string `json:"first"`
+	Last   string `json:"last"`
+	Pages  int    `json:"pages"`
+}
+
+// BatesNumberPdfs stamps the pages of `inputPaths` with sequential Bates numbers that continue from one file to
+// the next.  The numbered version of inputPaths[i] is written to outputPaths[i].  Returns the range of numbers of
+// each file.
+func BatesNumberPdfs(inputPaths, outputPaths []string, batesOpts BatesOptions, opts Options) ([]BatesRange, error) {
+	if len(inputPaths) != len(outputPaths) {
+		return nil, errors.New("Number of input and output files differ")
+	}
+
+	digits := batesOpts.Digits
+	if digits == 0 {
+		digits = 6
+	}
+	next := batesOpts.Start
+	if next < 0 || digits < 0 {
+		return nil, errors.New("Bates numbers and digits must not be negative")
+	}
+
+	stampOpts := batesOpts.Stamp
+	if stampOpts.Text == "" {
+		stampOpts.Text = DefaultBatesTemplate
+	}
+	stampOpts.Pages = nil
+	stamp, err := newTextStamp(stampOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	format := func(n int) string {
+		return batesOpts.Prefix + fmt.Sprintf("%0*d", digits, n) + batesOpts.Suffix
+	}
+
+	ranges := []BatesRange{}
+	for i, inputPath := range inputPaths {
+		first := next
+		setVars := func(vars *stampVars) {
+			vars.bates = format(next)
+			next++
+		}
+		if err := stampTextPdf(inputPath, outputPaths[i], stamp, nil, setVars, opts); err != nil {
+			return ranges, fmt.Errorf("%s: %v", inputPath, err)
+		}
+
+		r := BatesRange{Input: inputPath, Output: outputPaths[i], Pages: next - first}
+		if r.Pages > 0 {
+			r.First, r.Last = format(first), format(next-1)
+		}
+		unicommon.Log.Debug("%s: %s - %s", inputPath, r.First, r.Last)
+		ranges = append(ranges, r)
+	}
+
+	return ranges, nil
+}
+
+// WriteBatesLog writes `ranges` to `w` as CSV with the columns first, last, pages, input and output.
+func WriteBatesLog(w io.Writer, ranges []BatesRange) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"first", "last", "pages", "input", "output"}); err != nil {
+		return err
+	}
+	for _, r := range ranges {
+		if err := cw.Write([]string{r.First, r.Last, strconv.Itoa(r.Pages), r.Input, r.Output}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,223 @@
+package pdfops
+
+import (
+	"errors"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcontent "github.com/unidoc/unidoc/pdf/contentstream"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+)
+
+// DefaultMaxInkRatio is the default fraction of image samples that may deviate from the image's average before a
+// page is no longer considered blank.  Allows for dust and scanner noise on scanned separator sheets.
+const DefaultMaxInkRatio = 0.002
+
+// inkThreshold is the fraction of the sample range by which a sample must deviate from the average to count as ink.
+const inkThreshold = 0.25
+
+// IsBlankPage returns true if `page` does not visibly paint anything: it has no painting operators other than
+// white fills and invisible text, and all its images are near-uniform, i.e. at most `maxInkRatio` of their samples
+// deviate noticeably from the average.
+func IsBlankPage(page *pdf.PdfPage, maxInkRatio float64) (bool, error) {
+	contents, err := page.GetAllContentStreams()
+	if err != nil {
+		return false, err
+	}
+
+	return isContentStreamBlank(contents, page.Resources, maxInkRatio)
+}
+
+// isContentStreamBlank returns true if content stream `contents` does not visibly paint anything.
+// Form XObjects are checked recursively.
+func isContentStreamBlank(contents string, resources *pdf.PdfPageResources, maxInkRatio float64) (bool, error) {
+	cstreamParser := pdfcontent.NewContentStreamParser(contents)
+	operations, err := cstreamParser.Parse()
+	if err != nil {
+		return false, err
+	}
+
+	blank := true // Has nothing visible been painted so far?
+
+	// Text render mode, which is not tracked by the processor.  Mode 3 is invisible text, e.g. OCR text layers.
+	renderMode := int64(0)
+	renderModes := []int64{}
+
+	processor := pdfcontent.NewContentStreamProcessor(*operations)
+	processor.AddHandler(pdfcontent.HandlerConditionEnumAllOperands, "",
+		func(op *pdfcontent.ContentStreamOperation, gs pdfcontent.GraphicsState,
+			resources *pdf.PdfPageResources) error {
+			if !blank {
+				return nil
+			}
+
+			switch op.Operand {
+			case "q":
+				renderModes = append(renderModes, renderMode)
+			case "Q":
+				if len(renderModes) > 0 {
+					renderMode = renderModes[len(renderModes)-1]
+					renderModes = renderModes[:len(renderModes)-1]
+				}
+			case "Tr":
+				if len(op.Params) == 1 {
+					if mode, ok := op.Params[0].(*pdfcore.PdfObjectInteger); ok {
+						renderMode = int64(*mode)
+					}
+				}
+			case "Tj", "TJ", "'", "\"":
+				if renderMode != 3 {
+					blank = false
+				}
+			case "f", "F", "f*":
+				blank = isWhite(gs.ColorspaceNonStroking, gs.ColorNonStroking)
+			case "S", "s":
+				blank = isWhite(gs.ColorspaceStroking, gs.ColorStroking)
+			case "B", "B*", "b", "b*":
+				blank = isWhite(gs.ColorspaceNonStroking, gs.ColorNonStroking) &&
+					isWhite(gs.ColorspaceStroking, gs.ColorStroking)
+			case "sh":
+				blank = false
+			}
+			return nil
+		})
+
+	// Inline images are completely stored with a ContentStreamInlineImage object as the parameter for BI.
+	processor.AddHandler(pdfcontent.HandlerConditionEnumOperand, "BI",
+		func(op *pdfcontent.ContentStreamOperation, gs pdfcontent.GraphicsState,
+			resources *pdf.PdfPageResources) error {
+			if !blank || len(op.Params) != 1 {
+				return nil
+			}
+			iimg, ok := op.Params[0].(*pdfcontent.ContentStreamInlineImage)
+			if !ok {
+				return errors.New("Invalid inline image parameter")
+			}
+
+			cs, err := iimg.GetColorSpace(resources)
+			if err != nil {
+				return err
+			}
+			img, err := iimg.ToImage(resources)
+			if err != nil {
+				return err
+			}
+
+			blank, err = isImageUniform(img, cs, maxInkRatio)
+			return err
+		})
+
+	processor.AddHandler(pdfcontent.HandlerConditionEnumOperand, "Do",
+		func(op *pdfcontent.ContentStreamOperation, gs pdfcontent.GraphicsState,
+			resources *pdf.PdfPageResources) error {
+			if !blank || len(op.Params) < 1 {
+				return nil
+			}
+			name, ok := op.Params[0].(*pdfcore.PdfObjectName)
+			if !ok {
+				return errors.New("Invalid XObject name")
+			}
+
+			_, xtype := resources.GetXObjectByName(*name)
+			switch xtype {
+			case pdf.XObjectTypeImage:
+				ximg, err := resources.GetXObjectImageByName(*name)
+				if err != nil {
+					return err
+				}
+				img, err := ximg.ToImage()
+				if err != nil {
+					return err
+				}
+				blank, err = isImageUniform(img, ximg.ColorSpace, maxInkRatio)
+				return err
+
+			case pdf.XObjectTypeForm:
+				xform, err := resources.GetXObjectFormByName(*name)
+				if err != nil {
+					return err
+				}
+				formContents, err := xform.GetContentStream()
+				if err != nil {
+					return err
+				}
+				// Forms without resources use the resources of the page.
+				formResources := xform.Resources
+				if formResources == nil {
+					formResources = resources
+				}
+				blank, err = isContentStreamBlank(string(formContents), formResources, maxInkRatio)
+				return err
+			}
+			return nil
+		})
+
+	err = processor.Process(resources)
+	if err != nil {
+		return false, err
+	}
+
+	return blank, nil
+}
+
+// isWhite returns true if `color` in colorspace `cs` is (nearly) white.  Colors that cannot be converted to RGB,
+// such as patterns, are not considered white.
+func isWhite(cs pdf.PdfColorspace, color pdf.PdfColor) bool {
+	if cs == nil || color == nil {
+		return false
+	}
+
+	rgbColor, err := cs.ColorToRGB(color)
+	if err != nil {
+		unicommon.Log.Debug("Unable to convert color to RGB: %v", err)
+		return false
+	}
+	rgb, ok := rgbColor.(*pdf.PdfColorDeviceRGB)
+	if !ok {
+		return false
+	}
+
+	const minWhite = 0.98
+	return rgb.R() >= minWhite && rgb.G() >= minWhite && rgb.B() >= minWhite
+}
+
+// isImageUniform returns true if `img` is light and at most `maxInkRatio` of its samples deviate by more than
+// inkThreshold from the average sample value.
+func isImageUniform(img *pdf.Image, cs pdf.PdfColorspace, maxInkRatio float64) (bool, error) {
+	// Image masks and images in unknown colorspaces are checked as they are.
+	if cs != nil {
+		rgbImg, err := cs.ImageToRGB(*img)
+		if err != nil {
+			return false, err
+		}
+		img = &rgbImg
+	}
+
+	samples := img.GetSamples()
+	if len(samples) == 0 {
+		return true, nil
+	}
+
+	sum := 0.0
+	for _, s := range samples {
+		sum += float64(s)
+	}
+	avg := sum / float64(len(samples))
+
+	maxVal := float64(uint32(1)<<uint(img.BitsPerComponent) - 1)
+	threshold := inkThreshold * maxVal
+
+	// A uniformly dark image is not blank.  Image masks paint with the fill color and are not checked.
+	if cs != nil && avg < maxVal-threshold {
+		return false, nil
+	}
+
+	ink := 0
+	for _, s := range samples {
+		if d := float64(s) - avg; d > threshold || d < -threshold {
+			ink++
+		}
+	}
+
+	return float64(ink)/float64(len(samples)) <= maxInkRatio, nil
+}
@@ -0,0 +1,277 @@
+package pdfops
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// BoxSpecKind is the way a BoxSpec gives a page box.
+type BoxSpecKind int
+
+const (
+	BoxRect    BoxSpecKind = iota // Absolute coordinates llx, lly, urx, ury in default user space.
+	BoxMargins                    // Margins left, bottom, right, top from the edges of the MediaBox, as displayed.
+	BoxAuto                       // The bounding box of the page contents, plus padding.
+	BoxNone                       // No box, removing it from the page.
+)
+
+// BoxSpec specifies a new page box.
+type BoxSpec struct {
+	Kind   BoxSpecKind
+	Values [4]float64 // The coordinates with BoxRect, the margins with BoxMargins.
+}
+
+// ParseBoxSpec parses box specification `s`: absolute coordinates `llx,lly,urx,ury` in default user space,
+// `margins:l,b,r,t` (left, bottom, right and top, as the page is displayed) or `margins:m` (the same on every side)
+// from the edges of the MediaBox, `auto` for the bounding box of the page contents or `none` to remove the box.
+func ParseBoxSpec(s string) (BoxSpec, error) {
+	s = strings.TrimSpace(s)
+	switch strings.ToLower(s) {
+	case "auto":
+		return BoxSpec{Kind: BoxAuto}, nil
+	case "none":
+		return BoxSpec{Kind: BoxNone}, nil
+	}
+
+	spec := BoxSpec{Kind: BoxRect}
+	if i := strings.Index(s, ":"); i >= 0 {
+		if !strings.EqualFold(s[:i], "margins") {
+			return BoxSpec{}, fmt.Errorf("Invalid box %q", s)
+		}
+		spec.Kind = BoxMargins
+		s = s[i+1:]
+	}
+
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 && !(spec.Kind == BoxMargins && len(parts) == 1) {
+		return BoxSpec{}, fmt.Errorf("Invalid box %q, expected 4 numbers", s)
+	}
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return BoxSpec{}, fmt.Errorf("Invalid box %q: %v", s, err)
+		}
+		spec.Values[i] = v
+	}
+	if len(parts) == 1 {
+		spec.Values = [4]float64{spec.Values[0], spec.Values[0], spec.Values[0], spec.Values[0]}
+	}
+
+	if spec.Kind == BoxRect {
+		b := Box(spec.Values).normalized()
+		if b[0] == b[2] || b[1] == b[3] {
+			return BoxSpec{}, fmt.Errorf("Empty box %q", s)
+		}
+		spec.Values = b
+	}
+	return spec, nil
+}
+
+// PageBoxesOptions are the options of SetPageBoxesPdf.  The boxes that are nil are left unchanged.
+type PageBoxesOptions struct {
+	MediaBox, CropBox, BleedBox, TrimBox, ArtBox *BoxSpec
+
+	Padding float64 // Space added around the contents with BoxAuto.
+
+	Pages *pagerange.Selection // Pages to change, nil for all.
+}
+
+// SetPageBoxesPdf changes the page boxes of the pages of `inputPath` as specified by `boxOpts` and writes the result
+// to `outputPath`.  The MediaBox is changed first and the other boxes are placed relative to the new one and clipped
+// to it.  Forms, outlines and optional content are kept.
+func SetPageBoxesPdf(inputPath, outputPath string, boxOpts PageBoxesOptions, opts Options) error {
+	if boxOpts.MediaBox != nil && boxOpts.MediaBox.Kind == BoxNone {
+		return errors.New("The MediaBox cannot be removed")
+	}
+
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return err
+	}
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	selected, err := boxOpts.Pages.SelectSet(pdfReader)
+	if err != nil {
+		return err
+	}
+
+	pdfWriter := pdf.NewPdfWriter()
+
+	ocProps, err := pdfReader.GetOCProperties()
+	if err != nil {
+		return err
+	}
+	pdfWriter.SetOCProperties(ocProps)
+
+	for i, page := range pdfReader.PageList {
+		pageNum := i + 1
+
+		if selected[pageNum] {
+			if err := setPageBoxes(page, pageNum, boxOpts); err != nil {
+				return fmt.Errorf("Page %d: %v", pageNum, err)
+			}
+		}
+
+		if err := pdfWriter.AddPage(page); err != nil {
+			return err
+		}
+	}
+
+	if err := pdfWriter.SetForms(pdfReader.AcroForm); err != nil {
+		return err
+	}
+	items := copyOutlineItems(pdfReader, outlineItems(pdfReader.GetOutlineTree()), readerPageObjects(pdfReader))
+	if len(items) > 0 {
+		pdfWriter.AddOutlineTree(&newOutlineTree(items).PdfOutlineTreeNode)
+	}
+
+	return writePdf(&pdfWriter, outputPath)
+}
+
+// setPageBoxes changes the boxes of `page` as specified by `boxOpts`.
+func setPageBoxes(page *pdf.PdfPage, pageNum int, boxOpts PageBoxesOptions) error {
+	mbox, err := page.GetMediaBox()
+	if err != nil {
+		return err
+	}
+	mediaBox := newBox(mbox).normalized()
+
+	// The content bounding box is computed at most once, and only if needed.
+	var content *Box
+	contentBox := func() (Box, bool, error) {
+		if content == nil {
+			b, ok, err := contentBBox(page, pageNum)
+			if err != nil || !ok {
+				return Box{}, ok, err
+			}
+			content = &b
+		}
+		return *content, true, nil
+	}
+
+	// resolve returns the box specified by `spec` on a page with MediaBox `media`, or false if it is to be
+	// removed or left unchanged.
+	resolve := func(spec BoxSpec, media Box) (Box, bool, error) {
+		switch spec.Kind {
+		case BoxRect:
+			return Box(spec.Values), true, nil
+		case BoxMargins:
+			var rotate int64
+			if page.Rotate != nil {
+				rotate = (*page.Rotate%360 + 360) % 360
+			}
+			toUser, w, h := media.displayMatrix(rotate)
+			l, b, r, t := spec.Values[0], spec.Values[1], spec.Values[2], spec.Values[3]
+			if l+r >= w || b+t >= h {
+				return Box{}, false, errors.New("Margins leave an empty box")
+			}
+			return toUser.transformBox(l, b, w-r, h-t), true, nil
+		case BoxAuto:
+			b, ok, err := contentBox()
+			if err != nil || !ok {
+				return Box{}, false, err
+			}
+			p := boxOpts.Padding
+			return Box{b[0] - p, b[1] - p, b[2] + p, b[3] + p}, true, nil
+		}
+		return Box{}, false, nil
+	}
+
+	if boxOpts.MediaBox != nil {
+		b, ok, err := resolve(*boxOpts.MediaBox, mediaBox)
+		if err != nil {
+			return err
+		}
+		if ok {
+			mediaBox = b
+			page.MediaBox = &pdf.PdfRectangle{Llx: b[0], Lly: b[1], Urx: b[2], Ury: b[3]}
+		} else {
+			unicommon.Log.Debug("Page %d: nothing to crop to, MediaBox left unchanged", pageNum)
+		}
+	}
+
+	boxes := []struct {
+		name string
+		spec *BoxSpec
+		box  **pdf.PdfRectangle
+	}{
+		{"CropBox", boxOpts.CropBox, &page.CropBox},
+		{"BleedBox", boxOpts.BleedBox, &page.BleedBox},
+		{"TrimBox", boxOpts.TrimBox, &page.TrimBox},
+		{"ArtBox", boxOpts.ArtBox, &page.ArtBox},
+	}
+	for _, box := range boxes {
+		if box.spec == nil {
+			continue
+		}
+		if box.spec.Kind == BoxNone {
+			*box.box = nil
+			continue
+		}
+
+		b, ok, err := resolve(*box.spec, mediaBox)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			unicommon.Log.Debug("Page %d: nothing to crop to, %s left unchanged", pageNum, box.name)
+			continue
+		}
+		// Boxes extending beyond the MediaBox are clipped to it by viewers anyway.
+		b = Box{math.Max(b[0], mediaBox[0]), math.Max(b[1], mediaBox[1]),
+			math.Min(b[2], mediaBox[2]), math.Min(b[3], mediaBox[3])}
+		if b[0] >= b[2] || b[1] >= b[3] {
+			return fmt.Errorf("%s is outside the MediaBox", box.name)
+		}
+		*box.box = &pdf.PdfRectangle{Llx: b[0], Lly: b[1], Urx: b[2], Ury: b[3]}
+	}
+	return nil
+}
+
+// contentBBox returns the bounding box of what is painted on `page`, number `pageNum`: text, images and paths
+// other than white fills.  It returns false if nothing is painted.  Clipping is not taken into account.
+func contentBBox(page *pdf.PdfPage, pageNum int) (Box, bool, error) {
+	contents, err := page.GetAllContentStreams()
+	if err != nil {
+		return Box{}, false, err
+	}
+
+	walker := newTextWalker(pageNum)
+	if err := walker.walk(contents, &contentStream{resources: page.Resources}, identityMatrix, 0); err != nil {
+		return Box{}, false, err
+	}
+
+	boxes := []Box{}
+	for _, run := range walker.runs {
+		for _, c := range run.Chars {
+			// Spaces paint nothing.  Characters that could not be decoded are assumed to.
+			if c.Text == "" || strings.TrimSpace(c.Text) != "" {
+				boxes = append(boxes, c.BBox)
+			}
+		}
+	}
+	for _, img := range walker.images {
+		boxes = append(boxes, img.ctm.transformBox(0, 0, 1, 1))
+	}
+	boxes = append(boxes, walker.paths...)
+
+	if len(boxes) == 0 {
+		return Box{}, false, nil
+	}
+	bbox := boxes[0]
+	for _, b := range boxes[1:] {
+		bbox = bbox.union(b)
+	}
+	unicommon.Log.Debug("Page %d: content bounding box %.1f", pageNum, bbox)
+	return bbox, true, nil
+}
@@ -0,0 +1,119 @@
+package pdfops
+
+import (
+	"testing"
+
+	pdf "github.com/unidoc/unidoc/pdf/model"
+)
+
+func TestParseBoxSpec(t *testing.T) {
+	tests := []struct {
+		in   string
+		want BoxSpec
+	}{
+		{"auto", BoxSpec{Kind: BoxAuto}},
+		{" NONE ", BoxSpec{Kind: BoxNone}},
+		{"0,0,612,792", BoxSpec{Kind: BoxRect, Values: [4]float64{0, 0, 612, 792}}},
+		{"10, 20 , 300.5,400", BoxSpec{Kind: BoxRect, Values: [4]float64{10, 20, 300.5, 400}}},
+		{"612,792,0,0", BoxSpec{Kind: BoxRect, Values: [4]float64{0, 0, 612, 792}}},
+		{"-10,50,100,-20", BoxSpec{Kind: BoxRect, Values: [4]float64{-10, -20, 100, 50}}},
+		{"margins:36", BoxSpec{Kind: BoxMargins, Values: [4]float64{36, 36, 36, 36}}},
+		{"Margins:10,20,30,40", BoxSpec{Kind: BoxMargins, Values: [4]float64{10, 20, 30, 40}}},
+		{"margins:0", BoxSpec{Kind: BoxMargins}},
+	}
+
+	for _, test := range tests {
+		got, err := ParseBoxSpec(test.in)
+		if err != nil {
+			t.Errorf("ParseBoxSpec(%q): %v", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseBoxSpec(%q) = %+v, want %+v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseBoxSpecErrors(t *testing.T) {
+	invalid := []string{
+		"",
+		"1,2,3",
+		"1,2,3,4,5",
+		"a,b,c,d",
+		"0,0,0,792",   // Empty box.
+		"5,5,5,5",     // Empty box.
+		"100,0,100,1", // Empty box.
+		"margins:",
+		"margins:1,2",
+		"margins:1,2,3,4,5",
+		"margin:10",
+		"auto:5",
+	}
+
+	for _, s := range invalid {
+		if spec, err := ParseBoxSpec(s); err == nil {
+			t.Errorf("ParseBoxSpec(%q) = %+v, want an error", s, spec)
+		}
+	}
+}
+
+func TestSetPageBoxesMargins(t *testing.T) {
+	// The margins are left, bottom, right and top of the page as displayed.
+	margins := &BoxSpec{Kind: BoxMargins, Values: [4]float64{10, 20, 30, 40}}
+
+	tests := []struct {
+		rotate int64
+		want   pdf.PdfRectangle
+	}{
+		{0, pdf.PdfRectangle{Llx: 110, Lly: 70, Urx: 670, Ury: 810}},
+		{90, pdf.PdfRectangle{Llx: 140, Lly: 60, Urx: 680, Ury: 820}},
+		{180, pdf.PdfRectangle{Llx: 130, Lly: 90, Urx: 690, Ury: 830}},
+		{270, pdf.PdfRectangle{Llx: 120, Lly: 80, Urx: 660, Ury: 840}},
+		{-90, pdf.PdfRectangle{Llx: 120, Lly: 80, Urx: 660, Ury: 840}},
+	}
+
+	for _, test := range tests {
+		rotate := test.rotate
+		page := &pdf.PdfPage{MediaBox: &pdf.PdfRectangle{Llx: 100, Lly: 50, Urx: 700, Ury: 850}, Rotate: &rotate}
+		if err := setPageBoxes(page, 1, PageBoxesOptions{CropBox: margins}); err != nil {
+			t.Errorf("Rotate %d: %v", test.rotate, err)
+			continue
+		}
+		if page.CropBox == nil || *page.CropBox != test.want {
+			t.Errorf("Rotate %d: CropBox %v, want %v", test.rotate, page.CropBox, test.want)
+		}
+	}
+}
+
+func TestSetPageBoxesClipping(t *testing.T) {
+	page := &pdf.PdfPage{MediaBox: &pdf.PdfRectangle{Urx: 600, Ury: 800}}
+	boxOpts := PageBoxesOptions{
+		MediaBox: &BoxSpec{Kind: BoxRect, Values: [4]float64{0, 0, 500, 700}},
+		TrimBox:  &BoxSpec{Kind: BoxRect, Values: [4]float64{-10, 100, 550, 600}},
+		ArtBox:   &BoxSpec{Kind: BoxNone},
+	}
+	page.ArtBox = &pdf.PdfRectangle{Urx: 10, Ury: 10}
+	if err := setPageBoxes(page, 1, boxOpts); err != nil {
+		t.Fatal(err)
+	}
+	if want := (pdf.PdfRectangle{Urx: 500, Ury: 700}); *page.MediaBox != want {
+		t.Errorf("MediaBox %v, want %v", *page.MediaBox, want)
+	}
+	if want := (pdf.PdfRectangle{Lly: 100, Urx: 500, Ury: 600}); page.TrimBox == nil || *page.TrimBox != want {
+		t.Errorf("TrimBox %v, want %v", page.TrimBox, want)
+	}
+	if page.ArtBox != nil {
+		t.Errorf("ArtBox %v not removed", *page.ArtBox)
+	}
+
+	errorTests := []PageBoxesOptions{
+		{CropBox: &BoxSpec{Kind: BoxMargins, Values: [4]float64{300, 0, 300, 0}}},
+		{CropBox: &BoxSpec{Kind: BoxRect, Values: [4]float64{600, 0, 700, 100}}},
+	}
+	for _, boxOpts := range errorTests {
+		page := &pdf.PdfPage{MediaBox: &pdf.PdfRectangle{Urx: 600, Ury: 800}}
+		if err := setPageBoxes(page, 1, boxOpts); err == nil {
+			t.Errorf("%+v succeeded, want an error", *boxOpts.CropBox)
+		}
+	}
+}
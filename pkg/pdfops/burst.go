@@ -0,0 +1,461 @@
+package pdfops
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// SplitMode selects how SplitMultiPdf divides a document into several files.
+type SplitMode int
+
+const (
+	SplitBurst       SplitMode = iota // One file per page.
+	SplitEveryN                       // A new file every SplitOptions.N pages.
+	SplitAtBookmarks                  // A new file at each top level bookmark.
+	SplitBySize                       // A new file whenever SplitOptions.MaxSize would be exceeded.
+	SplitAtBlanks                     // A new file at each run of blank (separator) pages.
+)
+
+// DefaultSplitTemplate is the output file name template used when none is specified.
+const DefaultSplitTemplate = "{base}_{n:03}.pdf"
+
+// SplitOptions specifies how SplitMultiPdf splits a document.
+type SplitOptions struct {
+	Mode SplitMode
+
+	// N is the number of pages per file for SplitEveryN.
+	N int
+
+	// MaxSize is the maximum size of an output file in bytes for SplitBySize.  A page that exceeds the limit on its
+	// own is written to a file of its own.
+	MaxSize int64
+
+	// MaxInkRatio is the blank page detection tolerance for SplitAtBlanks, see IsBlankPage.  DefaultMaxInkRatio
+	// if 0.
+	MaxInkRatio float64
+
+	// DropBlanks removes the blank pages for SplitAtBlanks.  Otherwise they are kept at the start of the file that
+	// follows them.
+	DropBlanks bool
+
+	// Template is the output file name template, DefaultSplitTemplate if empty.  It can contain the placeholders
+	// {base} (input path without the .pdf extension), {n} (number of the output file, starting at 1), {from} and {to}
+	// (first and last page in the file) and {title} (bookmark title for SplitAtBookmarks).  Numeric placeholders
+	// accept a zero padded width, e.g. {n:03}.
+	Template string
+
+	// Pages selects the pages that are split, all pages if nil.
+	Pages *pagerange.Selection
+}
+
+// splitPart is a group of pages that is written to one output file.
+type splitPart struct {
+	pages []int
+	title string
+}
+
+// SplitMultiPdf splits `inputPath` into several files according to `splitOpts` and returns the paths of the files
+// written.  Optional content (OCProperties) is kept intact in every output.
+func SplitMultiPdf(inputPath string, splitOpts SplitOptions, opts Options) ([]string, error) {
+	template := splitOpts.Template
+	if template == "" {
+		template = DefaultSplitTemplate
+	}
+	// Check the template before doing any work.
+	_, err := formatSplitName(template, inputPath, 1, splitPart{pages: []int{1}})
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return nil, err
+	}
+
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	pageNums, err := splitOpts.Pages.Select(pdfReader)
+	if err != nil {
+		return nil, err
+	}
+	if len(pageNums) == 0 {
+		return nil, fmt.Errorf("No pages selected by %s", splitOpts.Pages)
+	}
+
+	ocProps, err := pdfReader.GetOCProperties()
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []splitPart
+	switch splitOpts.Mode {
+	case SplitBurst:
+		parts = splitEvery(pageNums, 1)
+	case SplitEveryN:
+		if splitOpts.N < 1 {
+			return nil, errors.New("Number of pages per file must be at least 1")
+		}
+		parts = splitEvery(pageNums, splitOpts.N)
+	case SplitAtBookmarks:
+		parts = splitAtBookmarks(pdfReader, pageNums)
+	case SplitBySize:
+		if splitOpts.MaxSize <= 0 {
+			return nil, errors.New("Maximum file size must be positive")
+		}
+		return splitBySize(pdfReader, ocProps, pageNums, splitOpts.MaxSize, inputPath, template)
+	case SplitAtBlanks:
+		maxInkRatio := splitOpts.MaxInkRatio
+		if maxInkRatio == 0 {
+			maxInkRatio = DefaultMaxInkRatio
+		}
+		parts, err = splitAtBlanks(pdfReader, pageNums, maxInkRatio, splitOpts.DropBlanks)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported split mode %d", splitOpts.Mode)
+	}
+
+	outputPaths := []string{}
+	for i, part := range parts {
+		outputPath, err := formatSplitName(template, inputPath, i+1, part)
+		if err != nil {
+			return outputPaths, err
+		}
+
+		data, err := renderPages(pdfReader, ocProps, part.pages)
+		if err != nil {
+			return outputPaths, err
+		}
+		err = ioutil.WriteFile(outputPath, data, 0644)
+		if err != nil {
+			return outputPaths, err
+		}
+		outputPaths = append(outputPaths, outputPath)
+	}
+
+	return outputPaths, nil
+}
+
+// renderPages returns a PDF document consisting of pages `pageNums` of `pdfReader`.
+// The pages are added to a new writer on every call, so that the same page can be rendered several times.
+func renderPages(pdfReader *pdf.PdfReader, ocProps pdfcore.PdfObject, pageNums []int) ([]byte, error) {
+	pdfWriter := pdf.NewPdfWriter()
+
+	// Keep the OC properties intact (optional content).
+	pdfWriter.SetOCProperties(ocProps)
+
+	for _, pageNum := range pageNums {
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return nil, err
+		}
+
+		err = pdfWriter.AddPage(page)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	err := pdfWriter.Write(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// splitEvery divides `pageNums` into parts of `n` pages.
+func splitEvery(pageNums []int, n int) []splitPart {
+	parts := []splitPart{}
+	for i := 0; i < len(pageNums); i += n {
+		end := i + n
+		if end > len(pageNums) {
+			end = len(pageNums)
+		}
+		parts = append(parts, splitPart{pages: pageNums[i:end]})
+	}
+	return parts
+}
+
+// splitAtBookmarks divides `pageNums` at the pages that the top level bookmarks of `pdfReader` point to.  Pages
+// before the first bookmark form a part of their own.
+func splitAtBookmarks(pdfReader *pdf.PdfReader, pageNums []int) []splitPart {
+	objNums := pageObjectNumbers(pdfReader)
+	named := namedDests(pdfReader)
+
+	titles := map[int]string{}
+	for _, item := range outlineItems(pdfReader.GetOutlineTree()) {
+		pageNum := destPageNum(outlineDest(item, named), objNums)
+		if pageNum == 0 {
+			unicommon.Log.Debug("Bookmark %q has no page destination - skipping", outlineTitle(item))
+			continue
+		}
+		// The first bookmark pointing to a page names the part.
+		if _, has := titles[pageNum]; !has {
+			titles[pageNum] = outlineTitle(item)
+		}
+	}
+	if len(titles) == 0 {
+		unicommon.Log.Debug("No bookmarks with page destinations, writing a single file")
+	}
+
+	parts := []splitPart{}
+	for _, pageNum := range pageNums {
+		title, isStart := titles[pageNum]
+		if isStart || len(parts) == 0 {
+			parts = append(parts, splitPart{title: title})
+		}
+		part := &parts[len(parts)-1]
+		part.pages = append(part.pages, pageNum)
+	}
+	return parts
+}
+
+// splitAtBlanks divides `pageNums` at each run of blank pages, see IsBlankPage.  The blank pages are dropped if
+// `dropBlanks` is true, otherwise they start the part that follows them.
+func splitAtBlanks(pdfReader *pdf.PdfReader, pageNums []int, maxInkRatio float64, dropBlanks bool) ([]splitPart,
+	error) {
+	parts := []splitPart{{}}
+	prevBlank := false
+
+	for _, pageNum := range pageNums {
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return nil, err
+		}
+		blank, err := IsBlankPage(page, maxInkRatio)
+		if err != nil {
+			return nil, err
+		}
+		if blank {
+			unicommon.Log.Info("Page %d is blank", pageNum)
+		}
+
+		if blank && !prevBlank && len(parts[len(parts)-1].pages) > 0 {
+			parts = append(parts, splitPart{})
+		}
+		prevBlank = blank
+
+		if blank && dropBlanks {
+			continue
+		}
+		part := &parts[len(parts)-1]
+		part.pages = append(part.pages, pageNum)
+	}
+
+	// Trailing blank pages that were dropped leave an empty part behind.
+	if len(parts[len(parts)-1].pages) == 0 {
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) == 0 {
+		return nil, errors.New("All selected pages are blank")
+	}
+
+	return parts, nil
+}
+
+// splitBySize writes `pageNums` to as few files as possible that are each at most `maxSize` bytes.
+func splitBySize(pdfReader *pdf.PdfReader, ocProps pdfcore.PdfObject, pageNums []int, maxSize int64,
+	inputPath, template string) ([]string, error) {
+	outputPaths := []string{}
+
+	render := func(pageNums []int) ([]byte, error) {
+		return renderPages(pdfReader, ocProps, pageNums)
+	}
+	write := func(part splitPart, data []byte) error {
+		outputPath, err := formatSplitName(template, inputPath, len(outputPaths)+1, part)
+		if err != nil {
+			return err
+		}
+		if int64(len(data)) > maxSize {
+			unicommon.Log.Info("%s: page %d exceeds the size limit on its own (%d bytes)", outputPath, part.pages[0],
+				len(data))
+		}
+		if err := ioutil.WriteFile(outputPath, data, 0644); err != nil {
+			return err
+		}
+		outputPaths = append(outputPaths, outputPath)
+		return nil
+	}
+
+	err := packBySize(pageNums, maxSize, render, write)
+	return outputPaths, err
+}
+
+// packBySize divides `pageNums` into as few parts as possible whose documents, as returned by `render`, are each
+// at most `maxSize` bytes, and passes each part and its document to `write`, in order.  A page that exceeds the
+// limit on its own makes a part of its own.
+// Each page is rendered on its own once to measure it.  The size of a part is estimated from the sizes of its pages,
+// scaled by how much smaller than their sum the part was when it was last measured, as pages share resources.  A
+// part is only rendered to measure it when the estimate exceeds the limit, and when it is written.
+func packBySize(pageNums []int, maxSize int64, render func(pageNums []int) ([]byte, error),
+	write func(part splitPart, data []byte) error) error {
+	var part splitPart
+	var partData []byte                  // The rendered part, nil if it changed since it was rendered.
+	var sum, measured, measuredSum int64 // Sum of the page sizes of the part, and its size when last measured.
+	sizes := map[int]int64{}
+
+	// flush writes the part.  Returns the number of pages it left out, the estimate having been too low.
+	flush := func() (int, error) {
+		dropped := 0
+		for {
+			if partData == nil {
+				data, err := render(part.pages)
+				if err != nil {
+					return 0, err
+				}
+				partData = data
+			}
+			if int64(len(partData)) <= maxSize || len(part.pages) == 1 {
+				break
+			}
+			part.pages = part.pages[:len(part.pages)-1]
+			partData = nil
+			dropped++
+		}
+
+		if err := write(part, partData); err != nil {
+			return 0, err
+		}
+
+		part, partData = splitPart{}, nil
+		return dropped, nil
+	}
+
+	for i := 0; i < len(pageNums) || len(part.pages) > 0; {
+		if i == len(pageNums) {
+			dropped, err := flush()
+			if err != nil {
+				return err
+			}
+			i -= dropped
+			continue
+		}
+		pageNum := pageNums[i]
+
+		size, ok := sizes[pageNum]
+		var data []byte
+		if !ok {
+			var err error
+			data, err = render([]int{pageNum})
+			if err != nil {
+				return err
+			}
+			size = int64(len(data))
+			sizes[pageNum] = size
+		}
+
+		if len(part.pages) == 0 {
+			part.pages, partData = []int{pageNum}, data
+			sum, measured, measuredSum = size, size, size
+			i++
+			continue
+		}
+
+		candidate := append(part.pages[:len(part.pages):len(part.pages)], pageNum)
+		estimate := measured + (sum+size-measuredSum)*measured/measuredSum
+		if estimate <= maxSize {
+			part.pages, partData = candidate, nil
+			sum += size
+			i++
+			continue
+		}
+
+		// Near the limit: measure the part with the page.
+		data, err := render(candidate)
+		if err != nil {
+			return err
+		}
+		if int64(len(data)) <= maxSize {
+			part.pages, partData = candidate, data
+			sum += size
+			measured, measuredSum = int64(len(data)), sum
+			i++
+			continue
+		}
+
+		// The page does not fit anymore, write out what we have.  The page starts the next part.
+		dropped, err := flush()
+		if err != nil {
+			return err
+		}
+		i -= dropped
+	}
+
+	return nil
+}
+
+var reSplitPlaceholder = regexp.MustCompile(`\{(\w+)(?::(\d+))?\}`)
+
+// formatSplitName returns the output path for the `n`th part `part` of `inputPath` according to `template`.
+// See SplitOptions.Template for the placeholders.
+func formatSplitName(template, inputPath string, n int, part splitPart) (string, error) {
+	base := strings.TrimSuffix(inputPath, filepath.Ext(inputPath))
+
+	var err error
+	name := reSplitPlaceholder.ReplaceAllStringFunc(template, func(ph string) string {
+		m := reSplitPlaceholder.FindStringSubmatch(ph)
+		key, width := m[1], m[2]
+
+		var num int
+		switch key {
+		case "base":
+			return base
+		case "title":
+			return sanitizeFileName(part.title)
+		case "n":
+			num = n
+		case "from":
+			num = part.pages[0]
+		case "to":
+			num = part.pages[len(part.pages)-1]
+		default:
+			err = fmt.Errorf("Unknown placeholder %s in template %q", ph, template)
+			return ph
+		}
+
+		if width == "" {
+			return strconv.Itoa(num)
+		}
+		w, _ := strconv.Atoi(width)
+		return fmt.Sprintf("%0*d", w, num)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.Contains(template, "{n") && !strings.Contains(template, "{from") {
+		return "", fmt.Errorf("Template %q must contain {n} or {from} to give each file a distinct name", template)
+	}
+
+	return name, nil
+}
+
+// sanitizeFileName replaces the characters of `s` that are not safe to use in file names.
+func sanitizeFileName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(s))
+	if s == "" {
+		return "untitled"
+	}
+	return s
+}
@@ -0,0 +1,59 @@
+package pdfops
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPackBySize(t *testing.T) {
+	// Pages share 100 bytes of resources, page 3 is large.
+	pageSizes := map[int]int{1: 50, 2: 50, 3: 300, 4: 50, 5: 50, 6: 50}
+	render := func(pageNums []int) ([]byte, error) {
+		size := 100
+		for _, pageNum := range pageNums {
+			size += pageSizes[pageNum]
+		}
+		return make([]byte, size), nil
+	}
+
+	got, sizes := packPages(t, []int{1, 2, 3, 4, 5, 6}, 300, render)
+	if want := [][]int{{1, 2}, {3}, {4, 5, 6}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if want := []int{200, 400, 250}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("got sizes %v, want %v", sizes, want)
+	}
+}
+
+func TestPackBySizeUnderestimate(t *testing.T) {
+	// Up to two pages cost 60 bytes each, more cost 200 each, so the estimates from single pages are too low.
+	render := func(pageNums []int) ([]byte, error) {
+		if len(pageNums) <= 2 {
+			return make([]byte, 60*len(pageNums)), nil
+		}
+		return make([]byte, 200*len(pageNums)), nil
+	}
+
+	got, sizes := packPages(t, []int{1, 2, 3, 4, 5}, 300, render)
+	if want := [][]int{{1, 2}, {3, 4}, {5}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if want := []int{120, 120, 60}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("got sizes %v, want %v", sizes, want)
+	}
+}
+
+// packPages returns the parts that packBySize writes for `pageNums` and the sizes of their documents.
+func packPages(t *testing.T, pageNums []int, maxSize int64,
+	render func(pageNums []int) ([]byte, error)) ([][]int, []int) {
+	parts, sizes := [][]int{}, []int{}
+	err := packBySize(pageNums, maxSize, render, func(part splitPart, data []byte) error {
+		parts = append(parts, append([]int{}, part.pages...))
+		sizes = append(sizes, len(data))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parts, sizes
+}
@@ -0,0 +1,41 @@
+package pdfops
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RGB is a color with red, green and blue components in the range 0 - 1.
+type RGB [3]float64
+
+// Colors that can be given by name to ParseRGB.
+var namedColors = map[string]RGB{
+	"black": {0, 0, 0},
+	"white": {1, 1, 1},
+	"gray":  {0.5, 0.5, 0.5},
+	"red":   {1, 0, 0},
+	"green": {0, 0.5, 0},
+	"blue":  {0, 0, 1},
+}
+
+// ParseRGB parses color `s`, given as #rrggbb, #rgb or a name (black, white, gray, red, green or blue).
+func ParseRGB(s string) (RGB, error) {
+	if color, ok := namedColors[strings.ToLower(s)]; ok {
+		return color, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return RGB{}, fmt.Errorf("Invalid color %q", s)
+	}
+	val, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return RGB{}, fmt.Errorf("Invalid color %q", s)
+	}
+
+	return RGB{float64(val>>16) / 255, float64(val>>8&0xff) / 255, float64(val&0xff) / 255}, nil
+}
@@ -0,0 +1,80 @@
+package pdfops
+
+import (
+	"fmt"
+
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// CropPdf crops the pages of `inputPath` selected by `pages` by `percentage` and writes the result to `outputPath`.
+// The percentage specifies the trim-off percentage, both width- and heightwise, and the view is zoomed in on
+// the page middle.
+func CropPdf(inputPath string, outputPath string, percentage int64, pages *pagerange.Selection, opts Options) error {
+	if percentage < 0 || percentage > 100 {
+		return fmt.Errorf("Percentage should be in the range 0 - 100 (got %d)", percentage)
+	}
+
+	pdfWriter := pdf.NewPdfWriter()
+
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return err
+	}
+
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return err
+	}
+
+	selected, err := pages.SelectSet(pdfReader)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < numPages; i++ {
+		pageNum := i + 1
+
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return err
+		}
+
+		if !selected[pageNum] {
+			err = pdfWriter.AddPage(page)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		bbox, err := page.GetMediaBox()
+		if err != nil {
+			return err
+		}
+
+		// Zoom in on the page middle, with a scaled width and height.
+		width := (*bbox).Urx - (*bbox).Llx
+		height := (*bbox).Ury - (*bbox).Lly
+		newWidth := width * float64(percentage) / 100.0
+		newHeight := height * float64(percentage) / 100.0
+		(*bbox).Llx += newWidth / 2
+		(*bbox).Lly += newHeight / 2
+		(*bbox).Urx -= newWidth / 2
+		(*bbox).Ury -= newHeight / 2
+
+		page.MediaBox = bbox
+
+		err = pdfWriter.AddPage(page)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writePdf(&pdfWriter, outputPath)
+}
@@ -0,0 +1,225 @@
+package pdfops
+
+import (
+	"fmt"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+)
+
+// FieldNameStrategy selects how MergePdf handles form fields with the same name in different inputs.
+type FieldNameStrategy int
+
+const (
+	FieldsRenameColliding FieldNameStrategy = iota // Keep names, rename fields whose name is already taken.
+	FieldsPrefixAll                                // Prefix the names of all fields of input N with "docN_".
+	FieldsMergeSameNamed                           // Join same-named fields of the same type into one shared field.
+)
+
+// XFAPolicy selects how MergePdf handles XFA form data, which cannot be merged.
+type XFAPolicy int
+
+const (
+	XFAKeepFirst XFAPolicy = iota // Keep the XFA of the first input that has one.
+	XFADrop                       // Remove XFA, leaving only the AcroForm fields.
+	XFAFail                       // Fail if any of the inputs has XFA.
+)
+
+// fieldName returns the partial name of `field`.
+func fieldName(field *pdf.PdfField) string {
+	t, ok := pdfcore.TraceToDirectObject(field.T).(*pdfcore.PdfObjectString)
+	if !ok {
+		return ""
+	}
+	return string(*t)
+}
+
+// childFields returns the fields among the kids of `field`.  Widget annotations are not included.
+func childFields(field *pdf.PdfField) []*pdf.PdfField {
+	children := []*pdf.PdfField{}
+	for _, kid := range field.KidsF {
+		if child, ok := kid.(*pdf.PdfField); ok {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// nonFieldKids returns the kids of `field` that are not fields, i.e. its widget annotations.
+func nonFieldKids(field *pdf.PdfField) []pdf.PdfModel {
+	kids := []pdf.PdfModel{}
+	for _, kid := range field.KidsF {
+		if _, isField := kid.(*pdf.PdfField); !isField {
+			kids = append(kids, kid)
+		}
+	}
+	return kids
+}
+
+// isTerminalField returns true if `field` has no child fields, i.e. it is the field that holds a value.
+func isTerminalField(field *pdf.PdfField) bool {
+	return len(childFields(field)) == 0
+}
+
+// prefixFields prepends `prefix` to the names of the fields in `fields`.  As fully qualified names are made of the
+// partial names of the ancestors, this renames all their descendants as well.
+func prefixFields(fields []*pdf.PdfField, prefix string) {
+	for _, field := range fields {
+		field.T = pdfcore.MakeString(prefix + fieldName(field))
+	}
+}
+
+// mergeFieldLists merges fields `src` into sibling fields `dst` according to `strategy`, and returns the merged
+// list.  `parent` is the parent field of the lists, nil for the top level fields.
+func mergeFieldLists(dst, src []*pdf.PdfField, parent *pdf.PdfField, strategy FieldNameStrategy) []*pdf.PdfField {
+	byName := map[string]*pdf.PdfField{}
+	for _, field := range dst {
+		byName[fieldName(field)] = field
+	}
+
+	for _, field := range src {
+		name := fieldName(field)
+		existing, collides := byName[name]
+		if !collides || name == "" {
+			field.Parent = parent
+			dst = append(dst, field)
+			byName[name] = field
+			continue
+		}
+
+		// Same-named intermediate fields: merge their children, only renaming the ones that collide.  Their other
+		// kids, widgets of the intermediate fields themselves, are all kept.
+		if !isTerminalField(existing) && !isTerminalField(field) {
+			kids := mergeFieldLists(childFields(existing), childFields(field), existing, strategy)
+			widgets := append(nonFieldKids(existing), nonFieldKids(field)...)
+			parentObj := existing.GetContainingPdfObject()
+			existing.KidsF = []pdf.PdfModel{}
+			for _, kid := range kids {
+				existing.KidsF = append(existing.KidsF, kid)
+			}
+			for _, kid := range widgets {
+				if widget, ok := kid.(*pdf.PdfAnnotationWidget); ok {
+					widget.Parent = parentObj
+				}
+				existing.KidsF = append(existing.KidsF, kid)
+			}
+			for _, annot := range field.KidsA {
+				if widget, ok := annot.GetContext().(*pdf.PdfAnnotationWidget); ok {
+					widget.Parent = parentObj
+				}
+				existing.KidsA = append(existing.KidsA, annot)
+			}
+			continue
+		}
+
+		if strategy == FieldsMergeSameNamed && joinFields(existing, field) {
+			continue
+		}
+
+		newName := uniqueFieldName(name, byName)
+		unicommon.Log.Debug("Form field %q already exists, renaming to %q", name, newName)
+		field.T = pdfcore.MakeString(newName)
+		field.Parent = parent
+		dst = append(dst, field)
+		byName[newName] = field
+	}
+
+	return dst
+}
+
+// joinFields moves the widget annotations of terminal field `field` to `existing`, so that both share the value of
+// `existing`.  Returns false if the fields cannot be joined, i.e. they are of different types or the widgets of
+// `field` are merged into its field dictionary.
+func joinFields(existing, field *pdf.PdfField) bool {
+	if !isTerminalField(existing) || !isTerminalField(field) {
+		return false
+	}
+	if existing.FT != nil && field.FT != nil && *existing.FT != *field.FT {
+		unicommon.Log.Debug("Form field %q: types %s and %s differ, not merging", fieldName(field), *existing.FT,
+			*field.FT)
+		return false
+	}
+	if len(field.KidsF) == 0 && len(field.KidsA) == 0 {
+		unicommon.Log.Debug("Form field %q: no separate widgets, not merging", fieldName(field))
+		return false
+	}
+
+	parentObj := existing.GetContainingPdfObject()
+	for _, kid := range field.KidsF {
+		if widget, ok := kid.(*pdf.PdfAnnotationWidget); ok {
+			widget.Parent = parentObj
+		}
+		existing.KidsF = append(existing.KidsF, kid)
+	}
+	for _, annot := range field.KidsA {
+		if widget, ok := annot.GetContext().(*pdf.PdfAnnotationWidget); ok {
+			widget.Parent = parentObj
+		}
+		existing.KidsA = append(existing.KidsA, annot)
+	}
+
+	return true
+}
+
+// uniqueFieldName returns `name` with the lowest numeric suffix that is not in `taken`.
+func uniqueFieldName(name string, taken map[string]*pdf.PdfField) string {
+	for i := 2; ; i++ {
+		newName := fmt.Sprintf("%s_%d", name, i)
+		if _, has := taken[newName]; !has {
+			return newName
+		}
+	}
+}
+
+// addWidgets adds the containing objects of the form field widgets of `page` to `widgets`.
+func addWidgets(widgets map[pdfcore.PdfObject]bool, page *pdf.PdfPage) {
+	for _, annot := range page.Annotations {
+		if _, isWidget := annot.GetContext().(*pdf.PdfAnnotationWidget); isWidget {
+			widgets[annot.GetContainingPdfObject()] = true
+		}
+	}
+}
+
+// removeWidgets removes the widget annotations whose containing objects are in `removed` from `fields` and their
+// descendants, and returns the fields that are left.  Fields whose widgets are all removed are removed as well.
+func removeWidgets(fields []*pdf.PdfField, removed map[pdfcore.PdfObject]bool) []*pdf.PdfField {
+	kept := []*pdf.PdfField{}
+	for _, field := range fields {
+		// The field dictionary may be its only widget.
+		if removed[field.GetContainingPdfObject()] {
+			unicommon.Log.Debug("Form field %q: widget removed, removing the field", fieldName(field))
+			continue
+		}
+		if len(field.KidsF) == 0 && len(field.KidsA) == 0 {
+			kept = append(kept, field)
+			continue
+		}
+
+		var kidsF []pdf.PdfModel
+		for _, kid := range field.KidsF {
+			if child, ok := kid.(*pdf.PdfField); ok {
+				if len(removeWidgets([]*pdf.PdfField{child}, removed)) == 0 {
+					continue
+				}
+			} else if removed[kid.GetContainingPdfObject()] {
+				continue
+			}
+			kidsF = append(kidsF, kid)
+		}
+		var kidsA []*pdf.PdfAnnotation
+		for _, annot := range field.KidsA {
+			if !removed[annot.GetContainingPdfObject()] {
+				kidsA = append(kidsA, annot)
+			}
+		}
+
+		if len(kidsF) == 0 && len(kidsA) == 0 {
+			unicommon.Log.Debug("Form field %q: all widgets removed, removing the field", fieldName(field))
+			continue
+		}
+		field.KidsF, field.KidsA = kidsF, kidsA
+		kept = append(kept, field)
+	}
+	return kept
+}
@@ -0,0 +1,84 @@
+package pdfops
+
+import (
+	"reflect"
+	"testing"
+
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+)
+
+// testField returns a field named `name` of type `ft` ("" for none) with kids `kids`.
+func testField(name, ft string, kids ...pdf.PdfModel) *pdf.PdfField {
+	field := &pdf.PdfField{T: pdfcore.MakeString(name), KidsF: kids}
+	if ft != "" {
+		field.FT = pdfcore.MakeName(ft)
+	}
+	return field
+}
+
+// fieldNames returns the names of `fields`.
+func fieldNames(fields []*pdf.PdfField) []string {
+	names := []string{}
+	for _, field := range fields {
+		names = append(names, fieldName(field))
+	}
+	return names
+}
+
+func TestMergeFieldListsRename(t *testing.T) {
+	dst := []*pdf.PdfField{testField("name", "Tx"), testField("email", "Tx")}
+	src := []*pdf.PdfField{testField("name", "Tx"), testField("name_2", "Tx"), testField("phone", "Tx"),
+		testField("", "Tx"), testField("", "Tx")}
+
+	merged := mergeFieldLists(dst, src, nil, FieldsRenameColliding)
+	want := []string{"name", "email", "name_2", "name_2_2", "phone", "", ""}
+	if got := fieldNames(merged); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMergeFieldListsIntermediate(t *testing.T) {
+	street, city := testField("street", "Tx"), testField("city", "Tx")
+	address := testField("address", "", street, city)
+	street.Parent, city.Parent = address, address
+	srcCity, zip := testField("city", "Tx"), testField("zip", "Tx")
+	srcAddress := testField("address", "", srcCity, zip)
+	srcCity.Parent, zip.Parent = srcAddress, srcAddress
+
+	merged := mergeFieldLists([]*pdf.PdfField{address}, []*pdf.PdfField{srcAddress}, nil, FieldsRenameColliding)
+	if len(merged) != 1 || merged[0] != address {
+		t.Fatalf("got %q, want the address field only", fieldNames(merged))
+	}
+	kids := childFields(address)
+	if got, want := fieldNames(kids), []string{"street", "city", "city_2", "zip"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("address kids %q, want %q", got, want)
+	}
+	for _, kid := range kids {
+		if kid.Parent != address {
+			t.Errorf("%s: parent not moved to the merged field", fieldName(kid))
+		}
+	}
+}
+
+func TestMergeFieldListsJoin(t *testing.T) {
+	widget, srcWidget := &pdf.PdfAnnotationWidget{}, &pdf.PdfAnnotationWidget{}
+	sig := testField("sig", "Tx", widget)
+	dst := []*pdf.PdfField{sig, testField("ok", "Btn", &pdf.PdfAnnotationWidget{}), testField("date", "Tx")}
+	src := []*pdf.PdfField{
+		testField("sig", "Tx", srcWidget),
+		// Different types are not joined.
+		testField("ok", "Tx", &pdf.PdfAnnotationWidget{}),
+		// Fields merged with their only widget are not joined.
+		testField("date", "Tx"),
+	}
+
+	merged := mergeFieldLists(dst, src, nil, FieldsMergeSameNamed)
+	want := []string{"sig", "ok", "date", "ok_2", "date_2"}
+	if got := fieldNames(merged); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if len(sig.KidsF) != 2 || sig.KidsF[0] != widget || sig.KidsF[1] != srcWidget {
+		t.Errorf("sig kids %v, want both widgets", sig.KidsF)
+	}
+}
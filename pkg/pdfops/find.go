@@ -0,0 +1,218 @@
+package pdfops
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// TextMatch is an occurrence of a search pattern on a page.
+type TextMatch struct {
+	Page  int    `json:"page"`
+	Text  string `json:"text"`
+	BBox  Box    `json:"bbox"`  // In default user space.
+	Boxes []Box  `json:"boxes"` // The part of the match on each line, a single box unless it spans lines.
+
+	// BBox and Boxes on the page as displayed: after the crop box and /Rotate are applied, with the origin at the
+	// bottom left corner.  Only set by FindText.
+	DisplayBBox  Box   `json:"display_bbox"`
+	DisplayBoxes []Box `json:"display_boxes"`
+}
+
+// FindOptions are the search options of FindText.
+type FindOptions struct {
+	Regexp     bool                 // The pattern is a regular expression (RE2 syntax) instead of a literal string.
+	IgnoreCase bool                 // Match case insensitively.
+	Pages      *pagerange.Selection // Pages to search, nil for all.
+}
+
+// CompilePattern returns the regular expression for search pattern `pattern`.
+func CompilePattern(pattern string, findOpts FindOptions) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, errors.New("Empty search pattern")
+	}
+	if !findOpts.Regexp {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if findOpts.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid pattern: %v", err)
+	}
+	return re, nil
+}
+
+// FindText returns the occurrences of `pattern` on the pages of `inputPath` selected by `findOpts`.
+func FindText(inputPath, pattern string, findOpts FindOptions, opts Options) ([]TextMatch, error) {
+	re, err := CompilePattern(pattern, findOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return nil, err
+	}
+
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	pageNums, err := findOpts.Pages.Select(pdfReader)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []TextMatch{}
+	for _, pageNum := range pageNums {
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return nil, err
+		}
+
+		runs, err := PageTextRuns(page, pageNum)
+		if err != nil {
+			return nil, fmt.Errorf("Page %d: %v", pageNum, err)
+		}
+		pageMatches := FindInRuns(runs, re)
+		if len(pageMatches) == 0 {
+			continue
+		}
+
+		box, rotate, err := displayedBox(page)
+		if err != nil {
+			return nil, err
+		}
+		toUser, _, _ := box.displayMatrix(rotate)
+		toDisplay, ok := toUser.inverse()
+		if !ok {
+			return nil, fmt.Errorf("Page %d: invalid page box", pageNum)
+		}
+		for i := range pageMatches {
+			pageMatches[i].setDisplayBoxes(toDisplay)
+		}
+		matches = append(matches, pageMatches...)
+	}
+
+	return matches, nil
+}
+
+// setDisplayBoxes sets the display space boxes of `match` from its user space boxes with `toDisplay`, the matrix
+// that maps the user space of its page to the page as displayed.
+func (match *TextMatch) setDisplayBoxes(toDisplay matrix) {
+	display := func(b Box) Box {
+		return toDisplay.transformBox(b[0], b[1], b[2], b[3])
+	}
+	match.DisplayBBox = display(match.BBox)
+	match.DisplayBoxes = make([]Box, len(match.Boxes))
+	for i, b := range match.Boxes {
+		match.DisplayBoxes[i] = display(b)
+	}
+}
+
+// FindInRuns returns the occurrences of `re` in text runs `runs`.  The runs of a page are joined in content stream
+// order: runs on the same line are separated by a space if there is a gap between them and runs on different lines
+// by a newline.  Matches do not span pages.
+func FindInRuns(runs []*TextRun, re *regexp.Regexp) []TextMatch {
+	matches := []TextMatch{}
+	for start := 0; start < len(runs); {
+		end := start
+		for end < len(runs) && runs[end].Page == runs[start].Page {
+			end++
+		}
+		matches = append(matches, newPageText(runs[start:end]).find(re)...)
+		start = end
+	}
+	return matches
+}
+
+// pageText is the searchable text of a page.
+type pageText struct {
+	pageNum int
+	text    strings.Builder
+	chars   []*TextChar // Characters of the text, nil for the separators between runs.
+	offsets []int       // Byte offset of each entry of chars in text.
+	lines   []int       // Line number of each entry of chars.
+}
+
+// newPageText joins `runs`, which are on the same page.
+func newPageText(runs []*TextRun) *pageText {
+	pt := &pageText{}
+	line := 0
+	for i, run := range runs {
+		pt.pageNum = run.Page
+		if i > 0 {
+			prev := runs[i-1]
+			switch {
+			case !onSameLine([]*TextRun{prev}, run) || run.BBox[0] < prev.BBox[0]:
+				line++
+				pt.add("\n", nil, line)
+			case run.BBox[0]-prev.BBox[2] > wordSpace*run.FontSize &&
+				!strings.HasSuffix(prev.Text, " ") && !strings.HasPrefix(run.Text, " "):
+				pt.add(" ", nil, line)
+			}
+		}
+		for j := range run.Chars {
+			pt.add(run.Chars[j].Text, &run.Chars[j], line)
+		}
+	}
+	return pt
+}
+
+// add appends `text` of character `c` (nil for separators) on line `line`.
+func (pt *pageText) add(text string, c *TextChar, line int) {
+	if text == "" {
+		return
+	}
+	pt.offsets = append(pt.offsets, pt.text.Len())
+	pt.lines = append(pt.lines, line)
+	pt.chars = append(pt.chars, c)
+	pt.text.WriteString(text)
+}
+
+// find returns the matches of `re` in the page text.
+func (pt *pageText) find(re *regexp.Regexp) []TextMatch {
+	matches := []TextMatch{}
+	text := pt.text.String()
+
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		match := TextMatch{Page: pt.pageNum, Text: text[loc[0]:loc[1]], Boxes: []Box{}}
+
+		line := -1
+		for i, offset := range pt.offsets {
+			if offset < loc[0] || offset >= loc[1] {
+				continue
+			}
+			c := pt.chars[i]
+			if c == nil {
+				// Separators have no position.
+				continue
+			}
+			if pt.lines[i] != line || len(match.Boxes) == 0 {
+				line = pt.lines[i]
+				match.Boxes = append(match.Boxes, c.BBox)
+			}
+			last := len(match.Boxes) - 1
+			match.Boxes[last] = match.Boxes[last].union(c.BBox)
+		}
+		if len(match.Boxes) == 0 {
+			continue
+		}
+
+		match.BBox = match.Boxes[0]
+		for _, box := range match.Boxes[1:] {
+			match.BBox = match.BBox.union(box)
+		}
+		matches = append(matches, match)
+	}
+
+	return matches
+}
@@ -0,0 +1,295 @@
+package pdfops
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"unicode/utf16"
+
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+)
+
+// SchemaVersion is the version of the structured (JSON/YAML) representation of PdfProperties, PageProperties and
+// SecurityInfo.  It is increased whenever fields are renamed or removed or their meaning changes; adding fields
+// does not change it.
+const SchemaVersion = 1
+
+// PdfProperties holds basic properties of a PDF file.
+type PdfProperties struct {
+	Path        string            `json:"path"`
+	PdfVersion  string            `json:"pdf_version,omitempty"`
+	IsEncrypted bool              `json:"encrypted"`
+	CanView     bool              `json:"viewable"` // Is the document viewable with the given passwords?
+	Auth        opener.AuthMethod `json:"auth"`     // How access to the document was obtained.
+	NumPages    int               `json:"num_pages"`
+
+	// Encryption is only set for encrypted documents that could be opened.
+	Encryption *EncryptionProperties `json:"encryption,omitempty"`
+
+	// Info holds the entries of the document information dictionary.
+	Info map[string]string `json:"info,omitempty"`
+}
+
+// EncryptionProperties describes the encryption of a document and the permissions granted by the password that
+// was used to open it.
+type EncryptionProperties struct {
+	Method      string       `json:"method"`
+	Permissions *Permissions `json:"permissions,omitempty"`
+}
+
+// Permissions are the access permissions of an encrypted document.
+type Permissions struct {
+	Print                bool `json:"print"`
+	PrintHighQuality     bool `json:"print_high_quality"`
+	Modify               bool `json:"modify"`
+	ExtractGraphics      bool `json:"extract"`
+	ExtractAccessibility bool `json:"extract_accessibility"`
+	Annotate             bool `json:"annotate"`
+	FillForms            bool `json:"fill_forms"`
+	Assemble             bool `json:"assemble"`
+}
+
+func newPermissions(p pdfcore.AccessPermissions) *Permissions {
+	return &Permissions{
+		Print:                p.Printing,
+		PrintHighQuality:     p.FullPrintQuality,
+		Modify:               p.Modify,
+		ExtractGraphics:      p.ExtractGraphics,
+		ExtractAccessibility: p.DisabilityExtract,
+		Annotate:             p.Annotate,
+		FillForms:            p.FillForms,
+		Assemble:             p.RotateInsert,
+	}
+}
+
+// GetPdfProperties returns the number of pages, encryption status, version and document information of
+// `inputPath`.  Encrypted documents that cannot be opened with the passwords in `opts` are reported with CanView
+// false and no page count rather than as an error.
+func GetPdfProperties(inputPath string, opts Options) (*PdfProperties, error) {
+	ret := PdfProperties{Path: inputPath}
+
+	version, err := headerVersion(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	ret.PdfVersion = version
+
+	doc, err := opener.Open(inputPath, opts.Open)
+	if opener.IsWrongPassword(err) {
+		ret.IsEncrypted = true
+		return &ret, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	ret.IsEncrypted = doc.Auth != opener.AuthNotEncrypted
+	ret.CanView = true
+	ret.Auth = doc.Auth
+
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+	ret.NumPages = numPages
+
+	if ret.IsEncrypted {
+		ret.Encryption = &EncryptionProperties{Method: pdfReader.GetEncryptionMethod()}
+
+		_, perms, err := pdfReader.CheckAccessRights([]byte(doc.Password))
+		if err != nil {
+			return nil, err
+		}
+		ret.Encryption.Permissions = newPermissions(perms)
+	}
+
+	trailer, err := pdfReader.GetTrailer()
+	if err != nil {
+		return nil, err
+	}
+	if v := catalogVersion(trailer); v != "" {
+		// The catalog overrides the header version when it is newer (PDF 1.4+ incremental updates).
+		if v > ret.PdfVersion {
+			ret.PdfVersion = v
+		}
+	}
+	ret.Info = infoDictEntries(getDict(trailer.Get("Info")))
+
+	return &ret, nil
+}
+
+var reHeaderVersion = regexp.MustCompile(`%PDF-(\d\.\d)`)
+
+// headerVersion returns the PDF version in the header of file `inputPath`, or "" if there is none.
+func headerVersion(inputPath string) (string, error) {
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// The header is at the start of the file, but some files have junk before it.
+	buf := make([]byte, 1024)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	m := reHeaderVersion.FindSubmatch(buf[:n])
+	if m == nil {
+		return "", nil
+	}
+	return string(m[1]), nil
+}
+
+// catalogVersion returns the Version entry of the document catalog referred to by `trailer`, if any.
+func catalogVersion(trailer *pdfcore.PdfObjectDictionary) string {
+	catalog := getDict(trailer.Get("Root"))
+	if catalog == nil {
+		return ""
+	}
+	version, ok := pdfcore.TraceToDirectObject(catalog.Get("Version")).(*pdfcore.PdfObjectName)
+	if !ok {
+		return ""
+	}
+	return string(*version)
+}
+
+// infoDictEntries returns the entries of document information dictionary `info` as text.
+func infoDictEntries(info *pdfcore.PdfObjectDictionary) map[string]string {
+	return infoTexts(infoDictObjects(info))
+}
+
+// infoDictObjects returns the entries of document information dictionary `info` as direct objects.
+func infoDictObjects(info *pdfcore.PdfObjectDictionary) map[string]pdfcore.PdfObject {
+	if info == nil {
+		return nil
+	}
+
+	entries := map[string]pdfcore.PdfObject{}
+	for _, key := range info.Keys() {
+		if obj := pdfcore.TraceToDirectObject(info.Get(key)); obj != nil {
+			entries[string(key)] = obj
+		}
+	}
+	return entries
+}
+
+// infoTexts returns information dictionary entries `entries` as text.
+func infoTexts(entries map[string]pdfcore.PdfObject) map[string]string {
+	if entries == nil {
+		return nil
+	}
+
+	texts := map[string]string{}
+	for key, obj := range entries {
+		switch t := obj.(type) {
+		case *pdfcore.PdfObjectString:
+			texts[key] = decodeTextString(string(*t))
+		case *pdfcore.PdfObjectName:
+			texts[key] = string(*t)
+		default:
+			texts[key] = t.DefaultWriteString()
+		}
+	}
+	return texts
+}
+
+// decodeTextString decodes PDF text string `s`, which is either UTF-16BE with a byte order mark or
+// PDFDocEncoding.  PDFDocEncoding is treated as Latin-1, which it matches for all printable ASCII and most
+// accented characters.
+func decodeTextString(s string) string {
+	b := []byte(s)
+	if bytes.HasPrefix(b, []byte{0xfe, 0xff}) {
+		b = b[2:]
+		units := make([]uint16, 0, len(b)/2)
+		for i := 0; i+1 < len(b); i += 2 {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// Box is a page boundary box as [llx lly urx ury].
+type Box [4]float64
+
+func newBox(r *pdf.PdfRectangle) Box {
+	return Box{r.Llx, r.Lly, r.Urx, r.Ury}
+}
+
+// PageProperties holds the geometry of a single page.
+type PageProperties struct {
+	PageNum  int     `json:"page"`
+	Rotate   int64   `json:"rotate"`
+	MediaBox Box     `json:"media_box"`
+	CropBox  Box     `json:"crop_box"` // The MediaBox if the page has no CropBox.
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+}
+
+// GetPageProperties returns the properties of page `pageNum` of `inputPath`, or of all pages if `pageNum` is
+// not a valid page number.
+func GetPageProperties(inputPath string, pageNum int, opts Options) ([]PageProperties, error) {
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return nil, err
+	}
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+
+	pageNums := []int{pageNum}
+	// If invalid pagenum, use all pages.
+	if pageNum <= 0 || pageNum > numPages {
+		pageNums = []int{}
+		for i := 0; i < numPages; i++ {
+			pageNums = append(pageNums, i+1)
+		}
+	}
+
+	props := []PageProperties{}
+	for _, num := range pageNums {
+		page, err := pdfReader.GetPage(num)
+		if err != nil {
+			return nil, err
+		}
+
+		mBox, err := page.GetMediaBox()
+		if err != nil {
+			return nil, err
+		}
+
+		p := PageProperties{
+			PageNum:  num,
+			MediaBox: newBox(mBox),
+			CropBox:  newBox(mBox),
+			Width:    mBox.Urx - mBox.Llx,
+			Height:   mBox.Ury - mBox.Lly,
+		}
+		if page.CropBox != nil {
+			p.CropBox = newBox(page.CropBox)
+		}
+		if page.Rotate != nil {
+			p.Rotate = *page.Rotate
+		}
+		props = append(props, p)
+	}
+
+	return props, nil
+}
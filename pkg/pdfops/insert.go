@@ -0,0 +1,164 @@
+package pdfops
+
+import (
+	"errors"
+	"fmt"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// InsertOptions are the options of InsertPdf.
+type InsertOptions struct {
+	After   int // Page of the target document after which the pages are inserted, 0 for before the first page.
+	Replace int // Number of pages of the target document after After that are replaced by the inserted pages.
+
+	Pages *pagerange.Selection // Pages of the source document to insert, nil for all.
+
+	// FieldNames selects how form fields of the source document whose names are taken in the target are handled.
+	FieldNames FieldNameStrategy
+
+	// KeepDuplicateResources disables sharing byte-identical fonts and images of the documents in the output.
+	KeepDuplicateResources bool
+}
+
+// InsertPdf inserts the pages of `sourcePath` selected by `insertOpts.Pages` into `targetPath` after page
+// `insertOpts.After`, replacing `insertOpts.Replace` pages, and writes the result to `outputPath`.  The form,
+// outlines and optional content of the target are kept.  The form fields and optional content groups of the
+// inserted pages are merged into them like MergePdf does; the source outlines are not copied.  Bookmarks of
+// replaced pages go to the first inserted page.
+func InsertPdf(targetPath, sourcePath, outputPath string, insertOpts InsertOptions, opts Options) error {
+	if insertOpts.After < 0 || insertOpts.Replace < 0 {
+		return errors.New("Invalid insertion position")
+	}
+
+	doc, err := opener.Open(targetPath, opts.Open)
+	if err != nil {
+		return err
+	}
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	srcDoc, err := opener.Open(sourcePath, opts.Open)
+	if err != nil {
+		return err
+	}
+	defer srcDoc.Close()
+	srcReader := srcDoc.Reader
+
+	numPages := len(pdfReader.PageList)
+	if insertOpts.After+insertOpts.Replace > numPages {
+		return fmt.Errorf("Page %d out of range (%s has %d pages)", insertOpts.After+insertOpts.Replace,
+			targetPath, numPages)
+	}
+
+	srcPageNums, err := insertOpts.Pages.Select(srcReader)
+	if err != nil {
+		return err
+	}
+	if len(srcPageNums) == 0 {
+		return fmt.Errorf("No pages selected by %s", insertOpts.Pages)
+	}
+	inserted := map[int]bool{}
+	for _, pageNum := range srcPageNums {
+		inserted[pageNum] = true
+	}
+
+	isReplaced := func(pageNum int) bool {
+		return pageNum > insertOpts.After && pageNum <= insertOpts.After+insertOpts.Replace
+	}
+
+	// The output pages: the target pages up to After, the inserted pages and the rest of the target pages.
+	pages := []*pdf.PdfPage{}
+	pages = append(pages, pdfReader.PageList[:insertOpts.After]...)
+	for _, pageNum := range srcPageNums {
+		pages = append(pages, srcReader.PageList[pageNum-1])
+	}
+	pages = append(pages, pdfReader.PageList[insertOpts.After+insertOpts.Replace:]...)
+
+	pdfWriter := pdf.NewPdfWriter()
+
+	ocProps, err := pdfReader.GetOCProperties()
+	if err != nil {
+		return err
+	}
+	srcOCProps, err := srcReader.GetOCProperties()
+	if err != nil {
+		return err
+	}
+	pdfWriter.SetOCProperties(mergeOCProperties(ocProps, srcOCProps))
+
+	dedup := newResourceDeduper()
+	for _, page := range pages {
+		if !insertOpts.KeepDuplicateResources {
+			dedup.dedupPage(page)
+		}
+		if err := pdfWriter.AddPage(page); err != nil {
+			return err
+		}
+	}
+	if dedup.replaced > 0 {
+		unicommon.Log.Info("Shared %d duplicate fonts and images", dedup.replaced)
+	}
+
+	// The fields of the pages that are left out are removed from both forms before merging them.
+	form := pdfReader.AcroForm
+	if form != nil && form.Fields != nil {
+		removed := map[pdfcore.PdfObject]bool{}
+		for i, page := range pdfReader.PageList {
+			if isReplaced(i + 1) {
+				addWidgets(removed, page)
+			}
+		}
+		fields := removeWidgets(*form.Fields, removed)
+		form.Fields = &fields
+	}
+	if srcForm := srcReader.AcroForm; srcForm != nil {
+		if srcForm.Fields != nil {
+			removed := map[pdfcore.PdfObject]bool{}
+			for i, page := range srcReader.PageList {
+				if !inserted[i+1] {
+					addWidgets(removed, page)
+				}
+			}
+			fields := removeWidgets(*srcForm.Fields, removed)
+			srcForm.Fields = &fields
+		}
+		// The XFA of the source describes fields that are not all inserted.
+		srcForm.XFA = nil
+
+		if form == nil {
+			form = srcForm
+		} else {
+			mergeOpts := MergeOptions{FieldNames: insertOpts.FieldNames}
+			if form, err = mergeForms(form, srcForm, 2, mergeOpts); err != nil {
+				return err
+			}
+		}
+	}
+	if form != nil {
+		if err := pdfWriter.SetForms(form); err != nil {
+			return err
+		}
+	}
+
+	pageObj := func(pageNum int) pdfcore.PdfObject {
+		if pageNum < 1 || pageNum > numPages {
+			return nil
+		}
+		if isReplaced(pageNum) {
+			return srcReader.PageList[srcPageNums[0]-1].GetPageAsIndirectObject()
+		}
+		return pdfReader.PageList[pageNum-1].GetPageAsIndirectObject()
+	}
+	items := copyOutlineItems(pdfReader, outlineItems(pdfReader.GetOutlineTree()), pageObj)
+	if len(items) > 0 {
+		pdfWriter.AddOutlineTree(&newOutlineTree(items).PdfOutlineTreeNode)
+	}
+
+	return writePdf(&pdfWriter, outputPath)
+}
@@ -0,0 +1,376 @@
+package pdfops
+
+import (
+	"fmt"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcontent "github.com/unidoc/unidoc/pdf/contentstream"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+)
+
+// Layer is an optional content group (OCG) that stamps and watermarks are put in so that viewers can show or hide
+// them, and that can be removed with RemoveLayerPdf.
+type Layer struct {
+	Name         string // Shown in the viewer's layers panel.
+	HideOnScreen bool   // Hidden when the document is viewed.
+	HideInPrint  bool   // Hidden when the document is printed.
+}
+
+// newOCG returns an optional content group for `layer`.  Its usage entries tell viewers whether to show it on
+// screen and in print.
+func newOCG(layer *Layer) *pdfcore.PdfIndirectObject {
+	state := func(hide bool) pdfcore.PdfObject {
+		if hide {
+			return pdfcore.MakeName("OFF")
+		}
+		return pdfcore.MakeName("ON")
+	}
+	viewUsage := pdfcore.MakeDict()
+	viewUsage.Set("ViewState", state(layer.HideOnScreen))
+	printUsage := pdfcore.MakeDict()
+	printUsage.Set("PrintState", state(layer.HideInPrint))
+	usage := pdfcore.MakeDict()
+	usage.Set("View", viewUsage)
+	usage.Set("Print", printUsage)
+
+	ocg := pdfcore.MakeDict()
+	ocg.Set("Type", pdfcore.MakeName("OCG"))
+	ocg.Set("Name", makeTextString(layer.Name))
+	ocg.Set("Usage", usage)
+	return pdfcore.MakeIndirectObject(ocg)
+}
+
+// addOCG adds `ocg` to the optional content properties `ocProps` of a document, which may be nil, and returns the
+// result.  The group is added to the layers panel and its usage is applied on viewing and printing.
+func addOCG(ocProps pdfcore.PdfObject, ocg *pdfcore.PdfIndirectObject, layer *Layer) pdfcore.PdfObject {
+	props := getDict(ocProps)
+	if props == nil {
+		props = pdfcore.MakeDict()
+		ocProps = props
+	}
+	appendArray(props, "OCGs", ocg)
+
+	d := getDict(props.Get("D"))
+	if d == nil {
+		d = pdfcore.MakeDict()
+		props.Set("D", d)
+	}
+	appendArray(d, "Order", ocg)
+	if layer.HideOnScreen {
+		appendArray(d, "OFF", ocg)
+	}
+	for _, event := range []string{"View", "Print"} {
+		as := pdfcore.MakeDict()
+		as.Set("Event", pdfcore.MakeName(event))
+		as.Set("OCGs", pdfcore.MakeArray(ocg))
+		as.Set("Category", pdfcore.MakeArray(pdfcore.MakeName(event)))
+		appendArray(d, "AS", as)
+	}
+
+	return ocProps
+}
+
+// mergeOCProperties adds the optional content groups of `ocProps2` to optional content properties `ocProps`,
+// either of which may be nil, and returns the result.  The groups keep their place in the layers panel and those
+// that are off by default stay off.
+func mergeOCProperties(ocProps, ocProps2 pdfcore.PdfObject) pdfcore.PdfObject {
+	props2 := getDict(ocProps2)
+	if props2 == nil {
+		return ocProps
+	}
+	props := getDict(ocProps)
+	if props == nil {
+		return ocProps2
+	}
+
+	if ocgs, ok := pdfcore.TraceToDirectObject(props2.Get("OCGs")).(*pdfcore.PdfObjectArray); ok {
+		for _, ocg := range *ocgs {
+			appendArray(props, "OCGs", ocg)
+		}
+	}
+
+	d2 := getDict(props2.Get("D"))
+	if d2 == nil {
+		return ocProps
+	}
+	d := getDict(props.Get("D"))
+	if d == nil {
+		d = pdfcore.MakeDict()
+		props.Set("D", d)
+	}
+	for _, key := range []pdfcore.PdfObjectName{"Order", "OFF"} {
+		if arr, ok := pdfcore.TraceToDirectObject(d2.Get(key)).(*pdfcore.PdfObjectArray); ok {
+			for _, obj := range *arr {
+				appendArray(d, key, obj)
+			}
+		}
+	}
+	return ocProps
+}
+
+// appendArray appends `obj` to array `key` of `dict`, which is created if needed.
+func appendArray(dict *pdfcore.PdfObjectDictionary, key pdfcore.PdfObjectName, obj pdfcore.PdfObject) {
+	if arr, ok := pdfcore.TraceToDirectObject(dict.Get(key)).(*pdfcore.PdfObjectArray); ok {
+		*arr = append(*arr, obj)
+		return
+	}
+	dict.Set(key, pdfcore.MakeArray(obj))
+}
+
+// markOptionalContent returns `content` marked as belonging to optional content group `ocg`, which is added to the
+// Properties resources of `page`.
+func markOptionalContent(page *pdf.PdfPage, content string, ocg *pdfcore.PdfIndirectObject) string {
+	if page.Resources == nil {
+		page.Resources = pdf.NewPdfPageResources()
+	}
+	props := getDict(page.Resources.Properties)
+	if props == nil {
+		props = pdfcore.MakeDict()
+		page.Resources.Properties = props
+	}
+
+	name := pdfcore.PdfObjectName("OC")
+	for i := 2; props.Get(name) != nil; i++ {
+		name = pdfcore.PdfObjectName(fmt.Sprintf("OC%d", i))
+	}
+	props.Set(name, ocg)
+
+	return fmt.Sprintf("/OC /%s BDC\n%s\nEMC\n", name, content)
+}
+
+// RemoveLayerPdf removes the optional content groups named `name` from `inputPath` and writes the result to
+// `outputPath`.  The page content marked as belonging to the groups, such as watermarks added with a Layer, is
+// removed along with the resources only it used.  Returns the number of pages changed.
+func RemoveLayerPdf(inputPath, outputPath, name string, opts Options) (int, error) {
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return 0, err
+	}
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	ocProps, err := pdfReader.GetOCProperties()
+	if err != nil {
+		return 0, err
+	}
+	removed, ocProps := removeOCGs(ocProps, name)
+	if len(removed) == 0 {
+		return 0, fmt.Errorf("No layer named %q", name)
+	}
+
+	// First remove the layer's content from all pages, then the resources that no page uses any more, as
+	// resource dictionaries can be shared by pages.
+	used := resourceNames{}
+	unused := resourceNames{}
+	numChanged := 0
+	for i, page := range pdfReader.PageList {
+		changed, err := removeLayerContent(page, removed, used, unused)
+		if err != nil {
+			return 0, fmt.Errorf("Page %d: %v", i+1, err)
+		}
+		if changed {
+			numChanged++
+		}
+	}
+	for dict, names := range unused {
+		for name := range names {
+			if !used[dict][name] {
+				unicommon.Log.Debug("Removing unused resource %s", name)
+				dict.Remove(name)
+			}
+		}
+	}
+
+	pdfWriter := pdf.NewPdfWriter()
+	pdfWriter.SetOCProperties(ocProps)
+	for _, page := range pdfReader.PageList {
+		if err := pdfWriter.AddPage(page); err != nil {
+			return 0, err
+		}
+	}
+	if err := pdfWriter.SetForms(pdfReader.AcroForm); err != nil {
+		return 0, err
+	}
+	items := copyOutlineItems(pdfReader, outlineItems(pdfReader.GetOutlineTree()), readerPageObjects(pdfReader))
+	if len(items) > 0 {
+		pdfWriter.AddOutlineTree(&newOutlineTree(items).PdfOutlineTreeNode)
+	}
+
+	return numChanged, writePdf(&pdfWriter, outputPath)
+}
+
+// removeOCGs removes the optional content groups named `name` from the optional content properties `ocProps`.
+// Returns the groups removed and the remaining properties, nil if no groups are left.
+func removeOCGs(ocProps pdfcore.PdfObject, name string) (map[pdfcore.PdfObject]bool, pdfcore.PdfObject) {
+	props := getDict(ocProps)
+	if props == nil {
+		return nil, ocProps
+	}
+	arr, ok := pdfcore.TraceToDirectObject(props.Get("OCGs")).(*pdfcore.PdfObjectArray)
+	if !ok {
+		return nil, ocProps
+	}
+
+	removed := map[pdfcore.PdfObject]bool{}
+	for _, ocg := range *arr {
+		if isNamedOCG(ocg, name) {
+			removed[ocg] = true
+		}
+	}
+	if len(removed) == 0 {
+		return nil, ocProps
+	}
+
+	if filterOCGRefs(props, removed) {
+		return removed, nil
+	}
+	return removed, ocProps
+}
+
+// filterOCGRefs removes the references to `ocgs` from the arrays in `obj`, which is part of the optional content
+// properties.  Arrays that become empty are removed, and so are dictionaries whose OCGs entry becomes empty, such
+// as usage application dictionaries for the removed groups only.  Returns true if `obj` is to be removed.
+func filterOCGRefs(obj pdfcore.PdfObject, ocgs map[pdfcore.PdfObject]bool) bool {
+	switch t := pdfcore.TraceToDirectObject(obj).(type) {
+	case *pdfcore.PdfObjectDictionary:
+		hadOCGs := t.Get("OCGs") != nil
+		for _, key := range t.Keys() {
+			if filterOCGRefs(t.Get(key), ocgs) {
+				t.Remove(key)
+			}
+		}
+		return hadOCGs && t.Get("OCGs") == nil
+	case *pdfcore.PdfObjectArray:
+		if len(*t) == 0 {
+			return false
+		}
+		kept := pdfcore.PdfObjectArray{}
+		for _, elem := range *t {
+			if ocgs[elem] {
+				continue
+			}
+			// Groups are not descended into, only the arrays and dictionaries that refer to them.
+			if _, ok := elem.(*pdfcore.PdfIndirectObject); !ok && filterOCGRefs(elem, ocgs) {
+				continue
+			}
+			kept = append(kept, elem)
+		}
+		*t = kept
+		return len(kept) == 0
+	}
+	return false
+}
+
+// isNamedOCG returns true if `obj` is an optional content group named `name`.
+func isNamedOCG(obj pdfcore.PdfObject, name string) bool {
+	dict := getDict(obj)
+	if dict == nil {
+		return false
+	}
+	if t, ok := dict.Get("Type").(*pdfcore.PdfObjectName); !ok || *t != "OCG" {
+		return false
+	}
+	s, ok := pdfcore.TraceToDirectObject(dict.Get("Name")).(*pdfcore.PdfObjectString)
+	return ok && decodeTextString(string(*s)) == name
+}
+
+// resourceNames holds names in resource dictionaries.
+type resourceNames map[*pdfcore.PdfObjectDictionary]map[pdfcore.PdfObjectName]bool
+
+func (rn resourceNames) add(dict *pdfcore.PdfObjectDictionary, name pdfcore.PdfObjectName) {
+	if dict == nil {
+		return
+	}
+	if rn[dict] == nil {
+		rn[dict] = map[pdfcore.PdfObjectName]bool{}
+	}
+	rn[dict][name] = true
+}
+
+// addOperation adds the resource used by `op`, if any, to `rn`.
+func (rn resourceNames) addOperation(resources *pdf.PdfPageResources, op *pdfcontent.ContentStreamOperation) {
+	if len(op.Params) == 0 {
+		return
+	}
+	var category pdfcore.PdfObject
+	param := op.Params[0]
+	switch op.Operand {
+	case "Tf":
+		category = resources.Font
+	case "Do":
+		category = resources.XObject
+	case "gs":
+		category = resources.ExtGState
+	case "BDC", "DP":
+		category = resources.Properties
+		if len(op.Params) < 2 {
+			return
+		}
+		param = op.Params[1]
+	default:
+		return
+	}
+	if name, ok := param.(*pdfcore.PdfObjectName); ok {
+		rn.add(getDict(category), *name)
+	}
+}
+
+// removeLayerContent removes the content of `page` marked as belonging to one of `ocgs`.  The resources used by
+// the remaining content are added to `used` and those used by the removed content to `unused`.  Returns true if
+// the page was changed.
+func removeLayerContent(page *pdf.PdfPage, ocgs map[pdfcore.PdfObject]bool, used, unused resourceNames) (bool,
+	error) {
+	if page.Resources == nil {
+		return false, nil
+	}
+	props := getDict(page.Resources.Properties)
+
+	contents, err := page.GetAllContentStreams()
+	if err != nil {
+		return false, err
+	}
+	operations, err := pdfcontent.NewContentStreamParser(contents).Parse()
+	if err != nil {
+		return false, err
+	}
+
+	kept := pdfcontent.ContentStreamOperations{}
+	depth := 0     // Marked content nesting depth.
+	skipDepth := 0 // Depth of the marked content being removed, 0 if none.
+	for _, op := range *operations {
+		switch op.Operand {
+		case "BMC", "BDC":
+			depth++
+			if skipDepth == 0 && op.Operand == "BDC" && len(op.Params) == 2 && props != nil {
+				tag, _ := op.Params[0].(*pdfcore.PdfObjectName)
+				name, _ := op.Params[1].(*pdfcore.PdfObjectName)
+				if tag != nil && *tag == "OC" && name != nil && ocgs[props.Get(*name)] {
+					skipDepth = depth
+				}
+			}
+		case "EMC":
+			if depth == skipDepth {
+				skipDepth = 0
+				depth--
+				unused.addOperation(page.Resources, op)
+				continue
+			}
+			depth--
+		}
+
+		if skipDepth > 0 {
+			unused.addOperation(page.Resources, op)
+			continue
+		}
+		used.addOperation(page.Resources, op)
+		kept = append(kept, op)
+	}
+
+	if len(kept) == len(*operations) {
+		return false, nil
+	}
+	return true, page.SetContentStreams([]string{string(kept.Bytes())}, pdfcore.NewFlateEncoder())
+}
@@ -0,0 +1,92 @@
+package pdfops
+
+import (
+	"reflect"
+	"testing"
+
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+)
+
+func TestFilterOCGRefs(t *testing.T) {
+	ocgA := pdfcore.MakeIndirectObject(pdfcore.MakeDict())
+	ocgB := pdfcore.MakeIndirectObject(pdfcore.MakeDict())
+	// describe returns the groups in array `obj` as A and B and the other elements as their type.
+	describe := func(obj pdfcore.PdfObject) []string {
+		arr, ok := pdfcore.TraceToDirectObject(obj).(*pdfcore.PdfObjectArray)
+		if !ok {
+			return nil
+		}
+		elems := []string{}
+		for _, elem := range *arr {
+			switch elem {
+			case ocgA:
+				elems = append(elems, "A")
+			case ocgB:
+				elems = append(elems, "B")
+			default:
+				elems = append(elems, reflect.TypeOf(elem).String())
+			}
+		}
+		return elems
+	}
+
+	usage := func(event string, ocgs ...pdfcore.PdfObject) *pdfcore.PdfObjectDictionary {
+		dict := pdfcore.MakeDict()
+		dict.Set("Event", pdfcore.MakeName(event))
+		dict.Set("OCGs", pdfcore.MakeArray(ocgs...))
+		dict.Set("Category", pdfcore.MakeArray(pdfcore.MakeName(event)))
+		return dict
+	}
+	config := pdfcore.MakeDict()
+	config.Set("Order", pdfcore.MakeArray(ocgA, ocgB, pdfcore.MakeArray(pdfcore.MakeString("Group"), ocgA)))
+	config.Set("ON", pdfcore.MakeArray(ocgA))
+	config.Set("OFF", pdfcore.MakeArray(ocgB))
+	config.Set("AS", pdfcore.MakeArray(usage("View", ocgA), usage("Print", ocgA, ocgB)))
+	props := pdfcore.MakeDict()
+	props.Set("OCGs", pdfcore.MakeArray(ocgA, ocgB))
+	props.Set("D", config)
+
+	if filterOCGRefs(props, map[pdfcore.PdfObject]bool{ocgA: true}) {
+		t.Fatal("properties with group B left are removed")
+	}
+
+	if got, want := describe(props.Get("OCGs")), []string{"B"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OCGs %v, want %v", got, want)
+	}
+	if got, want := describe(config.Get("Order")), []string{"B", "*core.PdfObjectArray"}; !reflect.DeepEqual(got,
+		want) {
+		t.Errorf("Order %v, want %v", got, want)
+	}
+	group := (*config.Get("Order").(*pdfcore.PdfObjectArray))[1]
+	if got, want := describe(group), []string{"*core.PdfObjectString"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Order group %v, want %v", got, want)
+	}
+	if config.Get("ON") != nil {
+		t.Errorf("empty ON array not removed")
+	}
+	if got, want := describe(config.Get("OFF")), []string{"B"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OFF %v, want %v", got, want)
+	}
+
+	// The usage dictionary for A only goes, the one for A and B keeps B.
+	as, ok := config.Get("AS").(*pdfcore.PdfObjectArray)
+	if !ok || len(*as) != 1 {
+		t.Fatalf("AS %v, want a single usage dictionary", config.Get("AS"))
+	}
+	printUsage := (*as)[0].(*pdfcore.PdfObjectDictionary)
+	if event := printUsage.Get("Event").(*pdfcore.PdfObjectName); *event != "Print" {
+		t.Errorf("usage dictionary for %s kept, want Print", *event)
+	}
+	if got, want := describe(printUsage.Get("OCGs")), []string{"B"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Print usage OCGs %v, want %v", got, want)
+	}
+	if got, want := describe(printUsage.Get("Category")), []string{"*core.PdfObjectName"}; !reflect.DeepEqual(got,
+		want) {
+		t.Errorf("Print usage Category %v, want %v", got, want)
+	}
+
+	// Removing the last group removes the properties.
+	if !filterOCGRefs(props, map[pdfcore.PdfObject]bool{ocgB: true}) {
+		t.Errorf("properties without groups are kept")
+	}
+}
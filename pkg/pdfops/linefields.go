@@ -0,0 +1,212 @@
+package pdfops
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// DefaultLinePattern matches the underscore lines that mark where a document is to be signed or filled in.
+const DefaultLinePattern = "_{5,}"
+
+// LineFieldType is the type of the form fields created by AddLineFields.
+type LineFieldType int
+
+const (
+	LineFieldSignature LineFieldType = iota // Signature fields.
+	LineFieldText                           // Single line text fields.
+)
+
+// LineFieldOptions are the options of AddLineFields.
+type LineFieldOptions struct {
+	Type    LineFieldType
+	Pattern string               // Regular expression of the lines, DefaultLinePattern if empty.
+	Pages   *pagerange.Selection // Pages to search, nil for all.
+
+	// NameTemplate is the name of the fields, with placeholders {n} for the field number and {page} for the page
+	// number.  "signature_{n}" or "text_{n}" if empty.
+	NameTemplate string
+
+	// Height is the height of the fields.  If 0 it is the height of the line's text for text fields and twice that
+	// for signature fields, which need room for the signature.
+	Height float64
+}
+
+// LineField is a field created by AddLineFields.
+type LineField struct {
+	Name string `json:"name"`
+	Page int    `json:"page"`
+	Rect Box    `json:"rect"`
+}
+
+// AddLineFields creates a form field on each line of `inputPath` matching `lineOpts.Pattern` and writes the result
+// to `outputPath`.  The fields start at the bottom of the line's text and are as wide as the line.  Existing form
+// fields are kept and new names that are already taken get a numeric suffix.
+func AddLineFields(inputPath, outputPath string, lineOpts LineFieldOptions, opts Options) ([]LineField, error) {
+	pattern := lineOpts.Pattern
+	if pattern == "" {
+		pattern = DefaultLinePattern
+	}
+	re, err := CompilePattern(pattern, FindOptions{Regexp: true})
+	if err != nil {
+		return nil, err
+	}
+
+	template := lineOpts.NameTemplate
+	if template == "" {
+		template = "signature_{n}"
+		if lineOpts.Type == LineFieldText {
+			template = "text_{n}"
+		}
+	}
+
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return nil, err
+	}
+
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	selected, err := lineOpts.Pages.SelectSet(pdfReader)
+	if err != nil {
+		return nil, err
+	}
+
+	form := pdfReader.AcroForm
+	if form == nil {
+		form = pdf.NewPdfAcroForm()
+	}
+	if form.Fields == nil {
+		form.Fields = &[]*pdf.PdfField{}
+	}
+	taken := map[string]*pdf.PdfField{}
+	for _, field := range *form.Fields {
+		taken[fieldName(field)] = field
+	}
+
+	pdfWriter := pdf.NewPdfWriter()
+
+	ocProps, err := pdfReader.GetOCProperties()
+	if err != nil {
+		return nil, err
+	}
+	pdfWriter.SetOCProperties(ocProps)
+
+	created := []LineField{}
+	for i, page := range pdfReader.PageList {
+		pageNum := i + 1
+
+		if selected[pageNum] {
+			runs, err := PageTextRuns(page, pageNum)
+			if err != nil {
+				return nil, fmt.Errorf("Page %d: %v", pageNum, err)
+			}
+
+			for _, match := range FindInRuns(runs, re) {
+				for _, box := range match.Boxes {
+					name := strings.NewReplacer("{n}", strconv.Itoa(len(created)+1),
+						"{page}", strconv.Itoa(pageNum)).Replace(template)
+					if _, has := taken[name]; has {
+						newName := uniqueFieldName(name, taken)
+						unicommon.Log.Debug("Form field %q already exists, using %q", name, newName)
+						name = newName
+					}
+
+					height := lineOpts.Height
+					if height <= 0 {
+						height = box[3] - box[1]
+						if lineOpts.Type == LineFieldSignature {
+							height *= 2
+						}
+					}
+					rect := Box{box[0], box[1], box[2], box[1] + height}
+
+					field := newLineField(page, name, rect, lineOpts.Type)
+					*form.Fields = append(*form.Fields, field)
+					taken[name] = field
+					created = append(created, LineField{Name: name, Page: pageNum, Rect: rect})
+				}
+			}
+		}
+
+		if err := pdfWriter.AddPage(page); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(created) == 0 {
+		unicommon.Log.Debug("No lines matching %q found", pattern)
+	}
+	if lineOpts.Type == LineFieldText {
+		ensureDefaultAppearance(form)
+		// Let viewers create the appearance of the new fields.
+		form.NeedAppearances = pdfcore.MakeBool(true)
+	}
+	if len(*form.Fields) > 0 {
+		if err := pdfWriter.SetForms(form); err != nil {
+			return nil, err
+		}
+	}
+
+	items := copyOutlineItems(pdfReader, outlineItems(pdfReader.GetOutlineTree()), readerPageObjects(pdfReader))
+	if len(items) > 0 {
+		pdfWriter.AddOutlineTree(&newOutlineTree(items).PdfOutlineTreeNode)
+	}
+
+	return created, writePdf(&pdfWriter, outputPath)
+}
+
+// newLineField returns a field named `name` of type `fieldType` with a widget annotation at `rect` on `page`,
+// which is added to the page's annotations.
+func newLineField(page *pdf.PdfPage, name string, rect Box, fieldType LineFieldType) *pdf.PdfField {
+	field := pdf.NewPdfField()
+	field.T = pdfcore.MakeString(name)
+	if fieldType == LineFieldText {
+		field.FT = pdfcore.MakeName("Tx")
+	} else {
+		field.FT = pdfcore.MakeName("Sig")
+	}
+
+	widget := pdf.NewPdfAnnotationWidget()
+	widget.Rect = pdfcore.MakeArrayFromFloats(rect[:])
+	widget.P = page.GetPageAsIndirectObject()
+	widget.F = pdfcore.MakeInteger(4) // Print.
+	widget.Parent = field.GetContainingPdfObject()
+
+	field.KidsA = append(field.KidsA, widget.PdfAnnotation)
+	page.Annotations = append(page.Annotations, widget.PdfAnnotation)
+
+	return field
+}
+
+// ensureDefaultAppearance gives `form` a default appearance for text fields, Helvetica in black with automatic
+// size, unless it has one.
+func ensureDefaultAppearance(form *pdf.PdfAcroForm) {
+	if form.DA != nil {
+		return
+	}
+
+	if form.DR == nil {
+		form.DR = pdf.NewPdfPageResources()
+	}
+	if !form.DR.HasFontByName("Helv") {
+		font := pdfcore.MakeDict()
+		font.Set("Type", pdfcore.MakeName("Font"))
+		font.Set("Subtype", pdfcore.MakeName("Type1"))
+		font.Set("BaseFont", pdfcore.MakeName("Helvetica"))
+		font.Set("Encoding", pdfcore.MakeName("WinAnsiEncoding"))
+		if err := form.DR.SetFontByName("Helv", pdfcore.MakeIndirectObject(font)); err != nil {
+			unicommon.Log.Debug("Unable to add default font: %v", err)
+			return
+		}
+	}
+	form.DA = pdfcore.MakeString("/Helv 0 Tf 0 g")
+}
@@ -0,0 +1,432 @@
+package pdfops
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+)
+
+// MergeMode selects the order in which MergePdf combines the pages of its inputs.
+type MergeMode int
+
+const (
+	MergeConcatenate MergeMode = iota // All pages of the first input, then all pages of the second, ...
+	MergeInterleave                   // First page of each input, then the second page of each input, ...
+	MergeShuffle                      // Duplex scans: fronts from the first input, backs from the second reversed.
+	MergeReverse                      // Like MergeConcatenate, but in reverse page order.
+	MergeCollate                      // Like MergeInterleave, but takes MergeOptions.CollateN pages at a time.
+)
+
+// MergeOptions specifies how MergePdf combines its inputs.
+type MergeOptions struct {
+	// Mode selects the page order.
+	Mode MergeMode
+
+	// CollateN is the number of pages taken from each input in turn for MergeCollate.
+	CollateN int
+
+	// DropOutlines discards the outlines (bookmarks) of the inputs.
+	DropOutlines bool
+
+	// NestOutlines puts the outline of each input below a new top level bookmark named after the input file.
+	NestOutlines bool
+
+	// FieldNames selects how form fields with the same name in different inputs are handled.
+	FieldNames FieldNameStrategy
+
+	// XFA selects how XFA form data is handled.
+	XFA XFAPolicy
+
+	// KeepDuplicateResources disables sharing byte-identical fonts and images of the inputs in the output.
+	KeepDuplicateResources bool
+}
+
+// MergePdf combines the pages of the files in `inputPaths` in the order selected by `mergeOpts` and writes the
+// result to `outputPath`.  Form field data (AcroForms) and outlines of the inputs are merged as well.
+func MergePdf(inputPaths []string, outputPath string, mergeOpts MergeOptions, opts Options) error {
+	pdfWriter := pdf.NewPdfWriter()
+
+	var forms *pdf.PdfAcroForm
+	outlines := []*pdf.PdfOutlineItem{}
+
+	readers := []*pdf.PdfReader{}
+	numPages := []int{}
+
+	for docIdx, inputPath := range inputPaths {
+		doc, err := opener.Open(inputPath, opts.Open)
+		if err != nil {
+			return err
+		}
+
+		defer doc.Close()
+		pdfReader := doc.Reader
+
+		n, err := pdfReader.GetNumPages()
+		if err != nil {
+			return err
+		}
+		readers = append(readers, pdfReader)
+		numPages = append(numPages, n)
+
+		// Handle outlines.
+		if !mergeOpts.DropOutlines && n > 0 {
+			outlines = append(outlines, mergeOutlines(pdfReader, inputPath, mergeOpts.NestOutlines)...)
+		}
+
+		// Handle forms.
+		if pdfReader.AcroForm != nil {
+			if pdfReader.AcroForm.XFA != nil && mergeOpts.XFA == XFAFail && len(inputPaths) > 1 {
+				return fmt.Errorf("%s: XFA forms cannot be merged", inputPath)
+			}
+
+			if forms == nil {
+				forms = pdfReader.AcroForm
+				if mergeOpts.FieldNames == FieldsPrefixAll && forms.Fields != nil {
+					prefixFields(*forms.Fields, fmt.Sprintf("doc%d_", docIdx+1))
+				}
+			} else {
+				forms, err = mergeForms(forms, pdfReader.AcroForm, docIdx+1, mergeOpts)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	order, err := mergeOrder(numPages, mergeOpts)
+	if err != nil {
+		return err
+	}
+
+	dedup := newResourceDeduper()
+	for _, mp := range order {
+		page, err := readers[mp.doc].GetPage(mp.pageNum)
+		if err != nil {
+			return err
+		}
+
+		if !mergeOpts.KeepDuplicateResources {
+			dedup.dedupPage(page)
+		}
+
+		err = pdfWriter.AddPage(page)
+		if err != nil {
+			return err
+		}
+	}
+
+	if dedup.replaced > 0 {
+		unicommon.Log.Info("Shared %d duplicate fonts and images", dedup.replaced)
+	}
+
+	if forms != nil && mergeOpts.XFA == XFADrop {
+		forms.XFA = nil
+	}
+
+	if len(outlines) > 0 {
+		pdfWriter.AddOutlineTree(&newOutlineTree(outlines).PdfOutlineTreeNode)
+	}
+
+	// Set the merged forms object.
+	if forms != nil {
+		err = pdfWriter.SetForms(forms)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writePdf(&pdfWriter, outputPath)
+}
+
+// mergePage identifies page `pageNum` of input `doc` (0-based).
+type mergePage struct {
+	doc     int
+	pageNum int
+}
+
+// mergeOrder returns the order in which the pages of inputs with `numPages` pages are added to the output.
+func mergeOrder(numPages []int, mergeOpts MergeOptions) ([]mergePage, error) {
+	// Page lists of each input, in the order they are taken from.
+	lists := make([][]mergePage, len(numPages))
+	for doc, n := range numPages {
+		for pageNum := 1; pageNum <= n; pageNum++ {
+			lists[doc] = append(lists[doc], mergePage{doc: doc, pageNum: pageNum})
+		}
+	}
+
+	switch mergeOpts.Mode {
+	case MergeConcatenate:
+		return collate(lists, 0), nil
+	case MergeReverse:
+		return reversePages(collate(lists, 0)), nil
+	case MergeInterleave:
+		return collate(lists, 1), nil
+	case MergeCollate:
+		if mergeOpts.CollateN < 1 {
+			return nil, errors.New("Number of pages to collate must be at least 1")
+		}
+		return collate(lists, mergeOpts.CollateN), nil
+	case MergeShuffle:
+		if len(lists) != 2 {
+			return nil, errors.New("Shuffle merging requires exactly two inputs (fronts and backs)")
+		}
+		if numPages[0] != numPages[1] && numPages[0] != numPages[1]+1 {
+			unicommon.Log.Info("Shuffle merging %d fronts with %d backs", numPages[0], numPages[1])
+		}
+		lists[1] = reversePages(lists[1])
+		return collate(lists, 1), nil
+	}
+
+	return nil, fmt.Errorf("Unsupported merge mode %d", mergeOpts.Mode)
+}
+
+// collate takes `n` pages from each of `lists` in turn until all are used up.  Each list is taken as a whole if
+// `n` is 0.
+func collate(lists [][]mergePage, n int) []mergePage {
+	order := []mergePage{}
+	for {
+		done := true
+		for i, list := range lists {
+			take := n
+			if take == 0 || take > len(list) {
+				take = len(list)
+			}
+			order = append(order, list[:take]...)
+			lists[i] = list[take:]
+			if len(lists[i]) > 0 {
+				done = false
+			}
+		}
+		if done {
+			return order
+		}
+	}
+}
+
+// reversePages returns `pages` in reverse order.
+func reversePages(pages []mergePage) []mergePage {
+	reversed := make([]mergePage, len(pages))
+	for i, p := range pages {
+		reversed[len(pages)-1-i] = p
+	}
+	return reversed
+}
+
+// mergeOutlines returns the top level outline items of `pdfReader` for the merged document, nested under an item
+// named after `inputPath` if `nest` is true.
+func mergeOutlines(pdfReader *pdf.PdfReader, inputPath string, nest bool) []*pdf.PdfOutlineItem {
+	pageObj := readerPageObjects(pdfReader)
+	items := copyOutlineItems(pdfReader, outlineItems(pdfReader.GetOutlineTree()), pageObj)
+	if !nest {
+		return items
+	}
+
+	title := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+	dest := pdfcore.MakeArray(pageObj(1), pdfcore.MakeName("Fit"))
+	docItem := newOutlineItem(title, dest)
+	linkOutlineItems(&docItem.PdfOutlineTreeNode, items)
+	return []*pdf.PdfOutlineItem{docItem}
+}
+
+func getDict(obj pdfcore.PdfObject) *pdfcore.PdfObjectDictionary {
+	if obj == nil {
+		return nil
+	}
+
+	obj = pdfcore.TraceToDirectObject(obj)
+	dict, ok := obj.(*pdfcore.PdfObjectDictionary)
+	if !ok {
+		unicommon.Log.Debug("Error type check error (got %T)", obj)
+		return nil
+	}
+
+	return dict
+}
+
+// Merge form resources.  Resources of `r2` whose names are taken by different resources in `r` are renamed, the
+// returned renames need to be applied to everything that refers to `r2`.
+func mergeResources(r, r2 *pdf.PdfPageResources) (*pdf.PdfPageResources, resourceRenames, error) {
+	// Merge Colorspace resources.
+	if r.ColorSpace == nil {
+		r.ColorSpace = r2.ColorSpace
+	} else if r2.ColorSpace != nil {
+		for key, val := range r2.ColorSpace.Colorspaces {
+			// Add the r2 colorspaces to r. Overwrite if duplicate.  Ensure only present once in Names.
+			if _, has := r.ColorSpace.Colorspaces[key]; !has {
+				r.ColorSpace.Names = append(r.ColorSpace.Names, key)
+			}
+			r.ColorSpace.Colorspaces[key] = val
+		}
+	}
+
+	renames := resourceRenames{}
+	r.XObject = mergeResourceDict(r.XObject, r2.XObject, "XObject", renames)
+	r.ExtGState = mergeResourceDict(r.ExtGState, r2.ExtGState, "ExtGState", renames)
+	r.Shading = mergeResourceDict(r.Shading, r2.Shading, "Shading", renames)
+	r.Pattern = mergeResourceDict(r.Pattern, r2.Pattern, "Pattern", renames)
+	r.Font = mergeResourceDict(r.Font, r2.Font, "Font", renames)
+	r.Properties = mergeResourceDict(r.Properties, r2.Properties, "Properties", renames)
+	if r.ProcSet == nil {
+		r.ProcSet = r2.ProcSet
+	}
+
+	return r, renames, nil
+}
+
+// Merge two interactive forms.  `docNum` is the number of the input that `form2` belongs to.
+func mergeForms(form, form2 *pdf.PdfAcroForm, docNum int, mergeOpts MergeOptions) (*pdf.PdfAcroForm, error) {
+	// Use whatever value comes first..
+	// TODO: Consider adding a more intelligent, preferential handling based on actual values.  If needed.
+
+	if form.NeedAppearances == nil {
+		form.NeedAppearances = form2.NeedAppearances
+	}
+
+	if form.SigFlags == nil {
+		form.SigFlags = form2.SigFlags
+	}
+
+	if form.CO == nil {
+		form.CO = form2.CO
+	}
+
+	if form.DR == nil {
+		form.DR = form2.DR
+	} else if form2.DR != nil {
+		dr, renames, err := mergeResources(form.DR, form2.DR)
+		if err != nil {
+			return nil, err
+		}
+		form.DR = dr
+
+		if !renames.empty() {
+			err = renameFormResources(form2, renames)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if form.DA == nil {
+		form.DA = form2.DA
+	}
+
+	if form.Q == nil {
+		form.Q = form2.Q
+	}
+
+	if form.XFA == nil {
+		form.XFA = form2.XFA
+	} else if form2.XFA != nil {
+		// XFA cannot be merged, see XFAPolicy.
+		unicommon.Log.Debug("Document %d: ignoring XFA, using the first one that was encountered", docNum)
+	}
+
+	// Fields.
+	if form2.Fields != nil {
+		if mergeOpts.FieldNames == FieldsPrefixAll {
+			prefixFields(*form2.Fields, fmt.Sprintf("doc%d_", docNum))
+		}
+		if form.Fields == nil {
+			form.Fields = &[]*pdf.PdfField{}
+		}
+		fields := mergeFieldLists(*form.Fields, *form2.Fields, nil, mergeOpts.FieldNames)
+		form.Fields = &fields
+	}
+
+	return form, nil
+}
+
+// renameFormResources applies `renames` of default resources (DR) to the default appearance (DA) strings of `form`
+// and its fields, and to the widget appearance streams that use the default resources.
+func renameFormResources(form *pdf.PdfAcroForm, renames resourceRenames) error {
+	if form.DA != nil {
+		da, err := renameContentResources(string(*form.DA), renames)
+		if err != nil {
+			return err
+		}
+		form.DA = pdfcore.MakeString(da)
+	}
+
+	if form.Fields == nil {
+		return nil
+	}
+
+	var renameField func(field *pdf.PdfField) error
+	renameField = func(field *pdf.PdfField) error {
+		if da, ok := pdfcore.TraceToDirectObject(field.DA).(*pdfcore.PdfObjectString); ok {
+			newDA, err := renameContentResources(string(*da), renames)
+			if err != nil {
+				return err
+			}
+			field.DA = pdfcore.MakeString(newDA)
+		}
+
+		for _, kid := range field.KidsF {
+			switch t := kid.(type) {
+			case *pdf.PdfField:
+				if err := renameField(t); err != nil {
+					return err
+				}
+			case *pdf.PdfAnnotationWidget:
+				if err := renameAppearanceResources(t.AP, renames); err != nil {
+					return err
+				}
+			}
+		}
+		for _, annot := range field.KidsA {
+			if err := renameAppearanceResources(annot.AP, renames); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, field := range *form.Fields {
+		if err := renameField(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renameAppearanceResources applies `renames` to the streams of appearance dictionary `ap` that have no resources
+// of their own and thus use the default resources of the form.
+func renameAppearanceResources(ap pdfcore.PdfObject, renames resourceRenames) error {
+	apDict := getDict(ap)
+	if apDict == nil {
+		return nil
+	}
+
+	streams := []*pdfcore.PdfObjectStream{}
+	for _, key := range []pdfcore.PdfObjectName{"N", "R", "D"} {
+		switch t := pdfcore.TraceToDirectObject(apDict.Get(key)).(type) {
+		case *pdfcore.PdfObjectStream:
+			streams = append(streams, t)
+		case *pdfcore.PdfObjectDictionary:
+			// Appearance states, e.g. /On and /Off of check boxes.
+			for _, state := range t.Keys() {
+				if stream, ok := pdfcore.TraceToDirectObject(t.Get(state)).(*pdfcore.PdfObjectStream); ok {
+					streams = append(streams, stream)
+				}
+			}
+		}
+	}
+
+	for _, stream := range streams {
+		if stream.PdfObjectDictionary.Get("Resources") != nil {
+			continue
+		}
+		if err := renameStreamResources(stream, renames); err != nil {
+			return err
+		}
+	}
+	return nil
+}
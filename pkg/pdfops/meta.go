@@ -0,0 +1,368 @@
+package pdfops
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+)
+
+// Metadata is the document level metadata of a PDF file.
+type Metadata struct {
+	Path string            `json:"path"`
+	Info map[string]string `json:"info"`          // Document information dictionary entries.
+	XMP  string            `json:"xmp,omitempty"` // XMP metadata packet of the catalog, if any.
+}
+
+// GetMetadata returns the document information dictionary and XMP metadata of `inputPath`.
+func GetMetadata(inputPath string, opts Options) (*Metadata, error) {
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return nil, err
+	}
+
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	info, xmp, err := readMetadata(pdfReader)
+	if err != nil {
+		return nil, err
+	}
+	return &Metadata{Path: inputPath, Info: infoTexts(info), XMP: xmp}, nil
+}
+
+// readMetadata returns the document information dictionary entries and the XMP metadata packet of `pdfReader`.
+func readMetadata(pdfReader *pdf.PdfReader) (map[string]pdfcore.PdfObject, string, error) {
+	trailer, err := pdfReader.GetTrailer()
+	if err != nil {
+		return nil, "", err
+	}
+
+	info := infoDictObjects(getDict(trailer.Get("Info")))
+	if info == nil {
+		info = map[string]pdfcore.PdfObject{}
+	}
+
+	xmp := ""
+	if catalog := getDict(trailer.Get("Root")); catalog != nil {
+		if stream, ok := pdfcore.TraceToDirectObject(catalog.Get("Metadata")).(*pdfcore.PdfObjectStream); ok {
+			data, err := pdfcore.DecodeStream(stream)
+			if err != nil {
+				return nil, "", err
+			}
+			xmp = string(data)
+		}
+	}
+
+	return info, xmp, nil
+}
+
+// MetadataUpdate describes changes to the document information dictionary.
+type MetadataUpdate struct {
+	Set    map[string]string // Entries to add or replace.
+	Delete []string          // Entries to remove.
+
+	// KeepModDate leaves the ModDate entry as it is instead of setting it to the current time.
+	KeepModDate bool
+}
+
+// ParseMetadataJSON reads a JSON object of Info dictionary entries from `r`: string values set an entry and null
+// values delete it, e.g. {"Title": "Annual report", "Keywords": null}.
+func ParseMetadataJSON(r io.Reader) (MetadataUpdate, error) {
+	update := MetadataUpdate{Set: map[string]string{}}
+
+	entries := map[string]*string{}
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return update, fmt.Errorf("Invalid metadata JSON: %v", err)
+	}
+
+	for key, val := range entries {
+		if val == nil {
+			update.Delete = append(update.Delete, key)
+		} else {
+			update.Set[key] = *val
+		}
+	}
+	sort.Strings(update.Delete)
+
+	return update, nil
+}
+
+// UpdateMetadata copies `inputPath` to `outputPath` with the document information dictionary changed according
+// to `update`.  Entries keep their type: names such as Trapped stay names.  The properties of the catalog's XMP
+// metadata stream that correspond to the entries are updated so that both agree, the rest of it is kept.
+func UpdateMetadata(inputPath, outputPath string, update MetadataUpdate, opts Options) error {
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return err
+	}
+
+	defer doc.Close()
+	pdfReader := doc.Reader
+	if doc.Auth != opener.AuthNotEncrypted {
+		unicommon.Log.Info("%s is encrypted, %s is written without encryption", inputPath, outputPath)
+	}
+	if doc.Auth != opener.AuthNotEncrypted {
+		unicommon.Log.Info("%s is encrypted, %s is written without encryption", inputPath, outputPath)
+	}
+
+	info, xmp, err := readMetadata(pdfReader)
+	if err != nil {
+		return err
+	}
+	previous := infoTexts(info)
+
+	for key, val := range update.Set {
+		if strings.ContainsAny(key, " /()<>[]{}%#") || key == "" {
+			return fmt.Errorf("Invalid Info dictionary key %q", key)
+		}
+		info[key] = infoValue(key, info[key], val)
+	}
+	if _, set := update.Set["ModDate"]; !set && !update.KeepModDate {
+		info["ModDate"] = makeTextString(formatPdfDate(time.Now()))
+	}
+	// Deletes come last so that an explicit delete wins over an entry that is also set.
+	for _, key := range update.Delete {
+		delete(info, key)
+	}
+
+	// Pass the document through the writer.
+	pdfWriter := pdf.NewPdfWriter()
+
+	ocProps, err := pdfReader.GetOCProperties()
+	if err != nil {
+		return err
+	}
+	pdfWriter.SetOCProperties(ocProps)
+
+	if err := copyPages(&pdfWriter, pdfReader); err != nil {
+		return err
+	}
+	if pdfReader.AcroForm != nil {
+		if err := pdfWriter.SetForms(pdfReader.AcroForm); err != nil {
+			return err
+		}
+	}
+	items := copyOutlineItems(pdfReader, outlineItems(pdfReader.GetOutlineTree()), readerPageObjects(pdfReader))
+	if len(items) > 0 {
+		pdfWriter.AddOutlineTree(&newOutlineTree(items).PdfOutlineTreeNode)
+	}
+
+	var buf bytes.Buffer
+	if err := pdfWriter.Write(&buf); err != nil {
+		return err
+	}
+
+	// The writer creates its own information dictionary, so the metadata is applied as an incremental update.
+	xmp = updateXMP(xmp, infoTexts(info), previous, nil)
+	data, err := appendMetadataUpdate(buf.Bytes(), pdfReader, info, xmp)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(outputPath, data, 0644)
+}
+
+// appendMetadataUpdate appends an incremental update to PDF document `data`, written by a PdfWriter from the
+// pages of `src`, that replaces its information dictionary by `info` and sets the XMP metadata stream of its catalog
+// to `xmp`.  The file identifier of `src` is kept.
+func appendMetadataUpdate(data []byte, src *pdf.PdfReader, info map[string]pdfcore.PdfObject,
+	xmp string) ([]byte, error) {
+	// The writer output is never encrypted, whether `src` was or not, so it can be read as it is.
+	pdfReader, err := pdf.NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if isEncrypted, err := pdfReader.IsEncrypted(); err != nil || isEncrypted {
+		return nil, errors.New("Unable to update the metadata of an encrypted document")
+	}
+
+	trailer, err := pdfReader.GetTrailer()
+	if err != nil {
+		return nil, err
+	}
+
+	size, ok := pdfcore.TraceToDirectObject(trailer.Get("Size")).(*pdfcore.PdfObjectInteger)
+	if !ok {
+		return nil, errors.New("Trailer has no Size")
+	}
+	rootNum := objectNumber(trailer.Get("Root"))
+	if rootNum <= 0 {
+		return nil, errors.New("Trailer has no Root reference")
+	}
+
+	rootObj, err := pdfReader.GetIndirectObjectByNumber(int(rootNum))
+	if err != nil {
+		return nil, err
+	}
+	catalog := getDict(rootObj)
+	if catalog == nil {
+		return nil, errors.New("Invalid catalog")
+	}
+
+	prevXref := bytes.LastIndex(data, []byte("startxref"))
+	if prevXref < 0 {
+		return nil, errors.New("No startxref")
+	}
+	var prevOffset int64
+	if _, err := fmt.Sscan(string(data[prevXref+len("startxref"):]), &prevOffset); err != nil {
+		return nil, fmt.Errorf("Invalid startxref: %v", err)
+	}
+
+	infoNum := int64(*size)
+	xmpNum := infoNum + 1
+
+	var out bytes.Buffer
+	out.Write(data)
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		out.WriteString("\n")
+	}
+	offsets := map[int64]int{}
+
+	// Information dictionary.
+	offsets[infoNum] = out.Len()
+	keys := []string{}
+	for key := range info {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(&out, "%d 0 obj\n<<", infoNum)
+	for _, key := range keys {
+		val := info[key]
+		if str, ok := val.(*pdfcore.PdfObjectString); ok {
+			fmt.Fprintf(&out, "\n%s %s", pdfcore.MakeName(key).DefaultWriteString(), encodeString(string(*str)))
+		} else {
+			fmt.Fprintf(&out, "\n%s %s", pdfcore.MakeName(key).DefaultWriteString(), val.DefaultWriteString())
+		}
+	}
+	out.WriteString("\n>>\nendobj\n")
+
+	// XMP metadata stream (never compressed, so that it can be found by tools that scan the file).
+	offsets[xmpNum] = out.Len()
+	fmt.Fprintf(&out, "%d 0 obj\n<< /Type /Metadata /Subtype /XML /Length %d >>\nstream\n%s\nendstream\nendobj\n",
+		xmpNum, len(xmp), xmp)
+
+	// Updated catalog.
+	offsets[rootNum] = out.Len()
+	fmt.Fprintf(&out, "%d 0 obj\n<<", rootNum)
+	for _, key := range catalog.Keys() {
+		if key == "Metadata" {
+			continue
+		}
+		fmt.Fprintf(&out, "\n%s %s", pdfcore.MakeName(string(key)).DefaultWriteString(),
+			catalog.Get(key).DefaultWriteString())
+	}
+	fmt.Fprintf(&out, "\n/Metadata %d 0 R\n>>\nendobj\n", xmpNum)
+
+	// Cross reference section and trailer.
+	xrefOffset := out.Len()
+	out.WriteString("xref\n")
+	objNums := []int64{rootNum, infoNum, xmpNum}
+	sort.Slice(objNums, func(i, j int) bool { return objNums[i] < objNums[j] })
+	for _, objNum := range objNums {
+		fmt.Fprintf(&out, "%d 1\n%010d 00000 n \n", objNum, offsets[objNum])
+	}
+
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R /Prev %d", xmpNum+1, rootNum, infoNum,
+		prevOffset)
+	srcTrailer, err := src.GetTrailer()
+	if err != nil {
+		return nil, err
+	}
+	id := srcTrailer.Get("ID")
+	if id == nil {
+		id = trailer.Get("ID")
+	}
+	if id != nil {
+		fmt.Fprintf(&out, " /ID %s", id.DefaultWriteString())
+	}
+	fmt.Fprintf(&out, " >>\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+
+	return out.Bytes(), nil
+}
+
+// objectNumber returns the object number of reference or indirect object `obj`, or 0.
+func objectNumber(obj pdfcore.PdfObject) int64 {
+	switch t := obj.(type) {
+	case *pdfcore.PdfObjectReference:
+		return t.ObjectNumber
+	case *pdfcore.PdfIndirectObject:
+		return t.ObjectNumber
+	case *pdfcore.PdfObjectStream:
+		return t.ObjectNumber
+	}
+	return 0
+}
+
+// infoValue returns value `val` of information dictionary entry `key`: a name if the entry is one, like Trapped, or
+// its previous value `old` was one, and a text string otherwise.
+func infoValue(key string, old pdfcore.PdfObject, val string) pdfcore.PdfObject {
+	if _, isName := old.(*pdfcore.PdfObjectName); isName || key == "Trapped" {
+		return pdfcore.MakeName(val)
+	}
+	return makeTextString(val)
+}
+
+// encodeString returns the bytes of string `s` as a PDF string: a literal string if they are printable ASCII and
+// hexadecimal otherwise.
+func encodeString(s string) string {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			ascii = false
+			break
+		}
+	}
+
+	if ascii {
+		r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+		return "(" + r.Replace(s) + ")"
+	}
+	return fmt.Sprintf("<%X>", s)
+}
+
+// formatPdfDate formats `t` as a PDF date string, e.g. D:20180102150405+01'00'.
+func formatPdfDate(t time.Time) string {
+	_, offset := t.Zone()
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	if offset == 0 {
+		return t.Format("D:20060102150405") + "Z"
+	}
+	return fmt.Sprintf("%s%s%02d'%02d'", t.Format("D:20060102150405"), sign, offset/3600, offset%3600/60)
+}
+
+// parsePdfDate parses PDF date string `s`.  Omitted trailing fields default to their lowest value.
+func parsePdfDate(s string) (time.Time, bool) {
+	s = strings.TrimPrefix(s, "D:")
+	s = strings.Replace(s, "'", "", -1)
+
+	layouts := []string{"20060102150405-0700", "20060102150405Z", "20060102150405", "200601021504", "2006010215",
+		"20060102", "200601", "2006"}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	// Z followed by an offset (Z00'00') is common as well.
+	if i := strings.Index(s, "Z"); i > 0 {
+		if t, err := time.Parse("20060102150405", s[:i]); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
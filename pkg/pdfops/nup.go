@@ -0,0 +1,333 @@
+package pdfops
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	"github.com/unidoc/unidoc/pdf/creator"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// NupOrder is the order in which pages are placed on an N-up sheet.
+type NupOrder int
+
+const (
+	NupAcross    NupOrder = iota // Left to right, then top to bottom.
+	NupDown                      // Top to bottom, then left to right.
+	NupAcrossRTL                 // Right to left, then top to bottom.
+	NupDownRTL                   // Top to bottom, then right to left.
+)
+
+var nupOrderNames = []string{"across", "down", "across-rtl", "down-rtl"}
+
+// ParseNupOrder parses N-up order `s`: across, down, across-rtl or down-rtl.
+func ParseNupOrder(s string) (NupOrder, error) {
+	for i, name := range nupOrderNames {
+		if strings.EqualFold(s, name) {
+			return NupOrder(i), nil
+		}
+	}
+	return 0, fmt.Errorf("Invalid order %q, expected one of %s", s, strings.Join(nupOrderNames, ", "))
+}
+
+func (o NupOrder) String() string {
+	if o < 0 || int(o) >= len(nupOrderNames) {
+		return fmt.Sprintf("NupOrder(%d)", int(o))
+	}
+	return nupOrderNames[o]
+}
+
+// nupGrids are the numbers of columns and rows of the N-up layouts on landscape sheets.  They are swapped on
+// portrait sheets.
+var nupGrids = map[int][2]int{
+	2:  {2, 1},
+	4:  {2, 2},
+	6:  {3, 2},
+	9:  {3, 3},
+	16: {4, 4},
+}
+
+// ImpositionOptions are the options shared by NupPdf and BookletPdf.
+type ImpositionOptions struct {
+	// Paper is the size of the sheets, the size of the first page if zero.  Sheets are turned to landscape or
+	// portrait, whichever fits the pages larger.
+	Paper creator.PageSize
+
+	Margin float64 // Space around the edges of the sheets.
+	Gutter float64 // Space between pages.
+	Border float64 // Width of a line drawn around each page, 0 for none.
+
+	Pages *pagerange.Selection // Pages to impose, nil for all.
+}
+
+// NupOptions are the options of NupPdf.
+type NupOptions struct {
+	ImpositionOptions
+	N     int // Pages per sheet: 2, 4, 6, 9 or 16.
+	Order NupOrder
+}
+
+// NupPdf places the pages of `inputPath` `nupOpts.N` to a sheet and writes the result to `outputPath`.  The pages
+// are scaled to fit their cells, keeping their aspect ratio, and centered in them.  Annotations and form fields are
+// not carried over.
+func NupPdf(inputPath, outputPath string, nupOpts NupOptions, opts Options) error {
+	grid, ok := nupGrids[nupOpts.N]
+	if !ok {
+		return fmt.Errorf("Invalid number of pages per sheet %d, expected 2, 4, 6, 9 or 16", nupOpts.N)
+	}
+
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return err
+	}
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	pageNums, err := nupOpts.Pages.Select(pdfReader)
+	if err != nil {
+		return err
+	}
+
+	sides := [][]int{}
+	for i := 0; i < len(pageNums); i += nupOpts.N {
+		end := i + nupOpts.N
+		if end > len(pageNums) {
+			end = len(pageNums)
+		}
+		sides = append(sides, pageNums[i:end])
+	}
+
+	return imposePdf(pdfReader, outputPath, sides, grid, nupOpts.Order, nupOpts.ImpositionOptions)
+}
+
+// BookletPdf imposes the pages of `inputPath` for saddle stitching and writes the result to `outputPath`.  Each
+// sheet holds two pages side by side on each side, and the sides are ordered so that the sheets, printed double
+// sided (flipped on the short edge), stacked, folded in the middle and stapled, make a booklet.  Blank pages are
+// added at the end to make the number of pages a multiple of 4.
+func BookletPdf(inputPath, outputPath string, impOpts ImpositionOptions, opts Options) error {
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return err
+	}
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	pageNums, err := impOpts.Pages.Select(pdfReader)
+	if err != nil {
+		return err
+	}
+	if len(pageNums) == 0 {
+		return errors.New("No pages selected")
+	}
+
+	return imposePdf(pdfReader, outputPath, bookletSides(pageNums), nupGrids[2], NupAcross, impOpts)
+}
+
+// bookletSides returns the page numbers on the sides of the sheets of a booklet of pages `pageNums`, front and
+// back of each sheet in turn, with 0 for the blank pages that make the number of pages a multiple of 4.
+func bookletSides(pageNums []int) [][]int {
+	n := (len(pageNums) + 3) / 4 * 4
+	pageNum := func(i int) int {
+		if i < len(pageNums) {
+			return pageNums[i]
+		}
+		return 0
+	}
+
+	// The outer sheet has the last and first page on the front and the second and second to last on the back,
+	// the next sheet the pages inside those, and so on.
+	sides := [][]int{}
+	for i := 0; i < n/2; i += 2 {
+		sides = append(sides,
+			[]int{pageNum(n - 1 - i), pageNum(i)},
+			[]int{pageNum(i + 1), pageNum(n - 2 - i)})
+	}
+	return sides
+}
+
+// imposePdf draws each list of page numbers in `sides` on a sheet with `grid` columns and rows in landscape, in
+// `order`, and writes the result to `outputPath`.  Page number 0 leaves a cell empty.
+func imposePdf(pdfReader *pdf.PdfReader, outputPath string, sides [][]int, grid [2]int, order NupOrder,
+	impOpts ImpositionOptions) error {
+	if len(sides) == 0 {
+		return errors.New("No pages selected")
+	}
+
+	// A booklet may start with a blank page.
+	firstPageNum := sides[0][0]
+	if firstPageNum == 0 {
+		firstPageNum = sides[0][1]
+	}
+	firstPage, err := pdfReader.GetPage(firstPageNum)
+	if err != nil {
+		return err
+	}
+	pageWidth, pageHeight, err := displayedSize(firstPage)
+	if err != nil {
+		return err
+	}
+
+	paper := impOpts.Paper
+	if paper[0] == 0 || paper[1] == 0 {
+		paper = creator.PageSize{pageWidth, pageHeight}
+	}
+
+	// Turn the sheet, and the grid with it, whichever way makes the pages larger.
+	landscape := creator.PageSize{math.Max(paper[0], paper[1]), math.Min(paper[0], paper[1])}
+	portrait := creator.PageSize{landscape[1], landscape[0]}
+	cols, rows := grid[0], grid[1]
+	sheet := landscape
+	l := newNupLayout(landscape, cols, rows, impOpts)
+	p := newNupLayout(portrait, rows, cols, impOpts)
+	if p.scale(pageWidth, pageHeight) > l.scale(pageWidth, pageHeight) {
+		sheet, cols, rows, l = portrait, rows, cols, p
+	}
+	if l.cellWidth <= 0 || l.cellHeight <= 0 {
+		return errors.New("Margin and gutter leave no room for the pages")
+	}
+	unicommon.Log.Debug("Sheet %.0f x %.0f, %d x %d pages", sheet[0], sheet[1], cols, rows)
+
+	c := creator.New()
+	c.SetPageSize(sheet)
+	for _, side := range sides {
+		c.NewPage()
+
+		for i, pageNum := range side {
+			if pageNum == 0 {
+				continue
+			}
+
+			col, row := order.cell(i, cols, rows)
+			page, err := pdfReader.GetPage(pageNum)
+			if err != nil {
+				return err
+			}
+			if err := l.drawPage(c, page, col, row); err != nil {
+				return err
+			}
+		}
+	}
+
+	return c.WriteToFile(outputPath)
+}
+
+// cell returns the column and row, counted from the top left, of the `i`th page on a sheet with `cols` columns and
+// `rows` rows.
+func (o NupOrder) cell(i, cols, rows int) (int, int) {
+	var col, row int
+	switch o {
+	case NupDown, NupDownRTL:
+		col, row = i/rows, i%rows
+	default:
+		col, row = i%cols, i/cols
+	}
+	if o == NupAcrossRTL || o == NupDownRTL {
+		col = cols - 1 - col
+	}
+	return col, row
+}
+
+// nupLayout is the division of a sheet into cells.
+type nupLayout struct {
+	opts                  ImpositionOptions
+	cellWidth, cellHeight float64
+}
+
+func newNupLayout(sheet creator.PageSize, cols, rows int, impOpts ImpositionOptions) nupLayout {
+	return nupLayout{
+		opts:       impOpts,
+		cellWidth:  (sheet[0] - 2*impOpts.Margin - float64(cols-1)*impOpts.Gutter) / float64(cols),
+		cellHeight: (sheet[1] - 2*impOpts.Margin - float64(rows-1)*impOpts.Gutter) / float64(rows),
+	}
+}
+
+// scale returns the scale at which a `width` x `height` page fits a cell.
+func (l nupLayout) scale(width, height float64) float64 {
+	return math.Min(l.cellWidth/width, l.cellHeight/height)
+}
+
+// drawPage draws `page` centered in the cell at `col`, `row`, counted from the top left.
+func (l nupLayout) drawPage(c *creator.Creator, page *pdf.PdfPage, col, row int) error {
+	block, rotate, err := uprightBlock(page)
+	if err != nil {
+		return err
+	}
+	width, height := block.Width(), block.Height()
+	if rotate == 90 || rotate == 270 {
+		width, height = height, width
+	}
+	scale := l.scale(width, height)
+	block.Scale(scale, scale)
+	width, height = width*scale, height*scale
+
+	x := l.opts.Margin + float64(col)*(l.cellWidth+l.opts.Gutter) + (l.cellWidth-width)/2
+	y := l.opts.Margin + float64(row)*(l.cellHeight+l.opts.Gutter) + (l.cellHeight-height)/2
+	placeUprightBlock(block, rotate, x, y)
+	if err := c.Draw(block); err != nil {
+		return err
+	}
+
+	if l.opts.Border > 0 {
+		border := creator.NewRectangle(x, y, width, height)
+		border.SetBorderWidth(l.opts.Border)
+		border.SetBorderColor(creator.ColorBlack)
+		if err := c.Draw(border); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uprightBlock returns a block with the contents of `page`, turned by the rotation it is displayed with, and that
+// rotation normalized to 0, 90, 180 or 270.
+func uprightBlock(page *pdf.PdfPage) (*creator.Block, int64, error) {
+	block, err := creator.NewBlockFromPage(page)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var rotate int64
+	if page.Rotate != nil {
+		rotate = (*page.Rotate%360 + 360) % 360
+	}
+	if rotate != 0 {
+		// Page rotation is clockwise, block rotation counterclockwise.
+		block.SetAngle(float64(360 - rotate))
+	}
+	return block, rotate, nil
+}
+
+// placeUprightBlock positions `block`, returned by uprightBlock for a page with rotation `rotate`, so that the top
+// left corner of the page as displayed is at `x`, `y`.  Blocks are rotated about their top left corner, so that
+// corner ends up elsewhere.
+func placeUprightBlock(block *creator.Block, rotate int64, x, y float64) {
+	switch rotate {
+	case 90:
+		block.SetPos(x+block.Height(), y)
+	case 180:
+		block.SetPos(x+block.Width(), y+block.Height())
+	case 270:
+		block.SetPos(x, y+block.Width())
+	default:
+		block.SetPos(x, y)
+	}
+}
+
+// displayedSize returns the width and height of `page` as displayed, i.e. taking its Rotate entry into account.
+func displayedSize(page *pdf.PdfPage) (float64, float64, error) {
+	mbox, err := page.GetMediaBox()
+	if err != nil {
+		return 0, 0, err
+	}
+	width, height := math.Abs(mbox.Urx-mbox.Llx), math.Abs(mbox.Ury-mbox.Lly)
+	if page.Rotate != nil && (*page.Rotate/90)%2 != 0 {
+		width, height = height, width
+	}
+	return width, height, nil
+}
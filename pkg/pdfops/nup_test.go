@@ -0,0 +1,49 @@
+package pdfops
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBookletSides(t *testing.T) {
+	tests := []struct {
+		pageNums []int
+		want     [][]int
+	}{
+		{[]int{1, 2, 3, 4}, [][]int{{4, 1}, {2, 3}}},
+		{[]int{1}, [][]int{{0, 1}, {0, 0}}},
+		{[]int{1, 2, 3, 4, 5, 6}, [][]int{{0, 1}, {2, 0}, {6, 3}, {4, 5}}},
+		{[]int{1, 2, 3, 4, 5, 6, 7, 8}, [][]int{{8, 1}, {2, 7}, {6, 3}, {4, 5}}},
+		{[]int{2, 4, 6, 8}, [][]int{{8, 2}, {4, 6}}},
+	}
+
+	for _, test := range tests {
+		if got := bookletSides(test.pageNums); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("bookletSides(%v) = %v, want %v", test.pageNums, got, test.want)
+		}
+	}
+}
+
+func TestNupOrderCell(t *testing.T) {
+	// The cells of the pages on a 3 x 2 sheet, as {col, row}.
+	tests := []struct {
+		order NupOrder
+		want  [][2]int
+	}{
+		{NupAcross, [][2]int{{0, 0}, {1, 0}, {2, 0}, {0, 1}, {1, 1}, {2, 1}}},
+		{NupDown, [][2]int{{0, 0}, {0, 1}, {1, 0}, {1, 1}, {2, 0}, {2, 1}}},
+		{NupAcrossRTL, [][2]int{{2, 0}, {1, 0}, {0, 0}, {2, 1}, {1, 1}, {0, 1}}},
+		{NupDownRTL, [][2]int{{2, 0}, {2, 1}, {1, 0}, {1, 1}, {0, 0}, {0, 1}}},
+	}
+
+	for _, test := range tests {
+		got := [][2]int{}
+		for i := 0; i < 6; i++ {
+			col, row := test.order.cell(i, 3, 2)
+			got = append(got, [2]int{col, row})
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.order, got, test.want)
+		}
+	}
+}
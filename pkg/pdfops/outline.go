@@ -0,0 +1,299 @@
+package pdfops
+
+import (
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+)
+
+// maxNameTreeDepth limits the depth of name trees, which guards against trees whose nodes are their own kids.
+const maxNameTreeDepth = 32
+
+// outlineItems returns the items directly below outline tree node `node`, in order.
+func outlineItems(node *pdf.PdfOutlineTreeNode) []*pdf.PdfOutlineItem {
+	items := []*pdf.PdfOutlineItem{}
+	if node == nil {
+		return items
+	}
+
+	for child := node.First; child != nil; {
+		item, ok := child.GetContext().(*pdf.PdfOutlineItem)
+		if !ok {
+			break
+		}
+		items = append(items, item)
+		child = item.Next
+	}
+
+	return items
+}
+
+// outlineTitle returns the title of outline item `item`.
+func outlineTitle(item *pdf.PdfOutlineItem) string {
+	if item.Title == nil {
+		return ""
+	}
+	return string(*item.Title)
+}
+
+// pageObjectNumbers maps the object numbers of the pages of `pdfReader` to their page numbers.
+func pageObjectNumbers(pdfReader *pdf.PdfReader) map[int64]int {
+	pageNums := map[int64]int{}
+	for i, page := range pdfReader.PageList {
+		if obj := page.GetPageAsIndirectObject(); obj != nil {
+			pageNums[obj.ObjectNumber] = i + 1
+		}
+	}
+	return pageNums
+}
+
+// outlineDest returns the destination of outline item `item`, either its Dest entry or the D entry of its GoTo
+// action, with named destinations looked up in `named` (see namedDests).  Returns nil if the item has no
+// destination or its name is unknown.
+func outlineDest(item *pdf.PdfOutlineItem, named map[string]pdfcore.PdfObject) *pdfcore.PdfObjectArray {
+	dest := item.Dest
+	if dest == nil && isGoToAction(item.A) {
+		dest = getDict(item.A).Get("D")
+	}
+	return explicitDest(dest, named)
+}
+
+// linkDestPageNum returns the page number that link annotation `annot` goes to, using the object number mapping in
+// `pageNums` (see pageObjectNumbers) and the named destinations in `named`.  Returns 0 if `annot` is not a link to
+// a page of the document.
+func linkDestPageNum(annot *pdf.PdfAnnotation, pageNums map[int64]int, named map[string]pdfcore.PdfObject) int {
+	link, ok := annot.GetContext().(*pdf.PdfAnnotationLink)
+	if !ok {
+		return 0
+	}
+	dest := link.Dest
+	if dest == nil && isGoToAction(link.A) {
+		dest = getDict(link.A).Get("D")
+	}
+	return destPageNum(explicitDest(dest, named), pageNums)
+}
+
+// explicitDest returns destination `dest` as an explicit destination array, looking up named destinations in
+// `named`.  Returns nil if `dest` is not a destination or its name is unknown.
+func explicitDest(dest pdfcore.PdfObject, named map[string]pdfcore.PdfObject) *pdfcore.PdfObjectArray {
+	dest = pdfcore.TraceToDirectObject(dest)
+	switch t := dest.(type) {
+	case *pdfcore.PdfObjectName:
+		dest = pdfcore.TraceToDirectObject(named[string(*t)])
+	case *pdfcore.PdfObjectString:
+		dest = pdfcore.TraceToDirectObject(named[string(*t)])
+	}
+	// The value of a named destination is either the array or a dictionary with the array as its D entry.
+	if dict, ok := dest.(*pdfcore.PdfObjectDictionary); ok {
+		dest = pdfcore.TraceToDirectObject(dict.Get("D"))
+	}
+
+	arr, ok := dest.(*pdfcore.PdfObjectArray)
+	if !ok || len(*arr) == 0 {
+		return nil
+	}
+	return arr
+}
+
+// namedDests returns the named destinations of `pdfReader` by name: the entries of the Dests dictionary of its
+// catalog and of the Dests name tree in its Names dictionary.
+func namedDests(pdfReader *pdf.PdfReader) map[string]pdfcore.PdfObject {
+	named := map[string]pdfcore.PdfObject{}
+	trailer, err := pdfReader.GetTrailer()
+	if err != nil {
+		return named
+	}
+	resolve := func(obj pdfcore.PdfObject) pdfcore.PdfObject {
+		if ref, ok := obj.(*pdfcore.PdfObjectReference); ok {
+			resolved, err := pdfReader.GetIndirectObjectByNumber(int(ref.ObjectNumber))
+			if err != nil {
+				unicommon.Log.Debug("Unable to resolve %d %d R: %v", ref.ObjectNumber, ref.GenerationNumber, err)
+				return nil
+			}
+			obj = resolved
+		}
+		return pdfcore.TraceToDirectObject(obj)
+	}
+
+	catalog, ok := resolve(trailer.Get("Root")).(*pdfcore.PdfObjectDictionary)
+	if !ok {
+		return named
+	}
+
+	if dests, ok := resolve(catalog.Get("Dests")).(*pdfcore.PdfObjectDictionary); ok {
+		for _, key := range dests.Keys() {
+			named[string(key)] = resolve(dests.Get(key))
+		}
+	}
+
+	// walkTree adds the entries of name tree node `node` and its descendants.
+	var walkTree func(node pdfcore.PdfObject, depth int)
+	walkTree = func(node pdfcore.PdfObject, depth int) {
+		dict, ok := resolve(node).(*pdfcore.PdfObjectDictionary)
+		if !ok || depth > maxNameTreeDepth {
+			return
+		}
+		if names, ok := resolve(dict.Get("Names")).(*pdfcore.PdfObjectArray); ok {
+			for i := 0; i+1 < len(*names); i += 2 {
+				if key, ok := resolve((*names)[i]).(*pdfcore.PdfObjectString); ok {
+					named[string(*key)] = resolve((*names)[i+1])
+				}
+			}
+		}
+		if kids, ok := resolve(dict.Get("Kids")).(*pdfcore.PdfObjectArray); ok {
+			for _, kid := range *kids {
+				walkTree(kid, depth+1)
+			}
+		}
+	}
+	if names, ok := resolve(catalog.Get("Names")).(*pdfcore.PdfObjectDictionary); ok {
+		walkTree(names.Get("Dests"), 0)
+	}
+
+	return named
+}
+
+// isGoToAction returns true if `obj` is a GoTo action, i.e. one that goes to a destination in the same document.
+func isGoToAction(obj pdfcore.PdfObject) bool {
+	action := getDict(obj)
+	if action == nil {
+		return false
+	}
+	s, ok := pdfcore.TraceToDirectObject(action.Get("S")).(*pdfcore.PdfObjectName)
+	return ok && *s == "GoTo"
+}
+
+// destPageNum returns the page number that destination `dest` points to, using the object number mapping in
+// `pageNums` (see pageObjectNumbers).  Returns 0 if the page is unknown.
+func destPageNum(dest *pdfcore.PdfObjectArray, pageNums map[int64]int) int {
+	if dest == nil || len(*dest) == 0 {
+		return 0
+	}
+
+	switch t := (*dest)[0].(type) {
+	case *pdfcore.PdfIndirectObject:
+		return pageNums[t.ObjectNumber]
+	case *pdfcore.PdfObjectReference:
+		return pageNums[t.ObjectNumber]
+	}
+	return 0
+}
+
+// pageObjectFunc returns the output page object for page `pageNum` of an input document, or nil if the page is
+// not part of the output.
+type pageObjectFunc func(pageNum int) pdfcore.PdfObject
+
+// readerPageObjects returns a pageObjectFunc for documents where all pages of `pdfReader` are added to the output
+// as they are.
+func readerPageObjects(pdfReader *pdf.PdfReader) pageObjectFunc {
+	return func(pageNum int) pdfcore.PdfObject {
+		if pageNum < 1 || pageNum > len(pdfReader.PageList) {
+			return nil
+		}
+		return pdfReader.PageList[pageNum-1].GetPageAsIndirectObject()
+	}
+}
+
+// copyOutlineItems returns copies of outline items `items` of `pdfReader` and their descendants, with the
+// destinations rewritten to the page objects returned by `pageObj`.  Items that point to pages which are not in
+// the output lose their destination but are kept, as their descendants may still be of use.
+func copyOutlineItems(pdfReader *pdf.PdfReader, items []*pdf.PdfOutlineItem,
+	pageObj pageObjectFunc) []*pdf.PdfOutlineItem {
+	return copyOutlineItemsWith(items, pageObjectNumbers(pdfReader), namedDests(pdfReader), pageObj)
+}
+
+func copyOutlineItemsWith(items []*pdf.PdfOutlineItem, pageNums map[int64]int, named map[string]pdfcore.PdfObject,
+	pageObj pageObjectFunc) []*pdf.PdfOutlineItem {
+	copies := []*pdf.PdfOutlineItem{}
+
+	for _, item := range items {
+		var dest pdfcore.PdfObject
+		if item.Dest != nil || isGoToAction(item.A) {
+			if arr := outlineDest(item, named); arr != nil {
+				if target := pageObj(destPageNum(arr, pageNums)); target != nil {
+					newArr := append(pdfcore.PdfObjectArray{target}, (*arr)[1:]...)
+					dest = &newArr
+				}
+			}
+			if dest == nil {
+				unicommon.Log.Debug("Bookmark %q: destination not in output, dropping it", outlineTitle(item))
+			}
+		}
+
+		itemCopy := newOutlineItem(outlineTitle(item), dest)
+		itemCopy.C = item.C
+		itemCopy.F = item.F
+		if item.Dest == nil && item.A != nil && !isGoToAction(item.A) {
+			// Other actions (e.g. URIs) do not refer to pages and can be kept.
+			itemCopy.A = item.A
+		}
+
+		children := copyOutlineItemsWith(outlineItems(&item.PdfOutlineTreeNode), pageNums, named, pageObj)
+		linkOutlineItems(&itemCopy.PdfOutlineTreeNode, children)
+		if item.Count != nil && *item.Count < 0 {
+			// Keep closed items closed.
+			count := -int64(len(children))
+			itemCopy.Count = &count
+		}
+
+		copies = append(copies, itemCopy)
+	}
+
+	return copies
+}
+
+// newOutlineItem returns a new outline item titled `title` that goes to `dest` (can be nil).
+func newOutlineItem(title string, dest pdfcore.PdfObject) *pdf.PdfOutlineItem {
+	item := pdf.NewPdfOutlineItem()
+	item.Title = pdfcore.MakeString(title)
+	item.Dest = dest
+	return item
+}
+
+// linkOutlineItems makes `items` the children of `parent`, setting up their sibling links and the visible
+// descendant count of open items.
+func linkOutlineItems(parent *pdf.PdfOutlineTreeNode, items []*pdf.PdfOutlineItem) {
+	parent.First, parent.Last = nil, nil
+	if len(items) == 0 {
+		return
+	}
+
+	for i, item := range items {
+		item.Parent = parent
+		item.Prev, item.Next = nil, nil
+		if i > 0 {
+			item.Prev = &items[i-1].PdfOutlineTreeNode
+		}
+		if i < len(items)-1 {
+			item.Next = &items[i+1].PdfOutlineTreeNode
+		}
+		if item.Count == nil && item.First != nil {
+			count := int64(visibleOutlineCount(outlineItems(&item.PdfOutlineTreeNode)))
+			item.Count = &count
+		}
+	}
+
+	parent.First = &items[0].PdfOutlineTreeNode
+	parent.Last = &items[len(items)-1].PdfOutlineTreeNode
+}
+
+// visibleOutlineCount returns the number of `items` and their descendants that are visible when their parent is
+// open.
+func visibleOutlineCount(items []*pdf.PdfOutlineItem) int {
+	count := len(items)
+	for _, item := range items {
+		if item.Count != nil && *item.Count > 0 {
+			count += visibleOutlineCount(outlineItems(&item.PdfOutlineTreeNode))
+		}
+	}
+	return count
+}
+
+// newOutlineTree returns an outline tree with top level items `items`.
+func newOutlineTree(items []*pdf.PdfOutlineItem) *pdf.PdfOutline {
+	outline := pdf.NewPdfOutlineTree()
+	linkOutlineItems(&outline.PdfOutlineTreeNode, items)
+	count := int64(visibleOutlineCount(items))
+	outline.Count = &count
+	return outline
+}
@@ -0,0 +1,360 @@
+package pdfops
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/creator"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// PageOrder is a parsed page order expression, see ParsePageOrder.
+type PageOrder struct {
+	expr  string
+	terms []orderTerm
+}
+
+// orderTerm is an element of a page order expression.
+type orderTerm struct {
+	sel      *pagerange.Selection // Pages in ascending order, nil for ranges and blank pages.
+	from, to int                  // Range in either direction, -1 standing for the last page.
+	blank    bool
+	size     creator.PageSize // Size of a blank page, the size of the page before it if zero.
+}
+
+// orderedPage is a page of the output of ReorderPagesPdf: page `pageNum` of the input, or a blank page of size
+// `size` if `pageNum` is 0.
+type orderedPage struct {
+	pageNum int
+	size    creator.PageSize
+}
+
+var reOrderRange = regexp.MustCompile(`^(\d+|end|last)\s*-\s*(\d+|end|last)$`)
+
+// ParsePageOrder parses page order expression `expr`, a comma separated list of the pages of the output.  A term
+// is a page selection as accepted by pagerange.Parse, e.g. 7, 1-3, even or !5, a range in reverse order, e.g. end-1,
+// or `blank` for a blank page the size of the page before it, or of a given paper size, e.g. blank:A4 (see
+// ParsePaperSize).  Pages that are not listed are deleted and pages listed more than once are duplicated.
+func ParsePageOrder(expr string) (*PageOrder, error) {
+	order := &PageOrder{expr: expr}
+
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		t, err := parseOrderTerm(part)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid page order %q: %v", part, err)
+		}
+		order.terms = append(order.terms, t)
+	}
+
+	if len(order.terms) == 0 {
+		return nil, errors.New("Empty page order")
+	}
+	return order, nil
+}
+
+func (o *PageOrder) String() string {
+	return o.expr
+}
+
+func parseOrderTerm(str string) (orderTerm, error) {
+	lower := strings.ToLower(str)
+
+	if lower == "blank" || strings.HasPrefix(lower, "blank:") {
+		t := orderTerm{blank: true}
+		if i := strings.Index(str, ":"); i >= 0 {
+			size, err := ParsePaperSize(str[i+1:])
+			if err != nil {
+				return t, err
+			}
+			t.size = size
+		}
+		return t, nil
+	}
+
+	if m := reOrderRange.FindStringSubmatch(lower); m != nil {
+		from, err := orderPageNum(m[1])
+		if err != nil {
+			return orderTerm{}, err
+		}
+		to, err := orderPageNum(m[2])
+		if err != nil {
+			return orderTerm{}, err
+		}
+		return orderTerm{from: from, to: to}, nil
+	}
+
+	sel, err := pagerange.Parse(str)
+	if err != nil {
+		return orderTerm{}, err
+	}
+	return orderTerm{sel: sel}, nil
+}
+
+// orderPageNum parses page number `str` of a range, returning -1 for the last page.
+func orderPageNum(str string) (int, error) {
+	if str == "end" || str == "last" {
+		return -1, nil
+	}
+	n, err := strconv.Atoi(str)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid page number %q", str)
+	}
+	return n, nil
+}
+
+// pages returns the pages of the output for input `pdfReader`, in order.
+func (o *PageOrder) pages(pdfReader *pdf.PdfReader) ([]orderedPage, error) {
+	numPages := len(pdfReader.PageList)
+	resolve := func(pageNum int) (int, error) {
+		if pageNum == -1 {
+			pageNum = numPages
+		}
+		if pageNum > numPages {
+			return 0, fmt.Errorf("Page %d out of range (document has %d pages)", pageNum, numPages)
+		}
+		return pageNum, nil
+	}
+
+	pages := []orderedPage{}
+	for _, t := range o.terms {
+		switch {
+		case t.blank:
+			pages = append(pages, orderedPage{size: t.size})
+		case t.sel != nil:
+			pageNums, err := t.sel.Select(pdfReader)
+			if err != nil {
+				return nil, err
+			}
+			for _, pageNum := range pageNums {
+				pages = append(pages, orderedPage{pageNum: pageNum})
+			}
+		default:
+			from, err := resolve(t.from)
+			if err != nil {
+				return nil, err
+			}
+			to, err := resolve(t.to)
+			if err != nil {
+				return nil, err
+			}
+			step := 1
+			if from > to {
+				step = -1
+			}
+			for pageNum := from; pageNum != to+step; pageNum += step {
+				pages = append(pages, orderedPage{pageNum: pageNum})
+			}
+		}
+	}
+	return pages, nil
+}
+
+// ReorderPagesPdf writes the pages of `inputPath` in `order` to `outputPath`, deleting, duplicating and inserting
+// blank pages as specified by it.  Annotations and form fields stay on their pages and outline items follow the
+// pages they point to; copies of a page after the first do not get its form field widgets, which can only be on one
+// page.  The form fields of deleted pages and the links to them are removed.
+func ReorderPagesPdf(inputPath, outputPath string, order *PageOrder, opts Options) error {
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return err
+	}
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	pages, err := order.pages(pdfReader)
+	if err != nil {
+		return err
+	}
+	if len(pages) == 0 {
+		return errors.New("No pages selected")
+	}
+
+	pdfWriter := pdf.NewPdfWriter()
+
+	ocProps, err := pdfReader.GetOCProperties()
+	if err != nil {
+		return err
+	}
+	pdfWriter.SetOCProperties(ocProps)
+
+	// Links to pages that are deleted would be left dangling.
+	kept := map[int]bool{}
+	for _, op := range pages {
+		kept[op.pageNum] = true
+	}
+	pageNums := pageObjectNumbers(pdfReader)
+	named := namedDests(pdfReader)
+	for i, page := range pdfReader.PageList {
+		if kept[i+1] {
+			dropDeadLinks(page, pageNums, named, kept)
+		}
+	}
+
+	// Blank pages without a size get the size of the page before them, or of the first page.
+	var prev *pdf.PdfPage
+	if len(pdfReader.PageList) > 0 {
+		prev = pdfReader.PageList[0]
+	}
+
+	added := map[int]bool{}
+	for _, op := range pages {
+		var page *pdf.PdfPage
+		switch {
+		case op.pageNum == 0:
+			if page, err = newBlankPage(op.size, prev); err != nil {
+				return err
+			}
+		case added[op.pageNum]:
+			page = duplicatePage(pdfReader.PageList[op.pageNum-1])
+		default:
+			page = pdfReader.PageList[op.pageNum-1]
+			added[op.pageNum] = true
+		}
+
+		if err := pdfWriter.AddPage(page); err != nil {
+			return err
+		}
+		prev = page
+	}
+
+	if form := pdfReader.AcroForm; form != nil {
+		removed := map[pdfcore.PdfObject]bool{}
+		for i, page := range pdfReader.PageList {
+			if !added[i+1] {
+				addWidgets(removed, page)
+			}
+		}
+		if form.Fields != nil && len(removed) > 0 {
+			fields := removeWidgets(*form.Fields, removed)
+			form.Fields = &fields
+		}
+		if err := pdfWriter.SetForms(form); err != nil {
+			return err
+		}
+	}
+
+	pageObj := func(pageNum int) pdfcore.PdfObject {
+		if !added[pageNum] {
+			return nil
+		}
+		return pdfReader.PageList[pageNum-1].GetPageAsIndirectObject()
+	}
+	items := copyOutlineItems(pdfReader, outlineItems(pdfReader.GetOutlineTree()), pageObj)
+	if len(items) > 0 {
+		pdfWriter.AddOutlineTree(&newOutlineTree(items).PdfOutlineTreeNode)
+	}
+
+	return writePdf(&pdfWriter, outputPath)
+}
+
+// newBlankPage returns an empty page of `size`, or with the MediaBox and rotation of `like` if `size` is zero.
+func newBlankPage(size creator.PageSize, like *pdf.PdfPage) (*pdf.PdfPage, error) {
+	page := pdf.NewPdfPage()
+	page.Resources = pdf.NewPdfPageResources()
+
+	if size[0] > 0 && size[1] > 0 {
+		page.MediaBox = &pdf.PdfRectangle{Llx: 0, Lly: 0, Urx: size[0], Ury: size[1]}
+		return page, nil
+	}
+	if like == nil {
+		return nil, errors.New("Blank pages need a size in documents without pages")
+	}
+
+	mbox, err := like.GetMediaBox()
+	if err != nil {
+		return nil, err
+	}
+	mediaBox := *mbox
+	page.MediaBox = &mediaBox
+	if like.Rotate != nil {
+		rotate := *like.Rotate
+		page.Rotate = &rotate
+	}
+	return page, nil
+}
+
+// duplicatePage returns a copy of `page` to add to the same document again.  The copy gets copies of the
+// annotations of `page`, except for form field widgets, which can only be on one page, and popups, which belong to
+// the annotation of the original.
+func duplicatePage(page *pdf.PdfPage) *pdf.PdfPage {
+	dup := page.Duplicate()
+	dup.Annotations = nil
+	for _, annot := range page.Annotations {
+		switch annot.GetContext().(type) {
+		case *pdf.PdfAnnotationWidget:
+			unicommon.Log.Debug("Not copying form field widget %v to the copy of a page", annot)
+			continue
+		case *pdf.PdfAnnotationPopup:
+			continue
+		}
+		dup.Annotations = append(dup.Annotations, copyAnnotation(annot))
+	}
+	return dup
+}
+
+// rawAnnotation is an annotation given by its dictionary, used as the context of a PdfAnnotation.
+type rawAnnotation struct {
+	container *pdfcore.PdfIndirectObject
+}
+
+func (a *rawAnnotation) ToPdfObject() pdfcore.PdfObject {
+	return a.container
+}
+
+func (a *rawAnnotation) GetContainingPdfObject() pdfcore.PdfObject {
+	return a.container
+}
+
+// copyAnnotation returns a copy of `annot` with its own dictionary.  The P entry, which points to the page of
+// `annot`, and the Popup entry are left out.  Everything else is shared with `annot`.
+func copyAnnotation(annot *pdf.PdfAnnotation) *pdf.PdfAnnotation {
+	var obj pdfcore.PdfObject
+	if ctx := annot.GetContext(); ctx != nil {
+		obj = ctx.ToPdfObject()
+	} else {
+		obj = annot.ToPdfObject()
+	}
+
+	dict := pdfcore.MakeDict()
+	if orig := getDict(obj); orig != nil {
+		for _, key := range orig.Keys() {
+			if key == "P" || key == "Popup" {
+				continue
+			}
+			dict.Set(key, orig.Get(key))
+		}
+	}
+
+	dup := pdf.NewPdfAnnotation()
+	dup.SetContext(&rawAnnotation{container: pdfcore.MakeIndirectObject(dict)})
+	return dup
+}
+
+// dropDeadLinks removes the link annotations of `page` that go to pages not in `kept`, by page number.  `pageNums`
+// maps the object numbers of the pages to their numbers (see pageObjectNumbers) and `named` holds the named
+// destinations (see namedDests).
+func dropDeadLinks(page *pdf.PdfPage, pageNums map[int64]int, named map[string]pdfcore.PdfObject,
+	kept map[int]bool) {
+	annots := []*pdf.PdfAnnotation{}
+	for _, annot := range page.Annotations {
+		if pageNum := linkDestPageNum(annot, pageNums, named); pageNum > 0 && !kept[pageNum] {
+			unicommon.Log.Debug("Removing link to deleted page %d", pageNum)
+			continue
+		}
+		annots = append(annots, annot)
+	}
+	page.Annotations = annots
+}
@@ -0,0 +1,119 @@
+package pdfops
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/unidoc/unidoc/pdf/creator"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+)
+
+// describeTerm returns a description of `t` for comparing parsed terms.
+func describeTerm(t orderTerm) string {
+	switch {
+	case t.blank:
+		return fmt.Sprintf("blank %v", t.size)
+	case t.sel != nil:
+		return "pages " + t.sel.String()
+	}
+	return fmt.Sprintf("range %d %d", t.from, t.to)
+}
+
+func TestParsePageOrder(t *testing.T) {
+	a4 := creator.PageSizeA4
+	blankA4 := fmt.Sprintf("blank %v", a4)
+	blankA4Landscape := fmt.Sprintf("blank %v", creator.PageSize{a4[1], a4[0]})
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"7", []string{"pages 7"}},
+		{"1-3", []string{"range 1 3"}},
+		{"end-1", []string{"range -1 1"}},
+		{"5 - 2", []string{"range 5 2"}},
+		{"3-last", []string{"range 3 -1"}},
+		{"last-end", []string{"range -1 -1"}},
+		{"!5", []string{"pages !5"}},
+		{"even", []string{"pages even"}},
+		{"1-end:odd", []string{"pages 1-end:odd"}},
+		{"blank", []string{"blank [0 0]"}},
+		{"blank:A4", []string{blankA4}},
+		{"BLANK:a4-landscape", []string{blankA4Landscape}},
+		{"end-1, blank, 2, 2", []string{"range -1 1", "blank [0 0]", "pages 2", "pages 2"}},
+		{" , 1 , ", []string{"pages 1"}},
+	}
+
+	for _, test := range tests {
+		order, err := ParsePageOrder(test.expr)
+		if err != nil {
+			t.Errorf("ParsePageOrder(%q): %v", test.expr, err)
+			continue
+		}
+		got := []string{}
+		for _, term := range order.terms {
+			got = append(got, describeTerm(term))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParsePageOrder(%q) = %q, want %q", test.expr, got, test.want)
+		}
+		if order.String() != test.expr {
+			t.Errorf("ParsePageOrder(%q).String() = %q", test.expr, order.String())
+		}
+	}
+}
+
+func TestParsePageOrderErrors(t *testing.T) {
+	for _, expr := range []string{"", " , ", "0", "0-3", "2-0", "x", "blank:huge", "blank:", "3-1:odd"} {
+		if _, err := ParsePageOrder(expr); err == nil {
+			t.Errorf("ParsePageOrder(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestPageOrderPages(t *testing.T) {
+	// Ranges and blank pages only depend on the number of pages of the document.
+	pdfReader := &pdf.PdfReader{PageList: make([]*pdf.PdfPage, 5)}
+	a4 := creator.PageSizeA4
+
+	tests := []struct {
+		expr string
+		want []orderedPage
+	}{
+		{"1-3", []orderedPage{{pageNum: 1}, {pageNum: 2}, {pageNum: 3}}},
+		{"end-1", []orderedPage{{pageNum: 5}, {pageNum: 4}, {pageNum: 3}, {pageNum: 2}, {pageNum: 1}}},
+		{"4-last, 2-2", []orderedPage{{pageNum: 4}, {pageNum: 5}, {pageNum: 2}}},
+		{"3-1, 1-3", []orderedPage{{pageNum: 3}, {pageNum: 2}, {pageNum: 1}, {pageNum: 1}, {pageNum: 2},
+			{pageNum: 3}}},
+		{"blank, 1-1, blank:A4", []orderedPage{{}, {pageNum: 1}, {size: a4}}},
+		{"end-end, blank:a4-landscape", []orderedPage{{pageNum: 5}, {size: creator.PageSize{a4[1], a4[0]}}}},
+	}
+
+	for _, test := range tests {
+		order, err := ParsePageOrder(test.expr)
+		if err != nil {
+			t.Errorf("ParsePageOrder(%q): %v", test.expr, err)
+			continue
+		}
+		got, err := order.pages(pdfReader)
+		if err != nil {
+			t.Errorf("%q: %v", test.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.expr, got, test.want)
+		}
+	}
+
+	for _, expr := range []string{"6-1", "1-6", "2-9"} {
+		order, err := ParsePageOrder(expr)
+		if err != nil {
+			t.Errorf("ParsePageOrder(%q): %v", expr, err)
+			continue
+		}
+		if _, err := order.pages(pdfReader); err == nil {
+			t.Errorf("%q of 5 pages succeeded, want an error", expr)
+		}
+	}
+}
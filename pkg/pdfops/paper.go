@@ -0,0 +1,60 @@
+package pdfops
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unidoc/pdf/creator"
+)
+
+// Paper sizes that can be given by name to ParsePaperSize.
+var paperSizes = map[string]creator.PageSize{
+	"a3":      creator.PageSizeA3,
+	"a4":      creator.PageSizeA4,
+	"a5":      creator.PageSizeA5,
+	"letter":  creator.PageSizeLetter,
+	"legal":   creator.PageSizeLegal,
+	"tabloid": {792, 1224},
+}
+
+// Points per unit of the units that can be used in ParsePaperSize.
+var paperUnits = map[string]float64{
+	"":   1,
+	"pt": 1,
+	"in": 72,
+	"mm": 72 / 25.4,
+	"cm": 72 / 2.54,
+}
+
+var rePaperSize = regexp.MustCompile(`^(\d+(?:\.\d*)?)x(\d+(?:\.\d*)?)([a-z]*)$`)
+
+// ParsePaperSize parses paper size `s`, which is a name (A3, A4, A5, Letter, Legal or Tabloid) or the width and
+// height as WxH in points, or in another unit with a pt, in, mm or cm suffix, e.g. 210x297mm.  Names are portrait,
+// a `-landscape` suffix swaps the width and height, e.g. A4-landscape.
+func ParsePaperSize(s string) (creator.PageSize, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	landscape := strings.HasSuffix(s, "-landscape")
+	s = strings.TrimSuffix(s, "-landscape")
+
+	size, ok := paperSizes[s]
+	if !ok {
+		m := rePaperSize.FindStringSubmatch(s)
+		if m == nil {
+			return creator.PageSize{}, fmt.Errorf("Invalid paper size %q", s)
+		}
+		unit, ok := paperUnits[m[3]]
+		w, _ := strconv.ParseFloat(m[1], 64)
+		h, _ := strconv.ParseFloat(m[2], 64)
+		if !ok || w <= 0 || h <= 0 {
+			return creator.PageSize{}, fmt.Errorf("Invalid paper size %q", s)
+		}
+		size = creator.PageSize{w * unit, h * unit}
+	}
+
+	if landscape {
+		size[0], size[1] = size[1], size[0]
+	}
+	return size, nil
+}
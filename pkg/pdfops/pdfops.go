@@ -0,0 +1,35 @@
+/*
+ * Package pdfops contains the PDF operations behind the pdftool command.
+ *
+ * The functions here are the ones from the standalone examples under pdf/ (mergePdf, splitPdf, cropPdf,
+ * outputPdfText, ...) turned into a reusable library: they take their settings as arguments rather than from
+ * os.Args and share the code for opening and writing documents.
+ */
+
+package pdfops
+
+import (
+	"os"
+
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+)
+
+// Options holds the settings that are shared by all operations.
+type Options struct {
+	// Open specifies the passwords and repair behaviour used for opening input documents.
+	Open opener.Options
+}
+
+// writePdf writes the contents of `pdfWriter` to a new file at `outputPath`.
+func writePdf(pdfWriter *pdf.PdfWriter, outputPath string) error {
+	fWrite, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+
+	defer fWrite.Close()
+
+	return pdfWriter.Write(fWrite)
+}
@@ -0,0 +1,211 @@
+package pdfops
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// Thresholds of the reading order reconstruction, relative to the median font size of a page.
+const (
+	columnGap = 1.0  // Minimum width of the whitespace between columns.
+	blockGap  = 0.7  // Minimum height of the whitespace between blocks.
+	wordSpace = 0.15 // Minimum gap between runs on a line that is taken as a space.
+)
+
+// TextLine is a line of text reconstructed from text runs.
+type TextLine struct {
+	Text string `json:"text"`
+	BBox Box    `json:"bbox"`
+}
+
+// TextBlock is a column or paragraph of text, made of lines that are read top to bottom.
+type TextBlock struct {
+	Page  int        `json:"page"`
+	BBox  Box        `json:"bbox"`
+	Lines []TextLine `json:"lines"`
+}
+
+// ReadingOrder groups text runs `runs` (see ExtractTextRuns) into blocks of lines in reading order.  The blocks of a
+// page are found by recursively cutting the page along whitespace: first into columns at vertical gaps wider than
+// columnGap, then into blocks at horizontal gaps, so that a heading spanning several columns is read before them.
+// Only horizontal text is supported.
+func ReadingOrder(runs []*TextRun) []TextBlock {
+	pageNums := []int{}
+	byPage := map[int][]*TextRun{}
+	for _, run := range runs {
+		if strings.TrimSpace(run.Text) == "" {
+			continue
+		}
+		if _, has := byPage[run.Page]; !has {
+			pageNums = append(pageNums, run.Page)
+		}
+		byPage[run.Page] = append(byPage[run.Page], run)
+	}
+
+	blocks := []TextBlock{}
+	for _, pageNum := range pageNums {
+		pageRuns := byPage[pageNum]
+		size := medianFontSize(pageRuns)
+		for _, group := range xyCut(pageRuns, size) {
+			blocks = append(blocks, newTextBlock(pageNum, group))
+		}
+	}
+	return blocks
+}
+
+// ReadingOrderText returns the text of `blocks` with a blank line between blocks and a form feed between pages.
+func ReadingOrderText(blocks []TextBlock) string {
+	var text strings.Builder
+	for i, block := range blocks {
+		if i > 0 {
+			if block.Page != blocks[i-1].Page {
+				text.WriteString("\f")
+			} else {
+				text.WriteString("\n")
+			}
+		}
+		for _, line := range block.Lines {
+			text.WriteString(line.Text + "\n")
+		}
+	}
+	return text.String()
+}
+
+// medianFontSize returns the median font size of `runs`.
+func medianFontSize(runs []*TextRun) float64 {
+	sizes := []float64{}
+	for _, run := range runs {
+		sizes = append(sizes, run.FontSize)
+	}
+	sort.Float64s(sizes)
+	if len(sizes) == 0 || sizes[len(sizes)/2] <= 0 {
+		return 10
+	}
+	return sizes[len(sizes)/2]
+}
+
+// xyCut splits `runs` into groups in reading order.
+func xyCut(runs []*TextRun, size float64) [][]*TextRun {
+	parts := splitAtGaps(runs, columnGap*size, true)
+	if len(parts) == 1 {
+		parts = splitAtGaps(runs, blockGap*size, false)
+	}
+	if len(parts) == 1 {
+		return parts
+	}
+
+	groups := [][]*TextRun{}
+	for _, part := range parts {
+		groups = append(groups, xyCut(part, size)...)
+	}
+	return groups
+}
+
+// splitAtGaps splits `runs` where their projection onto the x axis (if `vertical`) or the y axis has a gap of at
+// least `minGap`.  Columns are returned left to right and blocks top to bottom.
+func splitAtGaps(runs []*TextRun, minGap float64, vertical bool) [][]*TextRun {
+	lo, hi := 1, 3
+	if vertical {
+		lo, hi = 0, 2
+	}
+
+	sorted := append([]*TextRun{}, runs...)
+	if vertical {
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].BBox[lo] < sorted[j].BBox[lo] })
+	} else {
+		// Top to bottom.
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].BBox[hi] > sorted[j].BBox[hi] })
+	}
+
+	parts := [][]*TextRun{}
+	var part []*TextRun
+	edge := 0.0 // Far edge of the current part in the direction of the sweep.
+	for _, run := range sorted {
+		if part != nil {
+			gap := run.BBox[lo] - edge
+			if !vertical {
+				gap = edge - run.BBox[hi]
+			}
+			if gap >= minGap {
+				parts = append(parts, part)
+				part = nil
+			}
+		}
+		if part == nil {
+			edge = run.BBox[hi]
+			if !vertical {
+				edge = run.BBox[lo]
+			}
+		}
+		part = append(part, run)
+		if vertical {
+			edge = math.Max(edge, run.BBox[hi])
+		} else {
+			edge = math.Min(edge, run.BBox[lo])
+		}
+	}
+	if part != nil {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// newTextBlock returns the block of `runs`, which are assembled into lines.
+func newTextBlock(pageNum int, runs []*TextRun) TextBlock {
+	block := TextBlock{Page: pageNum, BBox: runs[0].BBox}
+
+	// Top to bottom, then left to right.
+	sorted := append([]*TextRun{}, runs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return centerY(sorted[i]) > centerY(sorted[j])
+	})
+
+	lines := [][]*TextRun{}
+	for _, run := range sorted {
+		block.BBox = block.BBox.union(run.BBox)
+		if n := len(lines); n > 0 && onSameLine(lines[n-1], run) {
+			lines[n-1] = append(lines[n-1], run)
+			continue
+		}
+		lines = append(lines, []*TextRun{run})
+	}
+
+	for _, line := range lines {
+		block.Lines = append(block.Lines, newTextLine(line))
+	}
+	return block
+}
+
+func centerY(run *TextRun) float64 {
+	return (run.BBox[1] + run.BBox[3]) / 2
+}
+
+// onSameLine returns true if the vertical center of `run` is within half a line of the runs in `line`.
+func onSameLine(line []*TextRun, run *TextRun) bool {
+	first := line[0]
+	height := math.Min(first.BBox[3]-first.BBox[1], run.BBox[3]-run.BBox[1])
+	return math.Abs(centerY(first)-centerY(run)) < height/2
+}
+
+// newTextLine joins `runs` from left to right, adding spaces where they are apart.
+func newTextLine(runs []*TextRun) TextLine {
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].BBox[0] < runs[j].BBox[0] })
+
+	var text strings.Builder
+	line := TextLine{BBox: runs[0].BBox}
+	for i, run := range runs {
+		if i > 0 {
+			prev := runs[i-1]
+			gap := run.BBox[0] - prev.BBox[2]
+			if gap > wordSpace*run.FontSize && !strings.HasSuffix(prev.Text, " ") &&
+				!strings.HasPrefix(run.Text, " ") {
+				text.WriteString(" ")
+			}
+		}
+		text.WriteString(run.Text)
+		line.BBox = line.BBox.union(run.BBox)
+	}
+	line.Text = strings.TrimSpace(text.String())
+	return line
+}
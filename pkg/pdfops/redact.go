@@ -0,0 +1,469 @@
+package pdfops
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcontent "github.com/unidoc/unidoc/pdf/contentstream"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// RedactOptions are the options of RedactPdf.
+type RedactOptions struct {
+	Terms      []string             // Literal strings to redact.
+	Regexps    []string             // Regular expressions (RE2 syntax) to redact.
+	IgnoreCase bool                 // Match Terms and Regexps case insensitively.
+	Rects      []RedactRect         // Areas to redact regardless of what they contain.
+	Pages      *pagerange.Selection // Pages to redact, nil for all.
+
+	FillColor   RGB    // Color of the rectangles drawn over the redacted areas.
+	Replacement string // Replaces the matches in annotations, form field values, bookmarks and metadata.
+}
+
+// RedactRect is an area to redact, in default user space.
+type RedactRect struct {
+	Page int // 0 for all pages.
+	BBox Box
+}
+
+// Redaction is an area removed by RedactPdf.
+type Redaction struct {
+	Page int    `json:"page"`
+	BBox Box    `json:"bbox"`
+	Text string `json:"text,omitempty"` // The matched text, empty for RedactRect areas.
+}
+
+// RedactPdf removes the text matching `redactOpts.Terms` and `redactOpts.Regexps` and everything in
+// `redactOpts.Rects` from the pages of `inputPath` and writes the result to `outputPath`.
+//
+// Unlike drawing a box over the text, the content is removed from the content streams: the characters are taken
+// out of their text showing operators, the covered parts of images are overwritten and inline images touching a
+// redacted area are dropped.  Form XObjects with redacted content are replaced by redacted copies.  A rectangle is
+// drawn in `redactOpts.FillColor` over each area.  Annotations overlapping a redacted area are removed (widgets
+// lose their appearance instead, so that viewers rebuild it from the scrubbed value), and the matches are replaced
+// in the remaining annotations, the form field values, the bookmarks and the document metadata.
+func RedactPdf(inputPath, outputPath string, redactOpts RedactOptions, opts Options) ([]Redaction, error) {
+	re, err := redactPattern(redactOpts)
+	if err != nil {
+		return nil, err
+	}
+	if re == nil && len(redactOpts.Rects) == 0 {
+		return nil, errors.New("Nothing to redact")
+	}
+
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return nil, err
+	}
+
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	selected, err := redactOpts.Pages.SelectSet(pdfReader)
+	if err != nil {
+		return nil, err
+	}
+
+	pdfWriter := pdf.NewPdfWriter()
+
+	ocProps, err := pdfReader.GetOCProperties()
+	if err != nil {
+		return nil, err
+	}
+	pdfWriter.SetOCProperties(ocProps)
+
+	// The pages are redacted before any is added to the writer, so that the originals of redacted images and forms
+	// that no page uses any more can be removed from shared resource dictionaries first.
+	scrub := newScrubber(re, redactOpts.Replacement)
+	redactions := []Redaction{}
+	pageAreas := map[int][]Box{}
+	replaced := resourceNames{}
+	for i, page := range pdfReader.PageList {
+		pageNum := i + 1
+		if !selected[pageNum] {
+			continue
+		}
+
+		pageRedactions, areas, err := redactPage(page, pageNum, re, redactOpts, replaced)
+		if err != nil {
+			return nil, fmt.Errorf("Page %d: %v", pageNum, err)
+		}
+		redactions = append(redactions, pageRedactions...)
+		pageAreas[pageNum] = areas
+
+		bboxes := []Box{}
+		for _, r := range pageRedactions {
+			bboxes = append(bboxes, r.BBox)
+		}
+		scrub.annotations(page, bboxes)
+	}
+
+	if err := removeReplacedXObjects(pdfReader, replaced); err != nil {
+		return nil, err
+	}
+
+	for _, page := range pdfReader.PageList {
+		if err := pdfWriter.AddPage(page); err != nil {
+			return nil, err
+		}
+	}
+
+	if form := pdfReader.AcroForm; form != nil {
+		if form.Fields != nil {
+			scrub.fields(*form.Fields)
+		}
+		if scrub.widgetsChanged {
+			form.NeedAppearances = pdfcore.MakeBool(true)
+		}
+		if err := pdfWriter.SetForms(form); err != nil {
+			return nil, err
+		}
+	}
+
+	items := copyOutlineItems(pdfReader, outlineItems(pdfReader.GetOutlineTree()), readerPageObjects(pdfReader))
+	scrub.outlineItems(items)
+	if len(items) > 0 {
+		pdfWriter.AddOutlineTree(&newOutlineTree(items).PdfOutlineTreeNode)
+	}
+
+	var buf bytes.Buffer
+	if err := pdfWriter.Write(&buf); err != nil {
+		return nil, err
+	}
+
+	// The metadata is rewritten even without matches, as the XMP may hold more than the information dictionary.
+	info, xmp, err := readMetadata(pdfReader)
+	if err != nil {
+		return nil, err
+	}
+	previous := infoTexts(info)
+	for key, val := range info {
+		if str, ok := val.(*pdfcore.PdfObjectString); ok {
+			if text := decodeTextString(string(*str)); scrub.text(text) != text {
+				info[key] = makeTextString(scrub.text(text))
+			}
+		}
+	}
+	info["ModDate"] = makeTextString(formatPdfDate(time.Now()))
+
+	xmp = updateXMP(xmp, infoTexts(info), previous, scrub.text)
+	data, err := appendMetadataUpdate(buf.Bytes(), pdfReader, info, xmp)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyRedaction(data, pageAreas); err != nil {
+		return nil, err
+	}
+
+	return redactions, ioutil.WriteFile(outputPath, data, 0644)
+}
+
+// redactPattern returns the regular expression matching any of the terms and regular expressions of `redactOpts`,
+// or nil if there are none.
+func redactPattern(redactOpts RedactOptions) (*regexp.Regexp, error) {
+	alternatives := []string{}
+	for _, term := range redactOpts.Terms {
+		if term != "" {
+			alternatives = append(alternatives, regexp.QuoteMeta(term))
+		}
+	}
+	for _, expr := range redactOpts.Regexps {
+		if _, err := regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("Invalid pattern %q: %v", expr, err)
+		}
+		alternatives = append(alternatives, "(?:"+expr+")")
+	}
+	if len(alternatives) == 0 {
+		return nil, nil
+	}
+
+	return CompilePattern(strings.Join(alternatives, "|"),
+		FindOptions{Regexp: true, IgnoreCase: redactOpts.IgnoreCase})
+}
+
+// redactPage removes the matches of `re` and the areas of `redactOpts.Rects` from `page`.  Returns the redactions
+// and the areas removed.  The names of the images and forms replaced by redacted copies are added to `replaced`.
+func redactPage(page *pdf.PdfPage, pageNum int, re *regexp.Regexp, redactOpts RedactOptions,
+	replaced resourceNames) ([]Redaction, []Box, error) {
+	contents, err := page.GetAllContentStreams()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	walker := newTextWalker(pageNum)
+	pageStream := &contentStream{resources: page.Resources}
+	if err := walker.walk(contents, pageStream, identityMatrix, 0); err != nil {
+		return nil, nil, err
+	}
+
+	redactions := []Redaction{}
+	areas := []Box{}
+	if re != nil {
+		for _, match := range FindInRuns(walker.runs, re) {
+			areas = append(areas, match.Boxes...)
+			redactions = append(redactions, Redaction{Page: pageNum, BBox: match.BBox, Text: match.Text})
+		}
+	}
+	for _, rect := range redactOpts.Rects {
+		if rect.Page == 0 || rect.Page == pageNum {
+			areas = append(areas, rect.BBox)
+			redactions = append(redactions, Redaction{Page: pageNum, BBox: rect.BBox})
+		}
+	}
+	if len(areas) == 0 {
+		return redactions, nil, nil
+	}
+
+	rw := newStreamRewriter(replaced)
+	removed := 0
+	for _, run := range walker.runs {
+		removed += redactRun(run, areas, rw)
+	}
+	for _, img := range walker.images {
+		changed, err := redactImage(img, areas, rw)
+		if err != nil {
+			return nil, nil, err
+		}
+		if changed {
+			removed++
+		}
+	}
+	unicommon.Log.Debug("Page %d: %d areas, %d characters and images removed", pageNum, len(areas), removed)
+
+	// Forms are drawn by the streams before them, so their changes are passed on to their parents in reverse order.
+	for i := len(walker.streams) - 1; i > 0; i-- {
+		if stream := walker.streams[i]; rw.dirty[stream] {
+			if err := redactForm(stream, rw); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if rw.dirty[pageStream] {
+		contents = rw.contents(pageStream)
+	}
+
+	// Draw the fill rectangles outside of the page's graphics state.
+	cc := pdfcontent.NewContentCreator()
+	cc.Add_q()
+	fill := redactOpts.FillColor
+	cc.Add_rg(fill[0], fill[1], fill[2])
+	for _, area := range areas {
+		cc.Add_re(area[0], area[1], area[2]-area[0], area[3]-area[1])
+	}
+	cc.Add_f()
+	cc.Add_Q()
+
+	contents = "q\n" + contents + "\nQ\n" + cc.String()
+	if err := page.SetContentStreams([]string{contents}, pdfcore.NewFlateEncoder()); err != nil {
+		return nil, nil, err
+	}
+
+	return redactions, areas, nil
+}
+
+// scrubber replaces the matches of a pattern in the strings of a document.
+type scrubber struct {
+	re             *regexp.Regexp // nil if there is nothing to replace.
+	replacement    string
+	widgetsChanged bool
+}
+
+func newScrubber(re *regexp.Regexp, replacement string) *scrubber {
+	return &scrubber{re: re, replacement: replacement}
+}
+
+// text returns `s` with the matches replaced.
+func (s *scrubber) text(str string) string {
+	if s.re == nil {
+		return str
+	}
+	return s.re.ReplaceAllLiteralString(str, s.replacement)
+}
+
+// object replaces the matches in the strings of `obj` and the direct arrays and dictionaries in it.  Returns the
+// new object and whether anything was replaced.
+func (s *scrubber) object(obj pdfcore.PdfObject) (pdfcore.PdfObject, bool) {
+	if s.re == nil {
+		return obj, false
+	}
+
+	switch t := obj.(type) {
+	case *pdfcore.PdfObjectString:
+		text := decodeTextString(string(*t))
+		if scrubbed := s.text(text); scrubbed != text {
+			return makeTextString(scrubbed), true
+		}
+	case *pdfcore.PdfObjectArray:
+		changed := false
+		for i, elem := range *t {
+			var elemChanged bool
+			(*t)[i], elemChanged = s.object(elem)
+			changed = changed || elemChanged
+		}
+		return t, changed
+	case *pdfcore.PdfObjectDictionary:
+		changed := false
+		for _, key := range t.Keys() {
+			if val, valChanged := s.object(t.Get(key)); valChanged {
+				t.Set(key, val)
+				changed = true
+			}
+		}
+		return t, changed
+	}
+	return obj, false
+}
+
+// annotations scrubs the annotations of `page`.  Annotations overlapping `areas` are removed, except for widgets,
+// whose appearance is removed instead.
+func (s *scrubber) annotations(page *pdf.PdfPage, areas []Box) {
+	kept := []*pdf.PdfAnnotation{}
+	for _, annot := range page.Annotations {
+		_, isWidget := annot.GetContext().(*pdf.PdfAnnotationWidget)
+
+		if rect, ok := pdfcore.TraceToDirectObject(annot.Rect).(*pdfcore.PdfObjectArray); ok && len(*rect) == 4 {
+			box := Box{}
+			for i := range box {
+				box[i], _ = numberValue((*rect)[i])
+			}
+			box = box.normalized()
+			hit := false
+			for _, area := range areas {
+				hit = hit || area.overlaps(box)
+			}
+			if hit && !isWidget {
+				unicommon.Log.Debug("Removing annotation %v in a redacted area", annot)
+				continue
+			}
+			if hit {
+				annot.AP = nil
+				s.widgetsChanged = true
+			}
+		}
+
+		changed := false
+		for _, field := range annotationStrings(annot) {
+			var fieldChanged bool
+			*field, fieldChanged = s.object(*field)
+			changed = changed || fieldChanged
+		}
+		if changed {
+			// The appearance may show the text that was replaced.
+			annot.AP = nil
+			s.widgetsChanged = s.widgetsChanged || isWidget
+		}
+		kept = append(kept, annot)
+	}
+	page.Annotations = kept
+}
+
+// annotationStrings returns the entries of `annot` that hold text: Contents and NM, and for markup annotations
+// T (author), Subj and RC (rich text).
+func annotationStrings(annot *pdf.PdfAnnotation) []*pdfcore.PdfObject {
+	entries := []*pdfcore.PdfObject{&annot.Contents, &annot.NM}
+	if markup := annotationMarkup(annot); markup != nil {
+		entries = append(entries, &markup.T, &markup.Subj, &markup.RC)
+	}
+	return entries
+}
+
+// annotationMarkup returns the markup entries of `annot`, or nil if it is not a markup annotation.
+func annotationMarkup(annot *pdf.PdfAnnotation) *pdf.PdfAnnotationMarkup {
+	switch t := annot.GetContext().(type) {
+	case *pdf.PdfAnnotationText:
+		return t.PdfAnnotationMarkup
+	case *pdf.PdfAnnotationFreeText:
+		return t.PdfAnnotationMarkup
+	case *pdf.PdfAnnotationLine:
+		return t.PdfAnnotationMarkup
+	case *pdf.PdfAnnotationSquare:
+		return t.PdfAnnotationMarkup
+	case *pdf.PdfAnnotationCircle:
+		return t.PdfAnnotationMarkup
+	case *pdf.PdfAnnotationPolygon:
+		return t.PdfAnnotationMarkup
+	case *pdf.PdfAnnotationPolyLine:
+		return t.PdfAnnotationMarkup
+	case *pdf.PdfAnnotationHighlight:
+		return t.PdfAnnotationMarkup
+	case *pdf.PdfAnnotationUnderline:
+		return t.PdfAnnotationMarkup
+	case *pdf.PdfAnnotationSquiggly:
+		return t.PdfAnnotationMarkup
+	case *pdf.PdfAnnotationStrikeOut:
+		return t.PdfAnnotationMarkup
+	case *pdf.PdfAnnotationCaret:
+		return t.PdfAnnotationMarkup
+	case *pdf.PdfAnnotationStamp:
+		return t.PdfAnnotationMarkup
+	case *pdf.PdfAnnotationInk:
+		return t.PdfAnnotationMarkup
+	case *pdf.PdfAnnotationFileAttachment:
+		return t.PdfAnnotationMarkup
+	case *pdf.PdfAnnotationSound:
+		return t.PdfAnnotationMarkup
+	case *pdf.PdfAnnotationRedact:
+		return t.PdfAnnotationMarkup
+	}
+	return nil
+}
+
+// fields scrubs the values and tooltips of `fields` and their descendants.
+func (s *scrubber) fields(fields []*pdf.PdfField) {
+	for _, field := range fields {
+		for _, entry := range []*pdfcore.PdfObject{&field.V, &field.DV, &field.TU} {
+			var changed bool
+			*entry, changed = s.object(*entry)
+			s.widgetsChanged = s.widgetsChanged || changed
+		}
+		s.fields(childFields(field))
+	}
+}
+
+// outlineItems scrubs the titles of `items` and their descendants.
+func (s *scrubber) outlineItems(items []*pdf.PdfOutlineItem) {
+	for _, item := range items {
+		if title := outlineTitle(item); s.text(title) != title {
+			item.Title = makeTextString(s.text(title))
+		}
+		s.outlineItems(outlineItems(&item.PdfOutlineTreeNode))
+	}
+}
+
+// normalized returns `b` with its corners ordered as [llx lly urx ury].
+func (b Box) normalized() Box {
+	return Box{math.Min(b[0], b[2]), math.Min(b[1], b[3]), math.Max(b[0], b[2]), math.Max(b[1], b[3])}
+}
+
+// makeTextString returns `s` as a PDF text string: as it is if it is ASCII, otherwise UTF-16BE with a byte order
+// mark.
+func makeTextString(s string) *pdfcore.PdfObjectString {
+	ascii := true
+	for _, r := range s {
+		if r > 0x7e {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return pdfcore.MakeString(s)
+	}
+
+	b := []byte{0xfe, 0xff}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return pdfcore.MakeString(string(b))
+}
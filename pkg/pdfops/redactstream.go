@@ -0,0 +1,610 @@
+package pdfops
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcontent "github.com/unidoc/unidoc/pdf/contentstream"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+)
+
+// streamRewriter collects the changes to the operations of the content streams of a page.
+type streamRewriter struct {
+	replace  map[*pdfcontent.ContentStreamOperation][]*pdfcontent.ContentStreamOperation // nil removes.
+	dirty    map[*contentStream]bool
+	replaced resourceNames // XObjects replaced by redacted copies.
+}
+
+func newStreamRewriter(replaced resourceNames) *streamRewriter {
+	return &streamRewriter{
+		replace:  map[*pdfcontent.ContentStreamOperation][]*pdfcontent.ContentStreamOperation{},
+		dirty:    map[*contentStream]bool{},
+		replaced: replaced,
+	}
+}
+
+// set replaces `op` of `stream` by `ops`.
+func (rw *streamRewriter) set(stream *contentStream, op *pdfcontent.ContentStreamOperation,
+	ops ...*pdfcontent.ContentStreamOperation) {
+	rw.replace[op] = ops
+	rw.dirty[stream] = true
+}
+
+// contents returns the contents of `stream` with the replacements applied.
+func (rw *streamRewriter) contents(stream *contentStream) string {
+	ops := pdfcontent.ContentStreamOperations{}
+	for _, op := range *stream.operations {
+		if repl, ok := rw.replace[op]; ok {
+			ops = append(ops, repl...)
+			continue
+		}
+		ops = append(ops, op)
+	}
+	return string(ops.Bytes())
+}
+
+// inArea returns true if the center of `box` is inside one of `areas`.
+func inArea(box Box, areas []Box) bool {
+	x, y := (box[0]+box[2])/2, (box[1]+box[3])/2
+	for _, area := range areas {
+		if x >= area[0] && x <= area[2] && y >= area[1] && y <= area[3] {
+			return true
+		}
+	}
+	return false
+}
+
+// overlaps returns true if `box` and `box2` intersect.
+func (b Box) overlaps(b2 Box) bool {
+	return b[0] < b2[2] && b2[0] < b[2] && b[1] < b2[3] && b2[1] < b[3]
+}
+
+// redactRun removes the characters of `run` whose center lies in one of `areas` from its content stream.  The
+// operator is replaced by a TJ in which the removed characters are replaced by position adjustments of the same
+// width, so that the remaining text does not move.  Returns the number of characters removed.
+func redactRun(run *TextRun, areas []Box, rw *streamRewriter) int {
+	removed := map[int]bool{}
+	for _, piece := range run.pieces {
+		if piece.code != nil && inArea(run.Chars[piece.char].BBox, areas) {
+			removed[piece.char] = true
+		}
+	}
+	if len(removed) == 0 {
+		return 0
+	}
+
+	arr := pdfcore.PdfObjectArray{}
+	str := []byte{}
+	flush := func() {
+		if len(str) > 0 {
+			arr = append(arr, pdfcore.MakeString(string(str)))
+			str = []byte{}
+		}
+	}
+	addAdjust := func(adjust float64) {
+		flush()
+		if n := len(arr); n > 0 {
+			if prev, ok := arr[n-1].(*pdfcore.PdfObjectFloat); ok {
+				arr[n-1] = pdfcore.MakeFloat(float64(*prev) + adjust)
+				return
+			}
+		}
+		arr = append(arr, pdfcore.MakeFloat(adjust))
+	}
+
+	for _, piece := range run.pieces {
+		switch {
+		case piece.code == nil:
+			addAdjust(piece.adjust)
+		case removed[piece.char]:
+			addAdjust(-piece.adjust)
+		default:
+			str = append(str, piece.code...)
+		}
+	}
+	flush()
+
+	tj := &pdfcontent.ContentStreamOperation{Operand: "TJ", Params: []pdfcore.PdfObject{&arr}}
+	nextLine := &pdfcontent.ContentStreamOperation{Operand: "T*"}
+
+	op := run.op
+	switch op.Operand {
+	case "'":
+		rw.set(run.stream, op, nextLine, tj)
+	case "\"":
+		// " sets the word and character spacing, which stay in effect afterwards.
+		ops := []*pdfcontent.ContentStreamOperation{}
+		if len(op.Params) == 3 {
+			ops = append(ops,
+				&pdfcontent.ContentStreamOperation{Operand: "Tw", Params: op.Params[0:1]},
+				&pdfcontent.ContentStreamOperation{Operand: "Tc", Params: op.Params[1:2]})
+		}
+		rw.set(run.stream, op, append(ops, nextLine, tj)...)
+	default:
+		rw.set(run.stream, op, tj)
+	}
+
+	return len(removed)
+}
+
+// redactImage removes the parts of image `img` that are in `areas`.  XObject images are replaced by a copy that is
+// black in the areas, inline images and stencil masks are removed altogether.  Returns true if the image was
+// changed.
+func redactImage(img imagePlacement, areas []Box, rw *streamRewriter) (bool, error) {
+	bounds := img.ctm.transformBox(0, 0, 1, 1)
+	hits := []Box{}
+	for _, area := range areas {
+		if area.overlaps(bounds) {
+			hits = append(hits, area)
+		}
+	}
+	if len(hits) == 0 {
+		return false, nil
+	}
+
+	if img.op.Operand == "BI" {
+		rw.set(img.stream, img.op)
+		return true, nil
+	}
+
+	name, ok := img.op.Params[0].(*pdfcore.PdfObjectName)
+	if !ok {
+		return false, errors.New("Invalid XObject name")
+	}
+	resources := img.stream.resources
+	ximg, err := resources.GetXObjectImageByName(*name)
+	if err != nil {
+		return false, err
+	}
+	if mask, ok := pdfcore.TraceToDirectObject(ximg.ImageMask).(*pdfcore.PdfObjectBool); ok && bool(*mask) {
+		rw.set(img.stream, img.op)
+		return true, nil
+	}
+	inv, ok := img.ctm.inverse()
+	if !ok {
+		// A degenerate image is not visible.
+		return false, nil
+	}
+
+	image, err := ximg.ToImage()
+	if err != nil {
+		return false, err
+	}
+	fillSamples(image, hits, inv, blackSamples(ximg, image))
+
+	redacted, err := pdf.NewXObjectImageFromImage(image, ximg.ColorSpace, pdfcore.NewFlateEncoder())
+	if err != nil {
+		return false, err
+	}
+	redacted.Decode = ximg.Decode
+
+	// The soft mask is copied with the same areas made transparent, so that it does not show the shape of what
+	// was removed.
+	if smaskStream, ok := pdfcore.TraceToDirectObject(ximg.SMask).(*pdfcore.PdfObjectStream); ok {
+		smask, err := redactSoftMask(smaskStream, hits, inv)
+		if err != nil {
+			return false, err
+		}
+		redacted.SMask = smask
+	}
+
+	newName := newXObjectName(resources, *name)
+	if err := resources.SetXObjectImageByName(newName, redacted); err != nil {
+		return false, err
+	}
+	rw.set(img.stream, img.op, doOperation(newName))
+	rw.replaced.add(getDict(resources.XObject), *name)
+
+	return true, nil
+}
+
+// fillSamples sets the pixels of `image` in `areas` to `pixel`.  `inv` maps default user space to image space.
+func fillSamples(image *pdf.Image, areas []Box, inv matrix, pixel []uint32) {
+	samples := image.GetSamples()
+	width, height := int(image.Width), int(image.Height)
+	comps := len(pixel)
+
+	for _, area := range areas {
+		x0, y0, x1, y1 := sampleRect(inv, area, width, height)
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				for c := 0; c < comps; c++ {
+					if i := (y*width+x)*comps + c; i < len(samples) {
+						samples[i] = pixel[c]
+					}
+				}
+			}
+		}
+	}
+	image.SetSamples(samples)
+}
+
+// redactSoftMask returns a copy of soft mask `smaskStream` that is transparent in `areas`.  `inv` maps default user
+// space to the image space of the masked image, which is the same as that of the mask.
+func redactSoftMask(smaskStream *pdfcore.PdfObjectStream, areas []Box, inv matrix) (pdfcore.PdfObject, error) {
+	smask, err := pdf.NewXObjectImageFromStream(smaskStream)
+	if err != nil {
+		return nil, err
+	}
+	image, err := smask.ToImage()
+	if err != nil {
+		return nil, err
+	}
+
+	// Soft masks are in DeviceGray, where the decoded value 0 is transparent.
+	transparent := uint32(0)
+	if decodeInverted(smask.Decode, 1)[0] {
+		transparent = uint32(1)<<uint(image.BitsPerComponent) - 1
+	}
+	fillSamples(image, areas, inv, []uint32{transparent})
+
+	redacted, err := pdf.NewXObjectImageFromImage(image, pdf.NewPdfColorspaceDeviceGray(), pdfcore.NewFlateEncoder())
+	if err != nil {
+		return nil, err
+	}
+	redacted.Decode = smask.Decode
+	return redacted.ToPdfObject(), nil
+}
+
+// blackSamples returns the samples of a pixel of `image`, the image of `ximg`, that is black, or as dark as
+// possible in Indexed color spaces.  The color space and the Decode array of `ximg` are taken into account: the
+// sample values that are black are 0 in DeviceGray and DeviceRGB, but the highest value in the K component of
+// DeviceCMYK or with an inverted Decode array.
+func blackSamples(ximg *pdf.XObjectImage, image *pdf.Image) []uint32 {
+	comps := image.ColorComponents
+	if comps < 1 {
+		comps = 1
+	}
+	maxVal := uint32(1)<<uint(image.BitsPerComponent) - 1
+	inverted := decodeInverted(ximg.Decode, comps)
+
+	// Without a usable color space, the lowest decoded value of each component is assumed to be black.
+	fallback := make([]uint32, comps)
+	for c := range fallback {
+		if inverted[c] != (comps == 4 && c == 3) {
+			fallback[c] = maxVal
+		}
+	}
+	if ximg.ColorSpace == nil {
+		return fallback
+	}
+
+	// The candidates are every value of single component images, which may be Indexed, and the combinations of the
+	// lowest, middle and highest value of each component otherwise.
+	values := []uint32{0, maxVal / 2, maxVal}
+	if comps == 1 && maxVal <= 255 {
+		values = values[:0]
+		for v := uint32(0); v <= maxVal; v++ {
+			values = append(values, v)
+		}
+	}
+	candidates := [][]uint32{{}}
+	for c := 0; c < comps; c++ {
+		next := [][]uint32{}
+		for _, cand := range candidates {
+			for _, v := range values {
+				next = append(next, append(cand[:len(cand):len(cand)], v))
+			}
+		}
+		candidates = next
+	}
+
+	// Convert the candidates to RGB as one image and pick the darkest.  The color space sees the decoded values.
+	samples := []uint32{}
+	for _, cand := range candidates {
+		for c, v := range cand {
+			if inverted[c] {
+				v = maxVal - v
+			}
+			samples = append(samples, v)
+		}
+	}
+	probe := pdf.Image{Width: int64(len(candidates)), Height: 1, BitsPerComponent: image.BitsPerComponent,
+		ColorComponents: comps}
+	probe.SetSamples(samples)
+	rgbImg, err := ximg.ColorSpace.ImageToRGB(probe)
+	if err != nil {
+		unicommon.Log.Debug("Unable to convert image to RGB, assuming black is the lowest value: %v", err)
+		return fallback
+	}
+	rgb := rgbImg.GetSamples()
+
+	best, bestLum := -1, 0.0
+	for i := range candidates {
+		if 3*i+2 >= len(rgb) {
+			break
+		}
+		lum := 0.3*float64(rgb[3*i]) + 0.59*float64(rgb[3*i+1]) + 0.11*float64(rgb[3*i+2])
+		if best < 0 || lum < bestLum {
+			best, bestLum = i, lum
+		}
+	}
+	if best < 0 {
+		return fallback
+	}
+	return candidates[best]
+}
+
+// decodeInverted returns for each of the `comps` components of an image whether Decode array `decode` inverts it,
+// i.e. maps the lowest sample value to the highest value of the component.
+func decodeInverted(decode pdfcore.PdfObject, comps int) []bool {
+	inverted := make([]bool, comps)
+	arr, ok := pdfcore.TraceToDirectObject(decode).(*pdfcore.PdfObjectArray)
+	if !ok {
+		return inverted
+	}
+	for c := range inverted {
+		if 2*c+1 >= len(*arr) {
+			break
+		}
+		dmin, ok1 := numberValue((*arr)[2*c])
+		dmax, ok2 := numberValue((*arr)[2*c+1])
+		inverted[c] = ok1 && ok2 && dmin > dmax
+	}
+	return inverted
+}
+
+// sampleRect returns the rectangle of samples [x0, x1) x [y0, y1) of a `width` x `height` image covered by `area`,
+// where `inv` maps default user space to image space.
+func sampleRect(inv matrix, area Box, width, height int) (int, int, int, int) {
+	// Image space is the unit square, with the first row of samples at the top.
+	u := inv.transformBox(area[0], area[1], area[2], area[3])
+	x0 := clampInt(int(math.Floor(u[0]*float64(width))), 0, width)
+	x1 := clampInt(int(math.Ceil(u[2]*float64(width))), 0, width)
+	y0 := clampInt(int(math.Floor((1-u[3])*float64(height))), 0, height)
+	y1 := clampInt(int(math.Ceil((1-u[1])*float64(height))), 0, height)
+	return x0, y0, x1, y1
+}
+
+// verifyRedaction reads back redacted document `data` and checks that nothing is left in the redacted areas
+// `pageAreas`, by page number: no characters other than spaces, no inline images or stencil masks, and only black in
+// other images.
+func verifyRedaction(data []byte, pageAreas map[int][]Box) error {
+	pdfReader, err := pdf.NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	for pageNum, areas := range pageAreas {
+		if len(areas) == 0 {
+			continue
+		}
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return err
+		}
+		contents, err := page.GetAllContentStreams()
+		if err != nil {
+			return err
+		}
+		walker := newTextWalker(pageNum)
+		if err := walker.walk(contents, &contentStream{resources: page.Resources}, identityMatrix, 0); err != nil {
+			return err
+		}
+
+		for _, run := range walker.runs {
+			for _, c := range run.Chars {
+				if strings.TrimSpace(c.Text) != "" && inArea(c.BBox, areas) {
+					return fmt.Errorf("Page %d: redacted text %q is still in the output", pageNum, c.Text)
+				}
+			}
+		}
+		for _, img := range walker.images {
+			if err := verifyImageRedaction(img, areas); err != nil {
+				return fmt.Errorf("Page %d: %v", pageNum, err)
+			}
+		}
+	}
+	return nil
+}
+
+// verifyImageRedaction checks that image `img` is black in `areas`.
+func verifyImageRedaction(img imagePlacement, areas []Box) error {
+	bounds := img.ctm.transformBox(0, 0, 1, 1)
+	hits := []Box{}
+	for _, area := range areas {
+		if area.overlaps(bounds) {
+			hits = append(hits, area)
+		}
+	}
+	if len(hits) == 0 {
+		return nil
+	}
+	if img.op.Operand == "BI" {
+		return errors.New("An inline image in a redacted area is still in the output")
+	}
+
+	name, ok := img.op.Params[0].(*pdfcore.PdfObjectName)
+	if !ok {
+		return errors.New("Invalid XObject name")
+	}
+	ximg, err := img.stream.resources.GetXObjectImageByName(*name)
+	if err != nil {
+		return err
+	}
+	if mask, ok := pdfcore.TraceToDirectObject(ximg.ImageMask).(*pdfcore.PdfObjectBool); ok && bool(*mask) {
+		return fmt.Errorf("Stencil mask %s in a redacted area is still in the output", *name)
+	}
+	inv, ok := img.ctm.inverse()
+	if !ok {
+		return nil
+	}
+
+	image, err := ximg.ToImage()
+	if err != nil {
+		return err
+	}
+	pixel := blackSamples(ximg, image)
+	samples := image.GetSamples()
+	width, height := int(image.Width), int(image.Height)
+	comps := len(pixel)
+	for _, area := range hits {
+		x0, y0, x1, y1 := sampleRect(inv, area, width, height)
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				for c := 0; c < comps; c++ {
+					if i := (y*width+x)*comps + c; i < len(samples) && samples[i] != pixel[c] {
+						return fmt.Errorf("Image %s still has samples in a redacted area", *name)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// redactForm replaces the form drawn by `stream` with a copy with the rewritten contents, so that other uses of
+// the form are not affected.
+func redactForm(stream *contentStream, rw *streamRewriter) error {
+	name, ok := stream.doOp.Params[0].(*pdfcore.PdfObjectName)
+	if !ok {
+		return errors.New("Invalid XObject name")
+	}
+	parentResources := stream.parent.resources
+	orig, _ := parentResources.GetXObjectByName(*name)
+	if orig == nil {
+		return fmt.Errorf("Form %s not found", *name)
+	}
+
+	dict := pdfcore.MakeDict()
+	for _, key := range orig.PdfObjectDictionary.Keys() {
+		dict.Set(key, orig.PdfObjectDictionary.Get(key))
+	}
+	// Resources added for redacted images are only in the model.
+	if stream.xform.Resources != nil {
+		dict.Set("Resources", stream.xform.Resources.ToPdfObject())
+	}
+	form := &pdfcore.PdfObjectStream{PdfObjectDictionary: dict, Stream: []byte(rw.contents(stream))}
+	dict.Remove("Filter")
+	dict.Remove("DecodeParms")
+	dict.Set("Length", pdfcore.MakeInteger(int64(len(form.Stream))))
+
+	newName := newXObjectName(parentResources, *name)
+	if err := parentResources.SetXObjectByName(newName, form); err != nil {
+		return err
+	}
+	unicommon.Log.Debug("Form %s redacted as %s", *name, newName)
+	rw.set(stream.parent, stream.doOp, doOperation(newName))
+	rw.replaced.add(getDict(parentResources.XObject), *name)
+	return nil
+}
+
+// removeReplacedXObjects removes the `replaced` images and forms from their resource dictionaries unless a page of
+// `pdfReader`, or a form drawn by one, still uses them.  Otherwise the writer would still write out the originals
+// of what was redacted.
+func removeReplacedXObjects(pdfReader *pdf.PdfReader, replaced resourceNames) error {
+	if len(replaced) == 0 {
+		return nil
+	}
+
+	used := resourceNames{}
+	visited := map[pdfcore.PdfObject]bool{}
+	for i, page := range pdfReader.PageList {
+		contents, err := page.GetAllContentStreams()
+		if err != nil {
+			return err
+		}
+		if err := addUsedXObjects(contents, page.Resources, used, visited); err != nil {
+			return fmt.Errorf("Page %d: %v", i+1, err)
+		}
+	}
+
+	for dict, names := range replaced {
+		for name := range names {
+			if !used[dict][name] {
+				unicommon.Log.Debug("Removing redacted XObject %s", name)
+				dict.Remove(name)
+			}
+		}
+	}
+	return nil
+}
+
+// addUsedXObjects adds the XObjects drawn by content stream `contents` with `resources`, and by the forms among
+// them, to `used`.  `visited` holds the forms already scanned.
+func addUsedXObjects(contents string, resources *pdf.PdfPageResources, used resourceNames,
+	visited map[pdfcore.PdfObject]bool) error {
+	if resources == nil {
+		return nil
+	}
+	operations, err := pdfcontent.NewContentStreamParser(contents).Parse()
+	if err != nil {
+		return err
+	}
+
+	for _, op := range *operations {
+		if op.Operand != "Do" || len(op.Params) == 0 {
+			continue
+		}
+		used.addOperation(resources, op)
+
+		name, ok := op.Params[0].(*pdfcore.PdfObjectName)
+		if !ok {
+			continue
+		}
+		obj, xtype := resources.GetXObjectByName(*name)
+		if xtype != pdf.XObjectTypeForm || obj == nil || visited[obj] {
+			continue
+		}
+		visited[obj] = true
+
+		xform, err := resources.GetXObjectFormByName(*name)
+		if err != nil {
+			return err
+		}
+		formContents, err := xform.GetContentStream()
+		if err != nil {
+			return err
+		}
+		// Forms without resources use the resources of the page.
+		formResources := xform.Resources
+		if formResources == nil {
+			formResources = resources
+		}
+		if err := addUsedXObjects(string(formContents), formResources, used, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newXObjectName returns a name for a redacted copy of XObject `name` that is not used in `resources`.
+func newXObjectName(resources *pdf.PdfPageResources, name pdfcore.PdfObjectName) pdfcore.PdfObjectName {
+	xobjects := getDict(resources.XObject)
+	if xobjects == nil {
+		return name + "_redacted"
+	}
+	return uniqueResourceName(xobjects, name)
+}
+
+func doOperation(name pdfcore.PdfObjectName) *pdfcontent.ContentStreamOperation {
+	return &pdfcontent.ContentStreamOperation{Operand: "Do", Params: []pdfcore.PdfObject{pdfcore.MakeName(string(name))}}
+}
+
+// inverse returns the inverse of `m`.  Returns false if `m` is not invertible.
+func (m matrix) inverse() (matrix, bool) {
+	det := m[0]*m[3] - m[1]*m[2]
+	if det == 0 {
+		return identityMatrix, false
+	}
+	return matrix{
+		m[3] / det, -m[1] / det,
+		-m[2] / det, m[0] / det,
+		(m[2]*m[5] - m[3]*m[4]) / det, (m[1]*m[4] - m[0]*m[5]) / det,
+	}, true
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,205 @@
+package pdfops
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/creator"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// ResizeMode is the way ResizePdf scales page contents to the new page size.
+type ResizeMode int
+
+const (
+	ResizeFit    ResizeMode = iota // Scale to fit within the margins.
+	ResizeFill                     // Scale to cover the area within the margins, cutting off what sticks out.
+	ResizeCenter                   // Don't scale, just center.
+)
+
+var resizeModeNames = []string{"fit", "fill", "center"}
+
+// ParseResizeMode parses resize mode `s`: fit, fill or center.
+func ParseResizeMode(s string) (ResizeMode, error) {
+	for i, name := range resizeModeNames {
+		if strings.EqualFold(s, name) {
+			return ResizeMode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("Invalid resize mode %q, expected one of %s", s, strings.Join(resizeModeNames, ", "))
+}
+
+func (m ResizeMode) String() string {
+	if m < 0 || int(m) >= len(resizeModeNames) {
+		return fmt.Sprintf("ResizeMode(%d)", int(m))
+	}
+	return resizeModeNames[m]
+}
+
+// ResizeOptions are the options of ResizePdf.
+type ResizeOptions struct {
+	Paper creator.PageSize // New page size.
+
+	// KeepOrientation uses Paper as given.  By default it is turned to the orientation of each page as displayed.
+	KeepOrientation bool
+
+	Mode    ResizeMode
+	Stretch bool    // Scale the width and height independently with ResizeFit and ResizeFill to fill the area.
+	Margin  float64 // Space left around the contents.
+
+	Pages *pagerange.Selection // Pages to resize, nil for all.
+}
+
+// ResizePdf changes the size of the pages of `inputPath` to `resizeOpts.Paper`, scaling their contents, and writes
+// the result to `outputPath`.  The visible area of each page (its CropBox, or MediaBox if it has none) becomes the
+// new MediaBox.  Annotation and form field rectangles are moved with the contents, and forms, outlines and
+// optional content are kept.
+func ResizePdf(inputPath, outputPath string, resizeOpts ResizeOptions, opts Options) error {
+	if resizeOpts.Paper[0] <= 0 || resizeOpts.Paper[1] <= 0 {
+		return errors.New("Invalid paper size")
+	}
+	if 2*resizeOpts.Margin >= math.Min(resizeOpts.Paper[0], resizeOpts.Paper[1]) {
+		return errors.New("Margin leaves no room for the contents")
+	}
+
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return err
+	}
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	selected, err := resizeOpts.Pages.SelectSet(pdfReader)
+	if err != nil {
+		return err
+	}
+
+	pdfWriter := pdf.NewPdfWriter()
+
+	ocProps, err := pdfReader.GetOCProperties()
+	if err != nil {
+		return err
+	}
+	pdfWriter.SetOCProperties(ocProps)
+
+	for i, page := range pdfReader.PageList {
+		pageNum := i + 1
+
+		if selected[pageNum] {
+			if err := resizePage(page, resizeOpts); err != nil {
+				return fmt.Errorf("Page %d: %v", pageNum, err)
+			}
+		}
+
+		if err := pdfWriter.AddPage(page); err != nil {
+			return err
+		}
+	}
+
+	if err := pdfWriter.SetForms(pdfReader.AcroForm); err != nil {
+		return err
+	}
+	items := copyOutlineItems(pdfReader, outlineItems(pdfReader.GetOutlineTree()), readerPageObjects(pdfReader))
+	if len(items) > 0 {
+		pdfWriter.AddOutlineTree(&newOutlineTree(items).PdfOutlineTreeNode)
+	}
+
+	return writePdf(&pdfWriter, outputPath)
+}
+
+// resizePage resizes `page` as specified by `resizeOpts`.
+func resizePage(page *pdf.PdfPage, resizeOpts ResizeOptions) error {
+	box, rotate, err := displayedBox(page)
+	if err != nil {
+		return err
+	}
+	toUser, width, height := box.displayMatrix(rotate)
+	fromUser, ok := toUser.inverse()
+	if !ok || width == 0 || height == 0 {
+		return errors.New("Empty page")
+	}
+
+	newWidth, newHeight := resizeOpts.Paper[0], resizeOpts.Paper[1]
+	if !resizeOpts.KeepOrientation && (width > height) != (newWidth > newHeight) {
+		newWidth, newHeight = newHeight, newWidth
+	}
+
+	// The scaling is done on the page as displayed.
+	margin := resizeOpts.Margin
+	sx := (newWidth - 2*margin) / width
+	sy := (newHeight - 2*margin) / height
+	switch resizeOpts.Mode {
+	case ResizeFit:
+		if !resizeOpts.Stretch {
+			sx = math.Min(sx, sy)
+			sy = sx
+		}
+	case ResizeFill:
+		if !resizeOpts.Stretch {
+			sx = math.Max(sx, sy)
+			sy = sx
+		}
+	default:
+		sx, sy = 1, 1
+	}
+	scale := matrix{sx, 0, 0, sy, (newWidth - width*sx) / 2, (newHeight - height*sy) / 2}
+	unicommon.Log.Debug("Resizing %.0f x %.0f to %.0f x %.0f, scale %.3f x %.3f", width, height, newWidth, newHeight,
+		sx, sy)
+
+	// The new page has the same rotation, with its origin at the bottom left corner.
+	newBox := Box{0, 0, newWidth, newHeight}
+	if rotate == 90 || rotate == 270 {
+		newBox = Box{0, 0, newHeight, newWidth}
+	}
+	newToUser, _, _ := newBox.displayMatrix(rotate)
+	m := fromUser.mult(scale).mult(newToUser)
+
+	if err := transformPage(page, m, newBox); err != nil {
+		return err
+	}
+	page.CropBox = nil
+	return nil
+}
+
+// transformPage moves the contents of `page` by `m`, together with its CropBox, BleedBox, TrimBox, ArtBox and
+// annotation rectangles, and makes `mediaBox` its MediaBox.
+func transformPage(page *pdf.PdfPage, m matrix, mediaBox Box) error {
+	contents, err := page.GetAllContentStreams()
+	if err != nil {
+		return err
+	}
+	cm := fmt.Sprintf("q %.6f %.6f %.6f %.6f %.6f %.6f cm\n", m[0], m[1], m[2], m[3], m[4], m[5])
+	if err := page.SetContentStreams([]string{cm + contents + "\nQ"}, pdfcore.NewFlateEncoder()); err != nil {
+		return err
+	}
+
+	page.MediaBox = &pdf.PdfRectangle{Llx: mediaBox[0], Lly: mediaBox[1], Urx: mediaBox[2], Ury: mediaBox[3]}
+	for _, b := range []**pdf.PdfRectangle{&page.CropBox, &page.BleedBox, &page.TrimBox, &page.ArtBox} {
+		if *b != nil {
+			t := m.transformBox((*b).Llx, (*b).Lly, (*b).Urx, (*b).Ury)
+			*b = &pdf.PdfRectangle{Llx: t[0], Lly: t[1], Urx: t[2], Ury: t[3]}
+		}
+	}
+
+	for _, annot := range page.Annotations {
+		rect, ok := pdfcore.TraceToDirectObject(annot.Rect).(*pdfcore.PdfObjectArray)
+		if !ok || len(*rect) != 4 {
+			continue
+		}
+		r, err := pdf.NewPdfRectangle(*rect)
+		if err != nil {
+			continue
+		}
+		t := m.transformBox(r.Llx, r.Lly, r.Urx, r.Ury)
+		annot.Rect = pdfcore.MakeArrayFromFloats(t[:])
+	}
+
+	return nil
+}
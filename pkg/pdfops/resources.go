@@ -0,0 +1,277 @@
+package pdfops
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcontent "github.com/unidoc/unidoc/pdf/contentstream"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+)
+
+// resourceRenames maps the old names of renamed resources to their new names, by resource dictionary
+// ("Font", "XObject", ...).
+type resourceRenames map[string]map[pdfcore.PdfObjectName]pdfcore.PdfObjectName
+
+func (r resourceRenames) add(category string, oldName, newName pdfcore.PdfObjectName) {
+	if r[category] == nil {
+		r[category] = map[pdfcore.PdfObjectName]pdfcore.PdfObjectName{}
+	}
+	r[category][oldName] = newName
+}
+
+func (r resourceRenames) empty() bool {
+	for _, names := range r {
+		if len(names) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// rename replaces name parameter `idx` of `op` if it refers to a renamed resource in `category`.
+func (r resourceRenames) rename(op *pdfcontent.ContentStreamOperation, idx int, category string) bool {
+	if idx < 0 || idx >= len(op.Params) {
+		return false
+	}
+	name, ok := op.Params[idx].(*pdfcore.PdfObjectName)
+	if !ok {
+		return false
+	}
+	newName, ok := r[category][*name]
+	if !ok {
+		return false
+	}
+	op.Params[idx] = pdfcore.MakeName(string(newName))
+	return true
+}
+
+// renameContentResources returns content stream (or DA string) `contents` with the references to the resources in
+// `renames` replaced by their new names.
+func renameContentResources(contents string, renames resourceRenames) (string, error) {
+	cstreamParser := pdfcontent.NewContentStreamParser(contents)
+	operations, err := cstreamParser.Parse()
+	if err != nil {
+		return contents, err
+	}
+
+	changed := false
+	for _, op := range *operations {
+		switch op.Operand {
+		case "Tf":
+			changed = renames.rename(op, 0, "Font") || changed
+		case "Do":
+			changed = renames.rename(op, 0, "XObject") || changed
+		case "gs":
+			changed = renames.rename(op, 0, "ExtGState") || changed
+		case "sh":
+			changed = renames.rename(op, 0, "Shading") || changed
+		case "scn", "SCN":
+			changed = renames.rename(op, len(op.Params)-1, "Pattern") || changed
+		case "BDC", "DP":
+			changed = renames.rename(op, 1, "Properties") || changed
+		}
+	}
+
+	if !changed {
+		return contents, nil
+	}
+	return string(operations.Bytes()), nil
+}
+
+// renameStreamResources rewrites the content of stream `stream` with renameContentResources.  The stream is
+// stored uncompressed afterwards.
+func renameStreamResources(stream *pdfcore.PdfObjectStream, renames resourceRenames) error {
+	data, err := pdfcore.DecodeStream(stream)
+	if err != nil {
+		return err
+	}
+
+	contents, err := renameContentResources(string(data), renames)
+	if err != nil {
+		return err
+	}
+	if contents == string(data) {
+		return nil
+	}
+
+	stream.Stream = []byte(contents)
+	stream.PdfObjectDictionary.Remove("Filter")
+	stream.PdfObjectDictionary.Remove("DecodeParms")
+	stream.PdfObjectDictionary.Set("Length", pdfcore.MakeInteger(int64(len(stream.Stream))))
+	return nil
+}
+
+// mergeResourceDict adds the entries of resource dictionary `obj2` to `obj`, and returns the merged object.
+// Entries that are identical in both are kept once.  Entries of `obj2` whose name is taken by a different resource
+// in `obj` are added under a new name, which is recorded in `renames` under `category`.
+func mergeResourceDict(obj, obj2 pdfcore.PdfObject, category string, renames resourceRenames) pdfcore.PdfObject {
+	if obj == nil {
+		return obj2
+	}
+
+	dict := getDict(obj)
+	dict2 := getDict(obj2)
+	if dict == nil || dict2 == nil {
+		return obj
+	}
+
+	for _, key := range dict2.Keys() {
+		val2 := dict2.Get(key)
+		val := dict.Get(key)
+		if val == nil {
+			dict.Set(key, val2)
+			continue
+		}
+		if val == val2 || objectDigest(val) == objectDigest(val2) {
+			continue
+		}
+
+		newKey := uniqueResourceName(dict, key)
+		unicommon.Log.Debug("%s resource %s already exists, renaming to %s", category, key, newKey)
+		dict.Set(newKey, val2)
+		renames.add(category, key, newKey)
+	}
+
+	return obj
+}
+
+// uniqueResourceName returns `name` with the lowest numeric suffix that is not used in `dict`.
+func uniqueResourceName(dict *pdfcore.PdfObjectDictionary, name pdfcore.PdfObjectName) pdfcore.PdfObjectName {
+	for i := 2; ; i++ {
+		newName := pdfcore.PdfObjectName(fmt.Sprintf("%s_%d", name, i))
+		if dict.Get(newName) == nil {
+			return newName
+		}
+	}
+}
+
+// objectDigest returns a digest of the contents of `obj`, following references.  Objects with equal digests are
+// identical apart from their object numbers.
+func objectDigest(obj pdfcore.PdfObject) string {
+	h := sha256.New()
+	writeObjectDigest(h, obj, map[pdfcore.PdfObject]bool{})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeObjectDigest(h hash.Hash, obj pdfcore.PdfObject, visiting map[pdfcore.PdfObject]bool) {
+	switch t := obj.(type) {
+	case *pdfcore.PdfIndirectObject:
+		if visiting[t] {
+			io.WriteString(h, "<cycle>")
+			return
+		}
+		visiting[t] = true
+		writeObjectDigest(h, t.PdfObject, visiting)
+		delete(visiting, t)
+	case *pdfcore.PdfObjectStream:
+		if visiting[t] {
+			io.WriteString(h, "<cycle>")
+			return
+		}
+		visiting[t] = true
+		io.WriteString(h, "stream")
+		writeObjectDigest(h, t.PdfObjectDictionary, visiting)
+		fmt.Fprintf(h, "%d:", len(t.Stream))
+		h.Write(t.Stream)
+		delete(visiting, t)
+	case *pdfcore.PdfObjectDictionary:
+		keys := t.Keys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		io.WriteString(h, "<<")
+		for _, key := range keys {
+			fmt.Fprintf(h, "/%s ", key)
+			writeObjectDigest(h, t.Get(key), visiting)
+		}
+		io.WriteString(h, ">>")
+	case *pdfcore.PdfObjectArray:
+		io.WriteString(h, "[")
+		for _, elem := range *t {
+			writeObjectDigest(h, elem, visiting)
+			io.WriteString(h, " ")
+		}
+		io.WriteString(h, "]")
+	case nil:
+		io.WriteString(h, "null")
+	default:
+		io.WriteString(h, t.DefaultWriteString())
+		io.WriteString(h, " ")
+	}
+}
+
+// resourceDeduper replaces byte-identical fonts and images on the pages of merged documents by a single shared
+// object, so that they are written only once.
+type resourceDeduper struct {
+	shared   map[string]pdfcore.PdfObject // Shared object by digest.
+	digests  map[pdfcore.PdfObject]string
+	replaced int
+}
+
+func newResourceDeduper() *resourceDeduper {
+	return &resourceDeduper{
+		shared:  map[string]pdfcore.PdfObject{},
+		digests: map[pdfcore.PdfObject]string{},
+	}
+}
+
+// dedupPage replaces the fonts and images used by `page` by identical ones that were seen on earlier pages.
+func (d *resourceDeduper) dedupPage(page *pdf.PdfPage) {
+	if page.Resources == nil {
+		return
+	}
+	d.dedupDict(page.Resources.Font, nil)
+	d.dedupDict(page.Resources.XObject, isImageStream)
+}
+
+// dedupDict replaces the indirect entries of resource dictionary `obj` that `accept` returns true for (all if nil)
+// by shared objects.
+func (d *resourceDeduper) dedupDict(obj pdfcore.PdfObject, accept func(pdfcore.PdfObject) bool) {
+	dict := getDict(obj)
+	if dict == nil {
+		return
+	}
+
+	for _, key := range dict.Keys() {
+		val := dict.Get(key)
+		switch val.(type) {
+		case *pdfcore.PdfIndirectObject, *pdfcore.PdfObjectStream:
+		default:
+			// Direct objects cannot be shared.
+			continue
+		}
+		if accept != nil && !accept(val) {
+			continue
+		}
+
+		digest, ok := d.digests[val]
+		if !ok {
+			digest = objectDigest(val)
+			d.digests[val] = digest
+		}
+
+		shared, has := d.shared[digest]
+		if !has {
+			d.shared[digest] = val
+			continue
+		}
+		if shared != val {
+			dict.Set(key, shared)
+			d.replaced++
+		}
+	}
+}
+
+// isImageStream returns true if `obj` is an image XObject.
+func isImageStream(obj pdfcore.PdfObject) bool {
+	stream, ok := obj.(*pdfcore.PdfObjectStream)
+	if !ok {
+		return false
+	}
+	subtype, ok := pdfcore.TraceToDirectObject(stream.PdfObjectDictionary.Get("Subtype")).(*pdfcore.PdfObjectName)
+	return ok && *subtype == "Image"
+}
@@ -0,0 +1,206 @@
+package pdfops
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// RotationRule is a rotation of a range of pages.
+type RotationRule struct {
+	Pages *pagerange.Selection // Pages to rotate, nil for all.
+
+	Angle    int64 // Clockwise, a multiple of 90.
+	Absolute bool  // Set the rotation of the pages to Angle instead of adding Angle to it.
+
+	// Auto rotates the pages so that most of their text reads upright, ignoring Angle.  Pages without text are
+	// left as they are.
+	Auto bool
+}
+
+// RotateOptions are the options of RotatePagesPdf.
+type RotateOptions struct {
+	Rules []RotationRule // The last rule that selects a page applies to it.
+
+	// Flatten rotates the contents of the pages selected by the rules, or of all pages if there are none, instead
+	// of setting their Rotate entry, which is removed.  The pages look the same, but their user space is upright.
+	Flatten bool
+}
+
+// RotatePdf rotates the pages of `inputPath` selected by `pages` by `degrees` and writes the result to `outputPath`.
+// Degrees needs to be a multiple of 90.
+func RotatePdf(inputPath string, outputPath string, degrees int64, pages *pagerange.Selection, opts Options) error {
+	rotateOpts := RotateOptions{Rules: []RotationRule{{Pages: pages, Angle: degrees}}}
+	return RotatePagesPdf(inputPath, outputPath, rotateOpts, opts)
+}
+
+// RotateFlattenPdf flattens the rotation flags of `inputPath` and writes the result to `outputPath`.
+// For each page the page contents are rotated with page.Rotate, and then page.Rotate is removed.  The output looks
+// the same in a viewer, but the bottom left corner as displayed becomes the origin.
+func RotateFlattenPdf(inputPath string, outputPath string, opts Options) error {
+	return RotatePagesPdf(inputPath, outputPath, RotateOptions{Flatten: true}, opts)
+}
+
+// RotatePagesPdf rotates the pages of `inputPath` as specified by `rotateOpts` and writes the result to
+// `outputPath`.  Forms, outlines and optional content are kept.
+func RotatePagesPdf(inputPath string, outputPath string, rotateOpts RotateOptions, opts Options) error {
+	for _, rule := range rotateOpts.Rules {
+		if !rule.Auto && rule.Angle%90 != 0 {
+			return fmt.Errorf("Degrees needs to be a multiple of 90 (got %d)", rule.Angle)
+		}
+	}
+
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return err
+	}
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	// The rule of each page, by page number.
+	rules := map[int]RotationRule{}
+	for _, rule := range rotateOpts.Rules {
+		selected, err := rule.Pages.SelectSet(pdfReader)
+		if err != nil {
+			return err
+		}
+		for pageNum := range selected {
+			rules[pageNum] = rule
+		}
+	}
+
+	pdfWriter := pdf.NewPdfWriter()
+
+	ocProps, err := pdfReader.GetOCProperties()
+	if err != nil {
+		return err
+	}
+	pdfWriter.SetOCProperties(ocProps)
+
+	for i, page := range pdfReader.PageList {
+		pageNum := i + 1
+
+		rule, ok := rules[pageNum]
+		if ok {
+			if err := rotatePage(page, pageNum, rule); err != nil {
+				return fmt.Errorf("Page %d: %v", pageNum, err)
+			}
+		}
+		if rotateOpts.Flatten && (ok || len(rotateOpts.Rules) == 0) {
+			if err := flattenRotation(page); err != nil {
+				return fmt.Errorf("Page %d: %v", pageNum, err)
+			}
+		}
+
+		if err := pdfWriter.AddPage(page); err != nil {
+			return err
+		}
+	}
+
+	if err := pdfWriter.SetForms(pdfReader.AcroForm); err != nil {
+		return err
+	}
+	items := copyOutlineItems(pdfReader, outlineItems(pdfReader.GetOutlineTree()), readerPageObjects(pdfReader))
+	if len(items) > 0 {
+		pdfWriter.AddOutlineTree(&newOutlineTree(items).PdfOutlineTreeNode)
+	}
+
+	return writePdf(&pdfWriter, outputPath)
+}
+
+// rotatePage sets the Rotate entry of `page`, number `pageNum`, as specified by `rule`.
+func rotatePage(page *pdf.PdfPage, pageNum int, rule RotationRule) error {
+	var rotate int64
+	if page.Rotate != nil {
+		rotate = *page.Rotate
+	}
+
+	switch {
+	case rule.Auto:
+		angle, ok, err := textAngle(page, pageNum)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			unicommon.Log.Debug("Page %d: no text, rotation left unchanged", pageNum)
+			return nil
+		}
+		// Text running at `angle` counterclockwise reads upright when the page is turned clockwise by it.
+		rotate = angle
+	case rule.Absolute:
+		rotate = rule.Angle
+	default:
+		rotate += rule.Angle
+	}
+
+	rotate = (rotate%360 + 360) % 360
+	page.Rotate = &rotate
+	return nil
+}
+
+// textAngle returns the direction of most of the text of `page`, number `pageNum`, rounded to 0, 90, 180 or 270
+// degrees counterclockwise, or false if the page has no text.
+func textAngle(page *pdf.PdfPage, pageNum int) (int64, bool, error) {
+	runs, err := PageTextRuns(page, pageNum)
+	if err != nil {
+		return 0, false, err
+	}
+
+	// Count the visible characters in each direction.
+	counts := [4]int{}
+	for _, run := range runs {
+		dir := (int(math.Floor(run.angle/90+0.5))%4 + 4) % 4
+		for _, c := range run.Chars {
+			if strings.TrimSpace(c.Text) != "" {
+				counts[dir]++
+			}
+		}
+	}
+
+	best := 0
+	for dir, count := range counts {
+		if count > counts[best] {
+			best = dir
+		}
+	}
+	if counts[best] == 0 {
+		return 0, false, nil
+	}
+	unicommon.Log.Debug("Page %d: characters by text direction 0, 90, 180, 270: %v", pageNum, counts)
+	return int64(best * 90), true, nil
+}
+
+// flattenRotation rotates the contents of `page` as it is displayed and removes its Rotate entry.
+func flattenRotation(page *pdf.PdfPage) error {
+	var rotate int64
+	if page.Rotate != nil {
+		rotate = (*page.Rotate%360 + 360) % 360
+	}
+	if rotate == 0 {
+		page.Rotate = nil
+		return nil
+	}
+
+	mbox, err := page.GetMediaBox()
+	if err != nil {
+		return err
+	}
+	toUser, width, height := newBox(mbox).normalized().displayMatrix(rotate)
+	fromUser, ok := toUser.inverse()
+	if !ok {
+		return errors.New("Empty page")
+	}
+
+	if err := transformPage(page, fromUser, Box{0, 0, width, height}); err != nil {
+		return err
+	}
+	page.Rotate = nil
+	return nil
+}
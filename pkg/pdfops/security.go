@@ -0,0 +1,190 @@
+package pdfops
+
+import (
+	"errors"
+	"os"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+)
+
+// DefaultPermissions returns the access permissions applied by pdf_protect.go: everything is allowed except
+// printing in full quality.
+func DefaultPermissions() pdfcore.AccessPermissions {
+	permissions := pdfcore.AccessPermissions{}
+	// Allow printing with low quality
+	permissions.Printing = true
+	permissions.FullPrintQuality = false
+	// Allow modifications.
+	permissions.Modify = true
+	// Allow annotations.
+	permissions.Annotate = true
+	permissions.FillForms = true
+	// Allow modifying page order, rotating pages etc.
+	permissions.RotateInsert = true
+	// Allow extracting graphics.
+	permissions.ExtractGraphics = true
+	// Allow extracting graphics (accessibility)
+	permissions.DisabilityExtract = true
+
+	return permissions
+}
+
+// ProtectPdf encrypts `inputPath` with the user password `userPassword` and the owner password `ownerPassword`,
+// granting `permissions` to users that open it with the user password, and writes the result to `outputPath`.
+func ProtectPdf(inputPath string, outputPath string, userPassword, ownerPassword string,
+	permissions pdfcore.AccessPermissions, opts Options) error {
+	pdfWriter := pdf.NewPdfWriter()
+
+	encryptOptions := &pdf.EncryptOptions{}
+	encryptOptions.Permissions = permissions
+
+	err := pdfWriter.Encrypt([]byte(userPassword), []byte(ownerPassword), encryptOptions)
+	if err != nil {
+		return err
+	}
+
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return err
+	}
+
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	err = copyPages(&pdfWriter, pdfReader)
+	if err != nil {
+		return err
+	}
+
+	return writePdf(&pdfWriter, outputPath)
+}
+
+// UnlockPdf decrypts `inputPath` with the password in `opts` and writes an unencrypted copy to `outputPath`.
+func UnlockPdf(inputPath string, outputPath string, opts Options) error {
+	pdfWriter := pdf.NewPdfWriter()
+
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return err
+	}
+
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	err = copyPages(&pdfWriter, pdfReader)
+	if err != nil {
+		return err
+	}
+
+	return writePdf(&pdfWriter, outputPath)
+}
+
+// SecurityInfo describes the protection of a PDF file.
+type SecurityInfo struct {
+	Path            string `json:"path"`
+	IsEncrypted     bool   `json:"encrypted"`
+	HasOpenPassword bool   `json:"has_open_password"` // Is a password other than the empty one needed to view it?
+	Method          string `json:"method,omitempty"`  // Encryption method, empty if not encrypted.
+
+	// Permissions granted to users that open the document with the empty password or the user password in the
+	// options.  Not set if neither opens the document.
+	Permissions *Permissions `json:"permissions,omitempty"`
+}
+
+// GetSecurityInfo returns protection information about `inputPath`, which is opened with the passwords in `opts`.
+// The empty password and then the user password in `opts` are used for determining the permissions.  Documents
+// that none of the passwords open are reported with their encryption method but no permissions rather than as an
+// error.
+func GetSecurityInfo(inputPath string, opts Options) (*SecurityInfo, error) {
+	doc, err := opener.Open(inputPath, opts.Open)
+	if opener.IsWrongPassword(err) {
+		info := SecurityInfo{Path: inputPath, IsEncrypted: true, HasOpenPassword: true}
+		if info.Method, err = encryptionMethod(inputPath); err != nil {
+			return nil, err
+		}
+		return &info, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	info := SecurityInfo{Path: inputPath, IsEncrypted: doc.Auth != opener.AuthNotEncrypted}
+	if !info.IsEncrypted {
+		return &info, nil
+	}
+	info.Method = pdfReader.GetEncryptionMethod()
+
+	// The document may have been opened with the owner password, so the empty and the user password are checked
+	// without authenticating again.
+	auth, perms, err := pdfReader.CheckAccessRights([]byte(""))
+	if err != nil {
+		return nil, err
+	}
+	info.HasOpenPassword = !auth
+
+	if !auth && opts.Open.UserPassword != "" {
+		auth, perms, err = pdfReader.CheckAccessRights([]byte(opts.Open.UserPassword))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if auth {
+		info.Permissions = newPermissions(perms)
+	}
+
+	return &info, nil
+}
+
+// encryptionMethod returns the encryption method of `inputPath`, which is read without decrypting the document.
+func encryptionMethod(inputPath string) (string, error) {
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	pdfReader, err := pdf.NewPdfReader(f)
+	if err != nil {
+		// The opener may have repaired the file in order to read it.
+		unicommon.Log.Debug("%s: encryption method not available: %v", inputPath, err)
+		return "", nil
+	}
+	if _, err := pdfReader.IsEncrypted(); err != nil {
+		return "", err
+	}
+	return pdfReader.GetEncryptionMethod(), nil
+}
+
+// copyPages adds all pages of `pdfReader` to `pdfWriter`.
+func copyPages(pdfWriter *pdf.PdfWriter, pdfReader *pdf.PdfReader) error {
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return err
+	}
+	if numPages < 1 {
+		return errors.New("Document has no pages")
+	}
+
+	for i := 0; i < numPages; i++ {
+		pageNum := i + 1
+
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return err
+		}
+
+		err = pdfWriter.AddPage(page)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package pdfops
+
+import (
+	"fmt"
+
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// SplitPdf writes the pages of `inputPath` selected by `pages` to `outputPath`.
+// Optional content (OCProperties) is kept intact.
+func SplitPdf(inputPath string, outputPath string, pages *pagerange.Selection, opts Options) error {
+	pdfWriter := pdf.NewPdfWriter()
+
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return err
+	}
+
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	pageNums, err := pages.Select(pdfReader)
+	if err != nil {
+		return err
+	}
+	if len(pageNums) == 0 {
+		return fmt.Errorf("No pages selected by %s", pages)
+	}
+
+	// Keep the OC properties intact (optional content).
+	// Rarely used but can be relevant in certain cases.
+	ocProps, err := pdfReader.GetOCProperties()
+	if err != nil {
+		return err
+	}
+	pdfWriter.SetOCProperties(ocProps)
+
+	for _, pageNum := range pageNums {
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return err
+		}
+
+		err = pdfWriter.AddPage(page)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writePdf(&pdfWriter, outputPath)
+}
@@ -0,0 +1,519 @@
+package pdfops
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	pdfcontent "github.com/unidoc/unidoc/pdf/contentstream"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+	"github.com/unidoc/unidoc/pdf/model/fonts"
+	"github.com/unidoc/unidoc/pdf/model/textencoding"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// Position is the place of a stamp relative to the corners and edges of the page as displayed, i.e. taking its
+// Rotate entry into account.
+type Position int
+
+const (
+	PositionTopLeft Position = iota
+	PositionTop
+	PositionTopRight
+	PositionLeft
+	PositionCenter
+	PositionRight
+	PositionBottomLeft
+	PositionBottom
+	PositionBottomRight
+)
+
+var positionNames = []string{
+	"top-left", "top", "top-right",
+	"left", "center", "right",
+	"bottom-left", "bottom", "bottom-right",
+}
+
+// ParsePosition parses position `s`: top-left, top, top-right, left, center, right, bottom-left, bottom or
+// bottom-right.
+func ParsePosition(s string) (Position, error) {
+	for i, name := range positionNames {
+		if strings.EqualFold(s, name) {
+			return Position(i), nil
+		}
+	}
+	return 0, fmt.Errorf("Invalid position %q, expected one of %s", s, strings.Join(positionNames, ", "))
+}
+
+func (p Position) String() string {
+	if p < 0 || int(p) >= len(positionNames) {
+		return fmt.Sprintf("Position(%d)", int(p))
+	}
+	return positionNames[p]
+}
+
+// column returns -1, 0 or 1 for positions at the left edge, in the center or at the right edge of the page.
+func (p Position) column() int {
+	return int(p)%3 - 1
+}
+
+// row returns -1, 0 or 1 for positions at the bottom edge, in the middle or at the top edge of the page.
+func (p Position) row() int {
+	return 1 - int(p)/3
+}
+
+// DefaultStampFont is the font of text stamps when none is specified.
+const DefaultStampFont = "Helvetica"
+
+// TextStampOptions are the options of StampTextPdf.
+type TextStampOptions struct {
+	// Text is the text to stamp.  It can contain several lines and the placeholders {page} for the page number,
+	// {pages} for the number of pages, {filename} for the name of the input file and {date} for the current date.
+	// Numbers can be zero padded, e.g. {page:04}, and the date can be given a Go time layout, e.g.
+	// {date:02 Jan 2006}.  The default layout is 2006-01-02.
+	Text string
+
+	// Font is the path of a TrueType font file or the name of one of the standard 14 fonts, DefaultStampFont if
+	// empty.
+	Font     string
+	FontSize float64 // 12 if 0.
+	Color    RGB
+	Opacity  float64 // 0 - 1, the stamp is opaque if 0.
+
+	// Angle is the counterclockwise rotation of the text in degrees about the point where it is placed.
+	Angle float64
+
+	// Position is where the text is placed, MarginX and MarginY are its distances from the edges of the page.
+	Position         Position
+	MarginX, MarginY float64
+
+	// Tile repeats the text all over the page, Spacing apart (twice the font size if 0).  Position and margins are
+	// ignored when tiling.
+	Tile    bool
+	Spacing float64
+
+	// Behind draws the text behind the page contents instead of in front of them.  Note that it is hidden by
+	// opaque content such as scanned images.
+	Behind bool
+
+	Layer *Layer // Optional content group to put the text in, nil for none.
+
+	Pages *pagerange.Selection // Pages to stamp, nil for all.
+}
+
+// StampTextPdf adds the text of `stampOpts` to the pages of `inputPath` and writes the result to `outputPath`.
+// The text is placed in the page's CropBox, or MediaBox if it has none.
+func StampTextPdf(inputPath, outputPath string, stampOpts TextStampOptions, opts Options) error {
+	stamp, err := newTextStamp(stampOpts)
+	if err != nil {
+		return err
+	}
+
+	return stampTextPdf(inputPath, outputPath, stamp, stampOpts.Pages, nil, opts)
+}
+
+// stampTextPdf adds `stamp` to the pages of `inputPath` selected by `pages` and writes the result to `outputPath`.
+// If `setVars` is not nil it is called before each page is stamped to set placeholders in addition to those set
+// here.
+func stampTextPdf(inputPath, outputPath string, stamp *textStamp, pages *pagerange.Selection,
+	setVars func(vars *stampVars), opts Options) error {
+	vars := stampVars{filename: filepath.Base(inputPath), date: time.Now()}
+
+	draw := func(page *pdf.PdfPage, pageNum, numPages int) (string, error) {
+		vars.page, vars.pages = pageNum, numPages
+		if setVars != nil {
+			setVars(&vars)
+		}
+		text, err := expandStampTemplate(stamp.opts.Text, vars)
+		if err != nil {
+			return "", err
+		}
+		return stamp.content(page, text)
+	}
+	return stampPdf(inputPath, outputPath, pages, stamp.opts.Behind, stamp.opts.Layer, draw, opts)
+}
+
+// stampPdf adds the content returned by `draw` to the pages of `inputPath` selected by `pages`, behind the page
+// contents if `behind` is true and in optional content group `layer` if it is not nil, and writes the result to
+// `outputPath`.  The form, outlines and optional content properties of the input are kept.
+func stampPdf(inputPath, outputPath string, pages *pagerange.Selection, behind bool, layer *Layer,
+	draw func(page *pdf.PdfPage, pageNum, numPages int) (string, error), opts Options) error {
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return err
+	}
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return err
+	}
+
+	selected, err := pages.SelectSet(pdfReader)
+	if err != nil {
+		return err
+	}
+
+	pdfWriter := pdf.NewPdfWriter()
+
+	ocProps, err := pdfReader.GetOCProperties()
+	if err != nil {
+		return err
+	}
+	var ocg *pdfcore.PdfIndirectObject
+	if layer != nil {
+		ocg = newOCG(layer)
+		ocProps = addOCG(ocProps, ocg, layer)
+	}
+	pdfWriter.SetOCProperties(ocProps)
+
+	for i := 0; i < numPages; i++ {
+		pageNum := i + 1
+
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return err
+		}
+
+		if selected[pageNum] {
+			content, err := draw(page, pageNum, numPages)
+			if err != nil {
+				return err
+			}
+			if ocg != nil {
+				content = markOptionalContent(page, content, ocg)
+			}
+			if err := addPageContent(page, content, behind); err != nil {
+				return err
+			}
+		}
+
+		if err := pdfWriter.AddPage(page); err != nil {
+			return err
+		}
+	}
+
+	if err := pdfWriter.SetForms(pdfReader.AcroForm); err != nil {
+		return err
+	}
+	items := copyOutlineItems(pdfReader, outlineItems(pdfReader.GetOutlineTree()), readerPageObjects(pdfReader))
+	if len(items) > 0 {
+		pdfWriter.AddOutlineTree(&newOutlineTree(items).PdfOutlineTreeNode)
+	}
+
+	return writePdf(&pdfWriter, outputPath)
+}
+
+// stampVars are the values of the placeholders in stamp templates.
+type stampVars struct {
+	page, pages int
+	filename    string
+	date        time.Time
+	bates       string // Only set when Bates numbering.
+}
+
+var reStampPlaceholder = regexp.MustCompile(`\{(\w+)(?::([^{}]*))?\}`)
+
+// expandStampTemplate returns `template` with its placeholders replaced by `vars`.  See TextStampOptions.Text for
+// the placeholders.
+func expandStampTemplate(template string, vars stampVars) (string, error) {
+	var err error
+	text := reStampPlaceholder.ReplaceAllStringFunc(template, func(ph string) string {
+		m := reStampPlaceholder.FindStringSubmatch(ph)
+		key, arg := m[1], m[2]
+
+		var num int
+		switch key {
+		case "filename":
+			return vars.filename
+		case "bates":
+			if vars.bates != "" {
+				return vars.bates
+			}
+			err = fmt.Errorf("Placeholder %s can only be used for Bates numbering", ph)
+			return ph
+		case "date":
+			if arg == "" {
+				arg = "2006-01-02"
+			}
+			return vars.date.Format(arg)
+		case "page":
+			num = vars.page
+		case "pages":
+			num = vars.pages
+		default:
+			err = fmt.Errorf("Unknown placeholder %s in template %q", ph, template)
+			return ph
+		}
+
+		if arg == "" {
+			return strconv.Itoa(num)
+		}
+		width, err2 := strconv.Atoi(arg)
+		if err2 != nil {
+			err = fmt.Errorf("Invalid width in placeholder %s", ph)
+			return ph
+		}
+		return fmt.Sprintf("%0*d", width, num)
+	})
+	if err != nil {
+		return "", err
+	}
+	return text, nil
+}
+
+// Line spacing of multi-line stamps, as a multiple of the font size.
+const stampLeading = 1.2
+
+// textStamp draws the text of text stamps.
+type textStamp struct {
+	opts    TextStampOptions
+	font    fonts.Font
+	fontObj pdfcore.PdfObject
+	encoder textencoding.TextEncoder
+	size    float64
+}
+
+func newTextStamp(stampOpts TextStampOptions) (*textStamp, error) {
+	if stampOpts.Opacity < 0 || stampOpts.Opacity > 1 {
+		return nil, fmt.Errorf("Opacity %g is not in the range 0 - 1", stampOpts.Opacity)
+	}
+	if stampOpts.FontSize < 0 {
+		return nil, fmt.Errorf("Invalid font size %g", stampOpts.FontSize)
+	}
+
+	font, err := loadFont(stampOpts.Font)
+	if err != nil {
+		return nil, err
+	}
+	encoder := textencoding.NewWinAnsiTextEncoder()
+	font.SetEncoder(encoder)
+
+	size := stampOpts.FontSize
+	if size == 0 {
+		size = 12
+	}
+
+	return &textStamp{opts: stampOpts, font: font, fontObj: font.ToPdfObject(), encoder: encoder, size: size}, nil
+}
+
+// loadFont returns font `name`, which is the path of a TrueType font file or the name of one of the standard 14
+// fonts.  The default is DefaultStampFont.
+func loadFont(name string) (fonts.Font, error) {
+	if name == "" {
+		name = DefaultStampFont
+	}
+	if strings.EqualFold(filepath.Ext(name), ".ttf") {
+		font, err := pdf.NewPdfFontFromTTFFile(name)
+		if err != nil {
+			return nil, err
+		}
+		return font, nil
+	}
+	if font := standardFont(name); font != nil {
+		return font, nil
+	}
+	return nil, fmt.Errorf("Unknown font %q, expected a .ttf file or one of the standard 14 fonts", name)
+}
+
+// width returns the width of `text` in the stamp's font.  Characters that the font can't encode are skipped, as
+// they are by the encoder.
+func (s *textStamp) width(text string) float64 {
+	width := 0.0
+	for _, r := range text {
+		glyph, ok := s.encoder.RuneToGlyph(r)
+		if !ok {
+			continue
+		}
+		if metrics, ok := s.font.GetGlyphCharMetrics(glyph); ok {
+			width += metrics.Wx
+		}
+	}
+	return width * s.size / 1000
+}
+
+// content returns the content stream that draws `text` on `page`.  The resources it uses are added to the page.
+func (s *textStamp) content(page *pdf.PdfPage, text string) (string, error) {
+	if page.Resources == nil {
+		page.Resources = pdf.NewPdfPageResources()
+	}
+	resources := page.Resources
+
+	fontName := pdfcore.PdfObjectName("StampFont")
+	for i := 2; resources.HasFontByName(fontName); i++ {
+		fontName = pdfcore.PdfObjectName(fmt.Sprintf("StampFont%d", i))
+	}
+	if err := resources.SetFontByName(fontName, s.fontObj); err != nil {
+		return "", err
+	}
+
+	var gsName pdfcore.PdfObjectName
+	if s.opts.Opacity > 0 && s.opts.Opacity < 1 {
+		var err error
+		if gsName, err = addOpacityExtGState(resources, s.opts.Opacity); err != nil {
+			return "", err
+		}
+	}
+
+	box, rotate, err := displayedBox(page)
+	if err != nil {
+		return "", err
+	}
+	toUser, width, height := box.displayMatrix(rotate)
+
+	lines := strings.Split(text, "\n")
+	leading := s.size * stampLeading
+	// The block extends from the ascent of the first line to the descent of the last, approximated as 0.8 and 0.2
+	// times the font size.
+	blockHeight := float64(len(lines)-1)*leading + s.size
+
+	// The anchor is the point of the text block that is placed at the position: its top left corner for top-left
+	// and so on.  Tiles are placed around the center of the page.
+	column, row := s.opts.Position.column(), s.opts.Position.row()
+	tiles := [][2]float64{{0, 0}}
+	if s.opts.Tile {
+		column, row = 0, 0
+		tiles = s.tileOffsets(lines, blockHeight, width, height)
+	}
+	ax := float64(column+1) / 2 * width
+	ay := float64(row+1) / 2 * height
+	if !s.opts.Tile {
+		ax -= float64(column) * s.opts.MarginX
+		ay -= float64(row) * s.opts.MarginY
+	}
+
+	theta := s.opts.Angle * math.Pi / 180
+	rotation := matrix{math.Cos(theta), math.Sin(theta), -math.Sin(theta), math.Cos(theta), 0, 0}
+	m := rotation.mult(translationMatrix(ax, ay)).mult(toUser)
+
+	top := float64(1-row) / 2 * blockHeight
+
+	cc := pdfcontent.NewContentCreator()
+	cc.Add_q()
+	cc.Add_cm(m[0], m[1], m[2], m[3], m[4], m[5])
+	if gsName != "" {
+		cc.Add_gs(gsName)
+	}
+	cc.Add_rg(s.opts.Color[0], s.opts.Color[1], s.opts.Color[2])
+	cc.Add_BT()
+	cc.Add_Tf(fontName, s.size)
+	for _, tile := range tiles {
+		for i, line := range lines {
+			x := -float64(column+1)/2*s.width(line) + tile[0]
+			y := top - 0.8*s.size - float64(i)*leading + tile[1]
+			cc.Add_Tm(1, 0, 0, 1, x, y)
+			cc.Add_Tj(*pdfcore.MakeString(s.encoder.Encode(line)))
+		}
+	}
+	cc.Add_ET()
+	cc.Add_Q()
+
+	return cc.String(), nil
+}
+
+// tileOffsets returns the offsets from the center of the page of the copies of the text block `lines` that cover
+// a `width` x `height` page, whatever the angle of the text.
+func (s *textStamp) tileOffsets(lines []string, blockHeight, width, height float64) [][2]float64 {
+	spacing := s.opts.Spacing
+	if spacing == 0 {
+		spacing = 2 * s.size
+	}
+	blockWidth := 0.0
+	for _, line := range lines {
+		blockWidth = math.Max(blockWidth, s.width(line))
+	}
+	stepX, stepY := blockWidth+spacing, blockHeight+spacing
+
+	// The tiles are laid out in the rotated coordinates of the text, so they must cover the circle around the page.
+	radius := math.Hypot(width, height) / 2
+	nx := int(math.Ceil(radius / stepX))
+	ny := int(math.Ceil(radius / stepY))
+
+	offsets := [][2]float64{}
+	for j := -ny; j <= ny; j++ {
+		// Every other row is shifted by half a tile.
+		shift := float64(j&1) * stepX / 2
+		for i := -nx; i <= nx; i++ {
+			offsets = append(offsets, [2]float64{float64(i)*stepX + shift, float64(j) * stepY})
+		}
+	}
+	return offsets
+}
+
+// addOpacityExtGState adds a graphics state with fill and stroke opacity `opacity` to `resources` and returns its
+// name.
+func addOpacityExtGState(resources *pdf.PdfPageResources, opacity float64) (pdfcore.PdfObjectName, error) {
+	name := pdfcore.PdfObjectName("StampGS")
+	for i := 2; resources.HasExtGState(name); i++ {
+		name = pdfcore.PdfObjectName(fmt.Sprintf("StampGS%d", i))
+	}
+	gs := pdfcore.MakeDict()
+	gs.Set("Type", pdfcore.MakeName("ExtGState"))
+	gs.Set("ca", pdfcore.MakeFloat(opacity))
+	gs.Set("CA", pdfcore.MakeFloat(opacity))
+	return name, resources.AddExtGState(name, gs)
+}
+
+// displayedBox returns the visible area of `page`, its CropBox or MediaBox, and its rotation, which is normalized
+// to 0, 90, 180 or 270.
+func displayedBox(page *pdf.PdfPage) (Box, int64, error) {
+	mbox, err := page.GetMediaBox()
+	if err != nil {
+		return Box{}, 0, err
+	}
+	box := newBox(mbox)
+	if page.CropBox != nil {
+		box = newBox(page.CropBox)
+	}
+	box = box.normalized()
+
+	var rotate int64
+	if page.Rotate != nil {
+		rotate = (*page.Rotate%360 + 360) % 360
+	}
+	return box, rotate, nil
+}
+
+// displayMatrix returns the matrix that maps the coordinates of a page with visible area `b` and rotation `rotate`
+// as displayed, with the origin at the bottom left corner, to its user space coordinates, and the width and
+// height of the page as displayed.
+func (b Box) displayMatrix(rotate int64) (matrix, float64, float64) {
+	w, h := b[2]-b[0], b[3]-b[1]
+	var m matrix
+	switch rotate {
+	case 90:
+		m = matrix{0, 1, -1, 0, w, 0}
+		w, h = h, w
+	case 180:
+		m = matrix{-1, 0, 0, -1, w, h}
+	case 270:
+		m = matrix{0, -1, 1, 0, 0, h}
+		w, h = h, w
+	default:
+		m = identityMatrix
+	}
+	return m.mult(translationMatrix(b[0], b[1])), w, h
+}
+
+// addPageContent draws `content` on `page` in front of its existing content or, if `behind` is true, behind it.
+// The existing content is wrapped in q/Q so that the graphics state it leaves behind does not affect `content`.
+func addPageContent(page *pdf.PdfPage, content string, behind bool) error {
+	streams, err := page.GetContentStreams()
+	if err != nil {
+		return err
+	}
+	if behind {
+		streams = append([]string{content}, streams...)
+	} else {
+		streams = append(append([]string{"q"}, streams...), "Q", content)
+	}
+	return page.SetContentStreams(streams, pdfcore.NewFlateEncoder())
+}
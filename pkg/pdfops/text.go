@@ -0,0 +1,54 @@
+package pdfops
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/unidoc/unidoc/pdf/extractor"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// OutputPdfText writes the text contents of the pages of `inputPath` selected by `pages` to `w`.
+func OutputPdfText(inputPath string, w io.Writer, pages *pagerange.Selection, opts Options) error {
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return err
+	}
+
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	pageNums, err := pages.Select(pdfReader)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "--------------------\n")
+	fmt.Fprintf(w, "PDF to text extraction:\n")
+	fmt.Fprintf(w, "--------------------\n")
+	for _, pageNum := range pageNums {
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return err
+		}
+
+		ex, err := extractor.New(page)
+		if err != nil {
+			return err
+		}
+
+		text, err := ex.ExtractText()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(w, "------------------------------")
+		fmt.Fprintf(w, "Page %d:\n", pageNum)
+		fmt.Fprintf(w, "\"%s\"\n", text)
+		fmt.Fprintln(w, "------------------------------")
+	}
+
+	return nil
+}
@@ -0,0 +1,460 @@
+package pdfops
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf16"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model/fonts"
+	"github.com/unidoc/unidoc/pdf/model/textencoding"
+)
+
+// standardFont returns standard 14 font `baseFont`, or nil if it is not one of them.  The names of the
+// corresponding Windows fonts (Arial, TimesNewRoman and CourierNew) are accepted as well.
+func standardFont(baseFont string) fonts.Font {
+	switch baseFont {
+	case "Helvetica", "Arial":
+		return fonts.NewFontHelvetica()
+	case "Helvetica-Bold", "Arial,Bold":
+		return fonts.NewFontHelveticaBold()
+	case "Helvetica-Oblique", "Arial,Italic":
+		return fonts.NewFontHelveticaOblique()
+	case "Helvetica-BoldOblique", "Arial,BoldItalic":
+		return fonts.NewFontHelveticaBoldOblique()
+	case "Times-Roman", "TimesNewRoman":
+		return fonts.NewFontTimesRoman()
+	case "Times-Bold", "TimesNewRoman,Bold":
+		return fonts.NewFontTimesBold()
+	case "Times-Italic", "TimesNewRoman,Italic":
+		return fonts.NewFontTimesItalic()
+	case "Times-BoldItalic", "TimesNewRoman,BoldItalic":
+		return fonts.NewFontTimesBoldItalic()
+	case "Courier", "CourierNew":
+		return fonts.NewFontCourier()
+	case "Courier-Bold", "CourierNew,Bold":
+		return fonts.NewFontCourierBold()
+	case "Courier-Oblique", "CourierNew,Italic":
+		return fonts.NewFontCourierOblique()
+	case "Courier-BoldOblique", "CourierNew,BoldItalic":
+		return fonts.NewFontCourierBoldOblique()
+	case "Symbol":
+		return fonts.NewFontSymbol()
+	case "ZapfDingbats":
+		return fonts.NewFontZapfDingbats()
+	}
+	return nil
+}
+
+// textFont holds what is needed to decode and measure the strings shown with a font.
+type textFont struct {
+	name       string  // BaseFont without subset prefix.
+	twoByte    bool    // Type0 fonts use 2 byte character codes (only Identity-like CMaps are supported).
+	widthScale float64 // Glyph space to text space, 1/1000 except for Type3 fonts.
+
+	widths       map[int]float64 // Glyph widths in glyph space by character code.
+	defaultWidth float64
+
+	ascent, descent float64 // Text space, relative to the font size.
+
+	toUnicode   map[int]string
+	differences map[int]string // Glyph names from the encoding's Differences array.
+	std         fonts.Font     // Metrics for standard 14 fonts without Widths.
+}
+
+// newTextFont returns the textFont for font dictionary `obj`.
+func newTextFont(obj pdfcore.PdfObject) *textFont {
+	font := &textFont{
+		widthScale: 0.001,
+		widths:     map[int]float64{},
+		ascent:     0.8,
+		descent:    -0.2,
+	}
+
+	dict := getDict(obj)
+	if dict == nil {
+		unicommon.Log.Debug("Invalid font object, using default metrics")
+		font.defaultWidth = 500
+		return font
+	}
+
+	if baseFont, ok := pdfcore.TraceToDirectObject(dict.Get("BaseFont")).(*pdfcore.PdfObjectName); ok {
+		font.name = string(*baseFont)
+		// Subset fonts are named like ABCDEF+Helvetica.
+		if i := strings.Index(font.name, "+"); i == 6 {
+			font.name = font.name[i+1:]
+		}
+	}
+
+	subtype, _ := pdfcore.TraceToDirectObject(dict.Get("Subtype")).(*pdfcore.PdfObjectName)
+	descriptor := getDict(dict.Get("FontDescriptor"))
+
+	if subtype != nil && *subtype == "Type0" {
+		font.twoByte = true
+		font.defaultWidth = 1000
+		if descendants, ok := pdfcore.TraceToDirectObject(dict.Get("DescendantFonts")).(*pdfcore.PdfObjectArray); ok &&
+			len(*descendants) > 0 {
+			if cidFont := getDict((*descendants)[0]); cidFont != nil {
+				if dw, ok := numberValue(cidFont.Get("DW")); ok {
+					font.defaultWidth = dw
+				}
+				font.readCIDWidths(cidFont.Get("W"))
+				descriptor = getDict(cidFont.Get("FontDescriptor"))
+			}
+		}
+	} else {
+		if subtype != nil && *subtype == "Type3" {
+			if fm, ok := pdfcore.TraceToDirectObject(dict.Get("FontMatrix")).(*pdfcore.PdfObjectArray); ok && len(*fm) == 6 {
+				if a, ok := numberValue((*fm)[0]); ok {
+					font.widthScale = a
+				}
+			}
+		}
+
+		firstChar, _ := numberValue(dict.Get("FirstChar"))
+		if widths, ok := pdfcore.TraceToDirectObject(dict.Get("Widths")).(*pdfcore.PdfObjectArray); ok {
+			for i, w := range *widths {
+				if val, ok := numberValue(w); ok {
+					font.widths[int(firstChar)+i] = val
+				}
+			}
+		} else {
+			font.std = standardFont(font.name)
+		}
+		if descriptor != nil {
+			font.defaultWidth, _ = numberValue(descriptor.Get("MissingWidth"))
+		}
+		if font.std == nil && font.defaultWidth == 0 && len(font.widths) == 0 {
+			font.defaultWidth = 500
+		}
+
+		font.readDifferences(dict.Get("Encoding"))
+	}
+
+	if descriptor != nil {
+		if ascent, ok := numberValue(descriptor.Get("Ascent")); ok && ascent > 0 {
+			font.ascent = ascent / 1000
+		}
+		if descent, ok := numberValue(descriptor.Get("Descent")); ok && descent < 0 {
+			font.descent = descent / 1000
+		}
+	}
+
+	if stream, ok := pdfcore.TraceToDirectObject(dict.Get("ToUnicode")).(*pdfcore.PdfObjectStream); ok {
+		data, err := pdfcore.DecodeStream(stream)
+		if err != nil {
+			unicommon.Log.Debug("Font %s: unable to decode ToUnicode CMap: %v", font.name, err)
+		} else {
+			font.toUnicode = parseToUnicode(data)
+		}
+	}
+
+	return font
+}
+
+// readCIDWidths reads the W array of a CIDFont: entries are either "c [w1 w2 ...]" or "cfirst clast w".
+func (font *textFont) readCIDWidths(obj pdfcore.PdfObject) {
+	arr, ok := pdfcore.TraceToDirectObject(obj).(*pdfcore.PdfObjectArray)
+	if !ok {
+		return
+	}
+
+	for i := 0; i+1 < len(*arr); {
+		first, ok := numberValue((*arr)[i])
+		if !ok {
+			return
+		}
+		if widths, ok := pdfcore.TraceToDirectObject((*arr)[i+1]).(*pdfcore.PdfObjectArray); ok {
+			for j, w := range *widths {
+				if val, ok := numberValue(w); ok {
+					font.widths[int(first)+j] = val
+				}
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(*arr) {
+			return
+		}
+		last, _ := numberValue((*arr)[i+1])
+		w, _ := numberValue((*arr)[i+2])
+		for c := int(first); c <= int(last); c++ {
+			font.widths[c] = w
+		}
+		i += 3
+	}
+}
+
+// readDifferences reads the Differences array of encoding dictionary `obj`.
+func (font *textFont) readDifferences(obj pdfcore.PdfObject) {
+	encoding := getDict(obj)
+	if encoding == nil {
+		return
+	}
+	arr, ok := pdfcore.TraceToDirectObject(encoding.Get("Differences")).(*pdfcore.PdfObjectArray)
+	if !ok {
+		return
+	}
+
+	font.differences = map[int]string{}
+	code := 0
+	for _, elem := range *arr {
+		switch t := pdfcore.TraceToDirectObject(elem).(type) {
+		case *pdfcore.PdfObjectInteger:
+			code = int(*t)
+		case *pdfcore.PdfObjectName:
+			font.differences[code] = string(*t)
+			code++
+		}
+	}
+}
+
+// codes splits string `data` shown with the font into character codes.
+func (font *textFont) codes(data []byte) []int {
+	codes := []int{}
+	if font.twoByte {
+		for i := 0; i+1 < len(data); i += 2 {
+			codes = append(codes, int(data[i])<<8|int(data[i+1]))
+		}
+		return codes
+	}
+	for _, b := range data {
+		codes = append(codes, int(b))
+	}
+	return codes
+}
+
+// codeBytes returns character code `code` as it appears in strings.
+func (font *textFont) codeBytes(code int) []byte {
+	if font.twoByte {
+		return []byte{byte(code >> 8), byte(code)}
+	}
+	return []byte{byte(code)}
+}
+
+// width returns the width of character `code` in text space for a font size of 1.
+func (font *textFont) width(code int) float64 {
+	if w, ok := font.widths[code]; ok {
+		return w * font.widthScale
+	}
+	if font.std != nil {
+		if glyph, ok := font.glyph(code); ok {
+			if metrics, ok := font.std.GetGlyphCharMetrics(glyph); ok {
+				return metrics.Wx * font.widthScale
+			}
+		}
+	}
+	return font.defaultWidth * font.widthScale
+}
+
+// glyph returns the glyph name of simple font character `code`.
+func (font *textFont) glyph(code int) (string, bool) {
+	if glyph, ok := font.differences[code]; ok {
+		return glyph, true
+	}
+	if code > 0xff {
+		return "", false
+	}
+	return textencoding.NewWinAnsiTextEncoder().CharcodeToGlyph(byte(code))
+}
+
+// decode returns the text of character `code`, or "" if it cannot be decoded.
+func (font *textFont) decode(code int) string {
+	if s, ok := font.toUnicode[code]; ok {
+		return s
+	}
+	if font.twoByte {
+		return ""
+	}
+
+	encoder := textencoding.NewWinAnsiTextEncoder()
+	if glyph, ok := font.differences[code]; ok {
+		if r, ok := encoder.GlyphToRune(glyph); ok {
+			return string(r)
+		}
+		return ""
+	}
+	if r, ok := encoder.CharcodeToRune(byte(code)); ok {
+		return string(r)
+	}
+	return ""
+}
+
+// parseToUnicode returns the character code to text mapping of ToUnicode CMap `data`.  Only the bfchar and
+// bfrange sections are read, which is what ToUnicode CMaps consist of.
+func parseToUnicode(data []byte) map[int]string {
+	mapping := map[int]string{}
+	tokens := cmapTokens(data)
+
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "beginbfchar":
+			for i++; i+1 < len(tokens) && tokens[i] != "endbfchar"; i += 2 {
+				code, ok := cmapHex(tokens[i])
+				if !ok {
+					continue
+				}
+				mapping[code] = cmapText(tokens[i+1])
+			}
+		case "beginbfrange":
+			for i++; i+2 < len(tokens) && tokens[i] != "endbfrange"; {
+				lo, ok1 := cmapHex(tokens[i])
+				hi, ok2 := cmapHex(tokens[i+1])
+				if tokens[i+2] == "[" {
+					i += 3
+					for code := lo; i < len(tokens) && tokens[i] != "]"; code, i = code+1, i+1 {
+						if ok1 && ok2 && code <= hi {
+							mapping[code] = cmapText(tokens[i])
+						}
+					}
+					i++ // "]"
+					continue
+				}
+				if ok1 && ok2 && hi-lo < 0x10000 {
+					dst := utf16.Decode(cmapUTF16(tokens[i+2]))
+					for code := lo; code <= hi && len(dst) > 0; code++ {
+						mapping[code] = string(dst)
+						// The last character is incremented for each code of the range.
+						dst = append([]rune{}, dst...)
+						dst[len(dst)-1]++
+					}
+				}
+				i += 3
+			}
+		}
+	}
+
+	return mapping
+}
+
+// cmapTokens splits CMap `data` into hex strings (with their angle brackets), array brackets and other words.
+// Literal strings are not needed for bfchar and bfrange and are skipped.
+func cmapTokens(data []byte) []string {
+	tokens := []string{}
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '<' && i+1 < len(data) && data[i+1] == '<', c == '>' && i+1 < len(data) && data[i+1] == '>':
+			i += 2
+		case c == '<':
+			end := bytes.IndexByte(data[i:], '>')
+			if end < 0 {
+				return tokens
+			}
+			tokens = append(tokens, string(data[i:i+end+1]))
+			i += end + 1
+		case c == '[' || c == ']':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '%':
+			for i < len(data) && data[i] != '\n' && data[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			for depth := 0; i < len(data); i++ {
+				if data[i] == '\\' {
+					i++
+				} else if data[i] == '(' {
+					depth++
+				} else if data[i] == ')' {
+					if depth--; depth == 0 {
+						i++
+						break
+					}
+				}
+			}
+		case bytes.IndexByte([]byte(" \t\r\n\f\x00"), c) >= 0:
+			i++
+		default:
+			start := i
+			for i < len(data) && bytes.IndexByte([]byte(" \t\r\n\f\x00<>[]()%/"), data[i]) < 0 {
+				i++
+			}
+			if i == start {
+				i++ // "/" starts a name, which is read as a word.
+				continue
+			}
+			tokens = append(tokens, string(data[start:i]))
+		}
+	}
+	return tokens
+}
+
+// cmapHexBytes returns the bytes of hex string token `token`.
+func cmapHexBytes(token string) ([]byte, bool) {
+	if len(token) < 2 || token[0] != '<' || token[len(token)-1] != '>' {
+		return nil, false
+	}
+	digits := []byte{}
+	for _, c := range []byte(token[1 : len(token)-1]) {
+		if bytes.IndexByte([]byte(" \t\r\n"), c) < 0 {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	b := make([]byte, len(digits)/2)
+	for i := range b {
+		hi, ok1 := hexDigit(digits[2*i])
+		lo, ok2 := hexDigit(digits[2*i+1])
+		if !ok1 || !ok2 {
+			return nil, false
+		}
+		b[i] = hi<<4 | lo
+	}
+	return b, true
+}
+
+func hexDigit(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// cmapHex returns the value of hex string token `token` as a character code.
+func cmapHex(token string) (int, bool) {
+	b, ok := cmapHexBytes(token)
+	if !ok || len(b) == 0 || len(b) > 4 {
+		return 0, false
+	}
+	code := 0
+	for _, c := range b {
+		code = code<<8 | int(c)
+	}
+	return code, true
+}
+
+// cmapUTF16 returns hex string token `token` as UTF-16BE code units.
+func cmapUTF16(token string) []uint16 {
+	b, _ := cmapHexBytes(token)
+	units := []uint16{}
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return units
+}
+
+// cmapText returns the text of UTF-16BE hex string token `token`.
+func cmapText(token string) string {
+	return string(utf16.Decode(cmapUTF16(token)))
+}
+
+// numberValue returns the value of integer or real number `obj`.
+func numberValue(obj pdfcore.PdfObject) (float64, bool) {
+	switch t := pdfcore.TraceToDirectObject(obj).(type) {
+	case *pdfcore.PdfObjectInteger:
+		return float64(*t), true
+	case *pdfcore.PdfObjectFloat:
+		return float64(*t), true
+	}
+	return 0, false
+}
@@ -0,0 +1,551 @@
+package pdfops
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcontent "github.com/unidoc/unidoc/pdf/contentstream"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/opener"
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// maxFormDepth limits the nesting of form XObjects, which guards against forms that draw themselves.
+const maxFormDepth = 20
+
+// TextRun is the text shown by a single text showing operator (Tj, TJ, ' or ").  Coordinates are in the default
+// user space of the page, i.e. after applying the text and current transformation matrices but not the page's
+// Rotate entry.
+type TextRun struct {
+	Page     int     `json:"page"`
+	Text     string  `json:"text"`
+	BBox     Box     `json:"bbox"`
+	Font     string  `json:"font"`
+	FontSize float64 `json:"font_size"` // Size of the text as it appears on the page.
+	Color    string  `json:"color"`     // Fill color as #rrggbb, empty if it cannot be converted to RGB.
+
+	Chars []TextChar `json:"-"`
+
+	angle float64 // Direction of the baseline in default user space, in degrees counterclockwise from the x axis.
+
+	// Where the run comes from, for rewriting it.
+	stream *contentStream
+	op     *pdfcontent.ContentStreamOperation
+	pieces []textPiece
+}
+
+// textPiece is an element of the string or TJ array shown by a text run.
+type textPiece struct {
+	code   []byte  // Character code, nil for TJ position adjustments.
+	adjust float64 // Advance of the character or the TJ adjustment, in thousandths of text space units.
+	char   int     // Index of the corresponding character in Chars, -1 if none.
+}
+
+// TextChar is a character of a TextRun.  Large TJ offsets between words, which stand in for spaces, are
+// included as space characters that cover the gap.
+type TextChar struct {
+	Text string // Empty if the character could not be decoded, more than one rune for ligatures.
+	BBox Box
+}
+
+// ExtractTextRuns returns the text runs of the pages of `inputPath` selected by `pages`, in content stream order.
+func ExtractTextRuns(inputPath string, pages *pagerange.Selection, opts Options) ([]*TextRun, error) {
+	doc, err := opener.Open(inputPath, opts.Open)
+	if err != nil {
+		return nil, err
+	}
+
+	defer doc.Close()
+	pdfReader := doc.Reader
+
+	pageNums, err := pages.Select(pdfReader)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []*TextRun{}
+	for _, pageNum := range pageNums {
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return nil, err
+		}
+
+		pageRuns, err := PageTextRuns(page, pageNum)
+		if err != nil {
+			return nil, fmt.Errorf("Page %d: %v", pageNum, err)
+		}
+		runs = append(runs, pageRuns...)
+	}
+
+	return runs, nil
+}
+
+// PageTextRuns returns the text runs of `page`, which is page number `pageNum`, including the text in form
+// XObjects.
+func PageTextRuns(page *pdf.PdfPage, pageNum int) ([]*TextRun, error) {
+	contents, err := page.GetAllContentStreams()
+	if err != nil {
+		return nil, err
+	}
+
+	walker := newTextWalker(pageNum)
+	if err := walker.walk(contents, &contentStream{resources: page.Resources}, identityMatrix, 0); err != nil {
+		return nil, err
+	}
+	return walker.runs, nil
+}
+
+// contentStream is a content stream visited by textWalker: the contents of a page or of a form XObject.
+type contentStream struct {
+	operations *pdfcontent.ContentStreamOperations
+	resources  *pdf.PdfPageResources
+
+	// For forms: the content stream, Do operator and form that draw it.
+	parent *contentStream
+	doOp   *pdfcontent.ContentStreamOperation
+	xform  *pdf.XObjectForm
+}
+
+// imagePlacement is an image drawn by a content stream.
+type imagePlacement struct {
+	stream *contentStream
+	op     *pdfcontent.ContentStreamOperation // Do or BI.
+	ctm    matrix                             // Maps the unit square to the image's position on the page.
+}
+
+// matrix is an affine transformation [a b c d e f], mapping (x, y) to (a*x + c*y + e, b*x + d*y + f).
+type matrix [6]float64
+
+var identityMatrix = matrix{1, 0, 0, 1, 0, 0}
+
+func translationMatrix(tx, ty float64) matrix {
+	return matrix{1, 0, 0, 1, tx, ty}
+}
+
+// mult returns m × n, the transformation that applies `m` and then `n`.
+func (m matrix) mult(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func (m matrix) transform(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// transformBox returns the bounding box of rectangle [llx lly urx ury] transformed by `m`.
+func (m matrix) transformBox(llx, lly, urx, ury float64) Box {
+	box := Box{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range [][2]float64{{llx, lly}, {urx, lly}, {urx, ury}, {llx, ury}} {
+		x, y := m.transform(p[0], p[1])
+		box[0], box[1] = math.Min(box[0], x), math.Min(box[1], y)
+		box[2], box[3] = math.Max(box[2], x), math.Max(box[3], y)
+	}
+	return box
+}
+
+// matrixFromObject returns the matrix of array `obj` of 6 numbers, or the identity matrix.
+func matrixFromObject(obj pdfcore.PdfObject) matrix {
+	arr, ok := pdfcore.TraceToDirectObject(obj).(*pdfcore.PdfObjectArray)
+	if !ok || len(*arr) != 6 {
+		return identityMatrix
+	}
+	m := matrix{}
+	for i, elem := range *arr {
+		val, ok := numberValue(elem)
+		if !ok {
+			return identityMatrix
+		}
+		m[i] = val
+	}
+	return m
+}
+
+// union returns the bounding box of `b` and `b2`.
+func (b Box) union(b2 Box) Box {
+	return Box{math.Min(b[0], b2[0]), math.Min(b[1], b2[1]), math.Max(b[2], b2[2]), math.Max(b[3], b2[3])}
+}
+
+// textState holds the graphics state parameters that position text, which the content stream processor does not
+// track.
+type textState struct {
+	ctm         matrix
+	charSpacing float64 // Tc
+	wordSpacing float64 // Tw
+	scaling     float64 // Tz / 100
+	leading     float64 // TL
+	rise        float64 // Ts
+	font        *textFont
+	fontName    string
+	fontSize    float64
+}
+
+// textWalker collects the text runs, images and paths of a page.
+type textWalker struct {
+	pageNum int
+	fonts   map[pdfcore.PdfObject]*textFont // By font object, shared by the page and its forms.
+	runs    []*TextRun
+	images  []imagePlacement
+	paths   []Box            // Bounding boxes of the painted paths, except white fills.
+	streams []*contentStream // The page contents and the forms drawn by it, parents before their forms.
+}
+
+func newTextWalker(pageNum int) *textWalker {
+	return &textWalker{pageNum: pageNum, fonts: map[pdfcore.PdfObject]*textFont{}}
+}
+
+// walk collects the text runs, images and paths of content stream `contents`, drawn with transformation `ctm`.
+// `stream` describes the content stream, its operations are set here.
+func (w *textWalker) walk(contents string, stream *contentStream, ctm matrix, depth int) error {
+	return w.walkFrom(contents, stream, textState{ctm: ctm, scaling: 1}, depth)
+}
+
+// walkFrom is walk for content stream `contents` starting in state `state`.  Forms start in the state of the
+// stream that draws them, so that they inherit its font and text parameters.
+func (w *textWalker) walkFrom(contents string, stream *contentStream, state textState, depth int) error {
+	cstreamParser := pdfcontent.NewContentStreamParser(contents)
+	operations, err := cstreamParser.Parse()
+	if err != nil {
+		return err
+	}
+	stream.operations = operations
+	w.streams = append(w.streams, stream)
+
+	stack := []textState{}
+	tm, tlm := identityMatrix, identityMatrix
+
+	// nextLine moves to the start of the next line, offset by (tx, ty).
+	nextLine := func(tx, ty float64) {
+		tlm = translationMatrix(tx, ty).mult(tlm)
+		tm = tlm
+	}
+
+	// The bounding box of the current path, including the control points of curves.
+	emptyPath := Box{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	path := emptyPath
+	addPoints := func(nums []float64) {
+		for i := 0; i+1 < len(nums); i += 2 {
+			x, y := state.ctm.transform(nums[i], nums[i+1])
+			path = path.union(Box{x, y, x, y})
+		}
+	}
+
+	processor := pdfcontent.NewContentStreamProcessor(*operations)
+	processor.AddHandler(pdfcontent.HandlerConditionEnumAllOperands, "",
+		func(op *pdfcontent.ContentStreamOperation, gs pdfcontent.GraphicsState,
+			resources *pdf.PdfPageResources) error {
+			nums, _ := operandNumbers(op)
+
+			switch op.Operand {
+			case "q":
+				stack = append(stack, state)
+			case "Q":
+				if len(stack) > 0 {
+					state = stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+				}
+			case "cm":
+				if len(nums) == 6 {
+					state.ctm = matrix{nums[0], nums[1], nums[2], nums[3], nums[4], nums[5]}.mult(state.ctm)
+				}
+			case "BT":
+				tm, tlm = identityMatrix, identityMatrix
+			case "Tc":
+				if len(nums) == 1 {
+					state.charSpacing = nums[0]
+				}
+			case "Tw":
+				if len(nums) == 1 {
+					state.wordSpacing = nums[0]
+				}
+			case "Tz":
+				if len(nums) == 1 {
+					state.scaling = nums[0] / 100
+				}
+			case "TL":
+				if len(nums) == 1 {
+					state.leading = nums[0]
+				}
+			case "Ts":
+				if len(nums) == 1 {
+					state.rise = nums[0]
+				}
+			case "Tf":
+				if len(op.Params) != 2 {
+					return nil
+				}
+				name, ok := op.Params[0].(*pdfcore.PdfObjectName)
+				size, ok2 := numberValue(op.Params[1])
+				if !ok || !ok2 {
+					return errors.New("Invalid Tf parameters")
+				}
+				state.font = w.font(resources, *name)
+				state.fontName = state.font.name
+				state.fontSize = size
+			case "Td":
+				if len(nums) == 2 {
+					nextLine(nums[0], nums[1])
+				}
+			case "TD":
+				if len(nums) == 2 {
+					state.leading = -nums[1]
+					nextLine(nums[0], nums[1])
+				}
+			case "Tm":
+				if len(nums) == 6 {
+					tlm = matrix{nums[0], nums[1], nums[2], nums[3], nums[4], nums[5]}
+					tm = tlm
+				}
+			case "T*":
+				nextLine(0, -state.leading)
+			case "Tj", "'", "\"":
+				if op.Operand == "\"" && len(op.Params) == 3 {
+					state.wordSpacing, _ = numberValue(op.Params[0])
+					state.charSpacing, _ = numberValue(op.Params[1])
+				}
+				if op.Operand != "Tj" {
+					nextLine(0, -state.leading)
+				}
+				if len(op.Params) == 0 {
+					return nil
+				}
+				s, ok := op.Params[len(op.Params)-1].(*pdfcore.PdfObjectString)
+				if !ok {
+					return fmt.Errorf("Invalid %s parameter", op.Operand)
+				}
+				run := w.newRun(stream, op, &state, gs)
+				w.showString(run, []byte(*s), &state, &tm)
+				w.addRun(run)
+			case "TJ":
+				if len(op.Params) != 1 {
+					return nil
+				}
+				arr, ok := pdfcore.TraceToDirectObject(op.Params[0]).(*pdfcore.PdfObjectArray)
+				if !ok {
+					return errors.New("Invalid TJ parameter")
+				}
+				run := w.newRun(stream, op, &state, gs)
+				for _, elem := range *arr {
+					if s, ok := elem.(*pdfcore.PdfObjectString); ok {
+						w.showString(run, []byte(*s), &state, &tm)
+						continue
+					}
+					adjust, ok := numberValue(elem)
+					if !ok {
+						continue
+					}
+					w.adjust(run, adjust, &state, &tm)
+				}
+				w.addRun(run)
+			case "m", "l", "c", "v", "y":
+				addPoints(nums)
+			case "re":
+				if len(nums) == 4 {
+					path = path.union(state.ctm.transformBox(nums[0], nums[1], nums[0]+nums[2], nums[1]+nums[3]))
+				}
+			case "S", "s", "f", "F", "f*", "B", "B*", "b", "b*":
+				isFill := op.Operand[0] == 'f' || op.Operand[0] == 'F'
+				white := colorHex(gs.ColorspaceNonStroking, gs.ColorNonStroking) == "#ffffff"
+				if path[0] <= path[2] && !(isFill && white) {
+					w.paths = append(w.paths, path)
+				}
+				path = emptyPath
+			case "n":
+				path = emptyPath
+			case "BI":
+				w.images = append(w.images, imagePlacement{stream: stream, op: op, ctm: state.ctm})
+			case "Do":
+				if len(op.Params) < 1 {
+					return nil
+				}
+				name, ok := op.Params[0].(*pdfcore.PdfObjectName)
+				if !ok {
+					return errors.New("Invalid XObject name")
+				}
+				_, xtype := resources.GetXObjectByName(*name)
+				if xtype == pdf.XObjectTypeImage {
+					w.images = append(w.images, imagePlacement{stream: stream, op: op, ctm: state.ctm})
+				}
+				if xtype != pdf.XObjectTypeForm || depth >= maxFormDepth {
+					return nil
+				}
+				xform, err := resources.GetXObjectFormByName(*name)
+				if err != nil {
+					return err
+				}
+				formContents, err := xform.GetContentStream()
+				if err != nil {
+					return err
+				}
+				// Forms without resources use the resources of the page.
+				formResources := xform.Resources
+				if formResources == nil {
+					formResources = resources
+				}
+				formState := state
+				formState.ctm = matrixFromObject(xform.Matrix).mult(state.ctm)
+				form := &contentStream{resources: formResources, parent: stream, doOp: op, xform: xform}
+				return w.walkFrom(string(formContents), form, formState, depth+1)
+			}
+			return nil
+		})
+
+	return processor.Process(stream.resources)
+}
+
+// font returns the textFont of font `name` in `resources`.
+func (w *textWalker) font(resources *pdf.PdfPageResources, name pdfcore.PdfObjectName) *textFont {
+	var obj pdfcore.PdfObject
+	if resources != nil {
+		obj, _ = resources.GetFontByName(name)
+	}
+	if obj == nil {
+		unicommon.Log.Debug("Font %s not found, using default metrics", name)
+	}
+
+	if font, ok := w.fonts[obj]; ok && obj != nil {
+		return font
+	}
+	font := newTextFont(obj)
+	if font.name == "" {
+		font.name = string(name)
+	}
+	if obj != nil {
+		w.fonts[obj] = font
+	}
+	return font
+}
+
+// newRun returns an empty run of text showing operator `op` in `stream`, with the font and fill color of the
+// current state.
+func (w *textWalker) newRun(stream *contentStream, op *pdfcontent.ContentStreamOperation, state *textState,
+	gs pdfcontent.GraphicsState) *TextRun {
+	return &TextRun{
+		Page:   w.pageNum,
+		Font:   state.fontName,
+		Color:  colorHex(gs.ColorspaceNonStroking, gs.ColorNonStroking),
+		stream: stream,
+		op:     op,
+	}
+}
+
+// addRun adds `run` unless it is empty.
+func (w *textWalker) addRun(run *TextRun) {
+	if len(run.Chars) == 0 {
+		return
+	}
+
+	text := strings.Builder{}
+	run.BBox = run.Chars[0].BBox
+	for _, c := range run.Chars {
+		text.WriteString(c.Text)
+		run.BBox = run.BBox.union(c.BBox)
+	}
+	run.Text = text.String()
+	w.runs = append(w.runs, run)
+}
+
+// renderingMatrix returns the text rendering matrix for text matrix `tm`, mapping glyph space scaled to a font
+// size of 1 to default user space.
+func renderingMatrix(state *textState, tm matrix) matrix {
+	return matrix{state.fontSize * state.scaling, 0, 0, state.fontSize, 0, state.rise}.mult(tm).mult(state.ctm)
+}
+
+// showString adds the characters of string `data` to `run` and advances text matrix `tm` past them.
+func (w *textWalker) showString(run *TextRun, data []byte, state *textState, tm *matrix) {
+	font := state.font
+	if font == nil {
+		unicommon.Log.Debug("Text shown without a font, ignoring it")
+		return
+	}
+
+	for _, code := range font.codes(data) {
+		width := font.width(code)
+
+		trm := renderingMatrix(state, *tm)
+		if run.FontSize == 0 {
+			run.FontSize = math.Hypot(trm[2], trm[3])
+			run.angle = math.Atan2(trm[1], trm[0]) * 180 / math.Pi
+		}
+		run.Chars = append(run.Chars, TextChar{
+			Text: font.decode(code),
+			BBox: trm.transformBox(0, font.descent, width, font.ascent),
+		})
+
+		tx := width*state.fontSize + state.charSpacing
+		if !font.twoByte && code == ' ' {
+			tx += state.wordSpacing
+		}
+		*tm = translationMatrix(tx*state.scaling, 0).mult(*tm)
+
+		piece := textPiece{code: font.codeBytes(code), char: len(run.Chars) - 1}
+		if state.fontSize != 0 {
+			piece.adjust = tx / state.fontSize * 1000
+		}
+		run.pieces = append(run.pieces, piece)
+	}
+}
+
+// adjust applies TJ position adjustment `adjust` (in thousandths of text space units).  Adjustments of more than
+// wordGap of the font size are taken as word breaks.
+func (w *textWalker) adjust(run *TextRun, adjust float64, state *textState, tm *matrix) {
+	const wordGap = 0.2
+
+	piece := textPiece{adjust: adjust, char: -1}
+	if -adjust/1000 > wordGap && len(run.Chars) > 0 && run.Chars[len(run.Chars)-1].Text != " " &&
+		state.font != nil {
+		trm := renderingMatrix(state, *tm)
+		run.Chars = append(run.Chars, TextChar{
+			Text: " ",
+			BBox: trm.transformBox(0, state.font.descent, -adjust/1000, state.font.ascent),
+		})
+		piece.char = len(run.Chars) - 1
+	}
+	run.pieces = append(run.pieces, piece)
+
+	tx := -adjust / 1000 * state.fontSize * state.scaling
+	*tm = translationMatrix(tx, 0).mult(*tm)
+}
+
+// operandNumbers returns the parameters of `op` as numbers.  Returns false if any of them is not a number.
+func operandNumbers(op *pdfcontent.ContentStreamOperation) ([]float64, bool) {
+	nums := []float64{}
+	for _, param := range op.Params {
+		val, ok := numberValue(param)
+		if !ok {
+			return nil, false
+		}
+		nums = append(nums, val)
+	}
+	return nums, true
+}
+
+// colorHex returns `color` in colorspace `cs` as #rrggbb, or "" if it cannot be converted to RGB.
+func colorHex(cs pdf.PdfColorspace, color pdf.PdfColor) string {
+	if cs == nil || color == nil {
+		return ""
+	}
+	rgbColor, err := cs.ColorToRGB(color)
+	if err != nil {
+		return ""
+	}
+	rgb, ok := rgbColor.(*pdf.PdfColorDeviceRGB)
+	if !ok {
+		return ""
+	}
+
+	component := func(v float64) int {
+		return int(math.Round(math.Max(0, math.Min(1, v)) * 255))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", component(rgb.R()), component(rgb.G()), component(rgb.B()))
+}
@@ -0,0 +1,207 @@
+package pdfops
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+
+	unicommon "github.com/unidoc/unidoc/common"
+	pdfcontent "github.com/unidoc/unidoc/pdf/contentstream"
+	pdfcore "github.com/unidoc/unidoc/pdf/core"
+	pdf "github.com/unidoc/unidoc/pdf/model"
+
+	"github.com/unidoc/unidoc-examples/pkg/pagerange"
+)
+
+// ImageLayout is the way a watermark image is placed on the page.
+type ImageLayout int
+
+const (
+	ImageLayoutWidth ImageLayout = iota // Scaled to the width of the page and centered vertically.
+	ImageLayoutFit                      // As large as fits within the margins, centered.
+	ImageLayoutPlace                    // At Position, scaled by Scale.
+	ImageLayoutTile                     // Repeated over the whole page, scaled by Scale.
+)
+
+var imageLayoutNames = []string{"width", "fit", "place", "tile"}
+
+// ParseImageLayout parses image layout `s`: width, fit, place or tile.
+func ParseImageLayout(s string) (ImageLayout, error) {
+	for i, name := range imageLayoutNames {
+		if strings.EqualFold(s, name) {
+			return ImageLayout(i), nil
+		}
+	}
+	return 0, fmt.Errorf("Invalid image layout %q, expected one of %s", s, strings.Join(imageLayoutNames, ", "))
+}
+
+func (l ImageLayout) String() string {
+	if l < 0 || int(l) >= len(imageLayoutNames) {
+		return fmt.Sprintf("ImageLayout(%d)", int(l))
+	}
+	return imageLayoutNames[l]
+}
+
+// ImageWatermarkOptions are the options of WatermarkImagePdf.
+type ImageWatermarkOptions struct {
+	Layout ImageLayout
+
+	// Position is where the image is placed with ImageLayoutPlace, Margin is its distance from the edges of the
+	// page then and with ImageLayoutFit.
+	Position Position
+	Margin   float64
+
+	// Scale is the size of the image with ImageLayoutPlace and ImageLayoutTile, relative to one point per pixel.
+	// 1 if 0.
+	Scale float64
+
+	Opacity float64 // 0 - 1, the image is opaque if 0.
+	Behind  bool    // Draw the image behind the page contents instead of in front of them.
+	Layer   *Layer  // Optional content group to put the image in, nil for none.
+
+	Pages *pagerange.Selection // Pages to watermark, nil for all.
+}
+
+// WatermarkImagePdf adds the image `watermarkPath` as a watermark to the pages of `inputPath` and writes the result
+// to `outputPath`.
+func WatermarkImagePdf(inputPath string, outputPath string, watermarkPath string, wmOpts ImageWatermarkOptions,
+	opts Options) error {
+	unicommon.Log.Debug("Input PDF: %v", inputPath)
+	unicommon.Log.Debug("Watermark image: %s", watermarkPath)
+
+	if wmOpts.Opacity < 0 || wmOpts.Opacity > 1 {
+		return fmt.Errorf("Opacity %g is not in the range 0 - 1", wmOpts.Opacity)
+	}
+	if wmOpts.Scale < 0 {
+		return fmt.Errorf("Invalid scale %g", wmOpts.Scale)
+	}
+
+	ximg, err := loadXObjectImage(watermarkPath)
+	if err != nil {
+		return err
+	}
+
+	draw := func(page *pdf.PdfPage, pageNum, numPages int) (string, error) {
+		return watermarkImageContent(page, ximg, wmOpts)
+	}
+	return stampPdf(inputPath, outputPath, wmOpts.Pages, wmOpts.Behind, wmOpts.Layer, draw, opts)
+}
+
+// loadXObjectImage returns an image XObject with the image in file `path`.  JPEG images are embedded as they are,
+// with a DCTDecode filter, so that they do not lose quality; others are Flate encoded.
+func loadXObjectImage(path string) (*pdf.XObjectImage, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := pdf.ImageHandling.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// JPEG files start with an SOI marker.
+	if !bytes.HasPrefix(data, []byte{0xff, 0xd8}) {
+		return pdf.NewXObjectImageFromImage(img, nil, pdfcore.NewFlateEncoder())
+	}
+
+	ximg, err := pdf.NewXObjectImageFromImage(img, nil, pdfcore.NewRawEncoder())
+	if err != nil {
+		return nil, err
+	}
+	dct := pdfcore.NewDCTEncoder()
+	dct.ColorComponents = img.ColorComponents
+	dct.BitsPerComponent = int(img.BitsPerComponent)
+	dct.Width = int(img.Width)
+	dct.Height = int(img.Height)
+	ximg.Filter = dct
+	ximg.Stream = data
+	return ximg, nil
+}
+
+// watermarkImageContent returns the content stream that draws `ximg` on `page` as specified by `wmOpts`.  The
+// resources it uses are added to the page.
+func watermarkImageContent(page *pdf.PdfPage, ximg *pdf.XObjectImage, wmOpts ImageWatermarkOptions) (string,
+	error) {
+	if page.Resources == nil {
+		page.Resources = pdf.NewPdfPageResources()
+	}
+	resources := page.Resources
+
+	imgName := pdfcore.PdfObjectName("Watermark")
+	for i := 2; resources.HasXObjectByName(imgName); i++ {
+		imgName = pdfcore.PdfObjectName(fmt.Sprintf("Watermark%d", i))
+	}
+	if err := resources.SetXObjectImageByName(imgName, ximg); err != nil {
+		return "", err
+	}
+
+	var gsName pdfcore.PdfObjectName
+	if wmOpts.Opacity > 0 && wmOpts.Opacity < 1 {
+		var err error
+		if gsName, err = addOpacityExtGState(resources, wmOpts.Opacity); err != nil {
+			return "", err
+		}
+	}
+
+	box, rotate, err := displayedBox(page)
+	if err != nil {
+		return "", err
+	}
+	toUser, width, height := box.displayMatrix(rotate)
+
+	cc := pdfcontent.NewContentCreator()
+	cc.Add_q()
+	cc.Add_cm(toUser[0], toUser[1], toUser[2], toUser[3], toUser[4], toUser[5])
+	if gsName != "" {
+		cc.Add_gs(gsName)
+	}
+	for _, r := range imagePlacements(float64(*ximg.Width), float64(*ximg.Height), width, height, wmOpts) {
+		cc.Add_q()
+		cc.Add_cm(r[2]-r[0], 0, 0, r[3]-r[1], r[0], r[1])
+		cc.Add_Do(imgName)
+		cc.Add_Q()
+	}
+	cc.Add_Q()
+
+	return cc.String(), nil
+}
+
+// imagePlacements returns the rectangles in which a `imgWidth` x `imgHeight` pixel image is drawn on a `width` x
+// `height` page, as displayed, according to `wmOpts`.
+func imagePlacements(imgWidth, imgHeight, width, height float64, wmOpts ImageWatermarkOptions) []Box {
+	scale := wmOpts.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	w, h := imgWidth*scale, imgHeight*scale
+	margin := wmOpts.Margin
+
+	switch wmOpts.Layout {
+	case ImageLayoutFit:
+		f := math.Min((width-2*margin)/imgWidth, (height-2*margin)/imgHeight)
+		w, h = imgWidth*f, imgHeight*f
+		x, y := (width-w)/2, (height-h)/2
+		return []Box{{x, y, x + w, y + h}}
+	case ImageLayoutPlace:
+		column, row := wmOpts.Position.column(), wmOpts.Position.row()
+		x := float64(column+1)/2*(width-w) - float64(column)*margin
+		y := float64(row+1)/2*(height-h) - float64(row)*margin
+		return []Box{{x, y, x + w, y + h}}
+	case ImageLayoutTile:
+		// Tiles start at the top left corner, like the page is read.
+		boxes := []Box{}
+		for y := height - h; y+h > 0; y -= h {
+			for x := 0.0; x < width; x += w {
+				boxes = append(boxes, Box{x, y, x + w, y + h})
+			}
+		}
+		return boxes
+	default:
+		h = width * imgHeight / imgWidth
+		y := (height - h) / 2
+		return []Box{{0, y, width, y + h}}
+	}
+}
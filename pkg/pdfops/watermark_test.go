@@ -0,0 +1,43 @@
+package pdfops
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImagePlacements(t *testing.T) {
+	// A 200 x 100 pixel image on a 600 x 800 page.
+	tests := []struct {
+		wmOpts ImageWatermarkOptions
+		want   []Box
+	}{
+		{ImageWatermarkOptions{}, []Box{{0, 250, 600, 550}}},
+		{ImageWatermarkOptions{Layout: ImageLayoutFit, Margin: 50}, []Box{{50, 275, 550, 525}}},
+		{ImageWatermarkOptions{Layout: ImageLayoutPlace, Position: PositionTopLeft, Margin: 20, Scale: 0.5},
+			[]Box{{20, 730, 120, 780}}},
+		{ImageWatermarkOptions{Layout: ImageLayoutPlace, Position: PositionBottomRight, Margin: 10},
+			[]Box{{390, 10, 590, 110}}},
+		{ImageWatermarkOptions{Layout: ImageLayoutPlace, Position: PositionCenter, Margin: 10},
+			[]Box{{200, 350, 400, 450}}},
+	}
+
+	for _, test := range tests {
+		got := imagePlacements(200, 100, 600, 800, test.wmOpts)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v: got %v, want %v", test.wmOpts, got, test.want)
+		}
+	}
+}
+
+func TestImagePlacementsTile(t *testing.T) {
+	// Tiles start at the top left corner and cover the page, overlapping its right and bottom edges.
+	got := imagePlacements(200, 100, 300, 250, ImageWatermarkOptions{Layout: ImageLayoutTile})
+	want := []Box{
+		{0, 150, 200, 250}, {200, 150, 400, 250},
+		{0, 50, 200, 150}, {200, 50, 400, 150},
+		{0, -50, 200, 50}, {200, -50, 400, 50},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
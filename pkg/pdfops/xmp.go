@@ -0,0 +1,332 @@
+package pdfops
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	unicommon "github.com/unidoc/unidoc/common"
+)
+
+// xmpDate formats PDF date string `s` as an XMP (ISO 8601) date.  Returns "" if `s` is not a valid date.
+func xmpDate(s string) string {
+	t, ok := parsePdfDate(s)
+	if !ok {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// xmlEscape escapes `s` for use as XML character data.
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// isXMLName returns true if `s` can be used as the local part of an XML element name.
+func isXMLName(s string) bool {
+	if s == "" || strings.HasPrefix(strings.ToLower(s), "xml") {
+		return false
+	}
+	for i, r := range s {
+		letter := r == '_' || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z')
+		if !letter && (i == 0 || !(r == '-' || r == '.' || (r >= '0' && r <= '9'))) {
+			return false
+		}
+	}
+	return true
+}
+
+// XMP namespaces of the properties that correspond to document information dictionary entries.
+const (
+	nsRDF  = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	nsDC   = "http://purl.org/dc/elements/1.1/"
+	nsXMP  = "http://ns.adobe.com/xap/1.0/"
+	nsPDF  = "http://ns.adobe.com/pdf/1.3/"
+	nsPDFX = "http://ns.adobe.com/pdfx/1.3/"
+)
+
+// xmpInfoProperties are the XMP properties, by namespace, that are generated from the standard information
+// dictionary entries.
+var xmpInfoProperties = map[string]map[string]bool{
+	nsDC:  {"title": true, "creator": true, "description": true},
+	nsPDF: {"Keywords": true, "Producer": true, "Trapped": true},
+	nsXMP: {"CreatorTool": true, "CreateDate": true, "ModifyDate": true, "MetadataDate": true},
+}
+
+// xmpProperties returns the XMP properties that correspond to document information dictionary entries `info`.
+// The standard entries are mapped to their Dublin Core, XMP and PDF schema properties as described in the XMP
+// specification, custom entries go to the pdfx namespace, as Acrobat does.
+func xmpProperties(info map[string]string) string {
+	var props bytes.Buffer
+
+	if v, ok := info["Title"]; ok {
+		fmt.Fprintf(&props, "   <dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n",
+			xmlEscape(v))
+	}
+	if v, ok := info["Author"]; ok {
+		fmt.Fprintf(&props, "   <dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n", xmlEscape(v))
+	}
+	if v, ok := info["Subject"]; ok {
+		fmt.Fprintf(&props,
+			"   <dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:description>\n",
+			xmlEscape(v))
+	}
+	if v, ok := info["Keywords"]; ok {
+		fmt.Fprintf(&props, "   <pdf:Keywords>%s</pdf:Keywords>\n", xmlEscape(v))
+	}
+	if v, ok := info["Producer"]; ok {
+		fmt.Fprintf(&props, "   <pdf:Producer>%s</pdf:Producer>\n", xmlEscape(v))
+	}
+	switch v := info["Trapped"]; v {
+	case "True", "False", "Unknown":
+		fmt.Fprintf(&props, "   <pdf:Trapped>%s</pdf:Trapped>\n", v)
+	}
+	if v, ok := info["Creator"]; ok {
+		fmt.Fprintf(&props, "   <xmp:CreatorTool>%s</xmp:CreatorTool>\n", xmlEscape(v))
+	}
+	if d := xmpDate(info["CreationDate"]); d != "" {
+		fmt.Fprintf(&props, "   <xmp:CreateDate>%s</xmp:CreateDate>\n", d)
+	}
+	if d := xmpDate(info["ModDate"]); d != "" {
+		fmt.Fprintf(&props, "   <xmp:ModifyDate>%s</xmp:ModifyDate>\n", d)
+		fmt.Fprintf(&props, "   <xmp:MetadataDate>%s</xmp:MetadataDate>\n", d)
+	}
+
+	custom := []string{}
+	for key := range info {
+		if !standardInfoKeys[key] {
+			custom = append(custom, key)
+		}
+	}
+	sort.Strings(custom)
+	for _, key := range custom {
+		if !isXMLName(key) {
+			continue
+		}
+		fmt.Fprintf(&props, "   <pdfx:%s>%s</pdfx:%s>\n", key, xmlEscape(info[key]), key)
+	}
+
+	return props.String()
+}
+
+// standardInfoKeys are the document information dictionary entries defined by the PDF specification.
+var standardInfoKeys = map[string]bool{"Title": true, "Author": true, "Subject": true, "Keywords": true,
+	"Producer": true, "Creator": true, "CreationDate": true, "ModDate": true, "Trapped": true}
+
+// xmpDescription returns an rdf:Description element about `about` holding `props`.
+func xmpDescription(about, props string) string {
+	return `  <rdf:Description rdf:about="` + xmlEscape(about) + `"
+    xmlns:dc="` + nsDC + `"
+    xmlns:xmp="` + nsXMP + `"
+    xmlns:pdf="` + nsPDF + `"
+    xmlns:pdfx="` + nsPDFX + `">
+` + props + `  </rdf:Description>
+`
+}
+
+// buildXMP returns a new XMP metadata packet that corresponds to document information dictionary entries `info`.
+func buildXMP(info map[string]string) string {
+	return `<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="` + nsRDF + `">
+` + xmpDescription("", xmpProperties(info)) + ` </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+}
+
+// updateXMP returns XMP metadata packet `existing` with the properties that correspond to document information
+// dictionary entries replaced by those of `info`.  Custom entries are only replaced if they are in `info` or
+// `previous`, the entries before the update.  Everything else, e.g. the PDF/A identification, is kept, with the
+// text passed through `text` if it is not nil.  A new packet is built if `existing` is empty or cannot be parsed.
+func updateXMP(existing string, info, previous map[string]string, text func(string) string) string {
+	if strings.TrimSpace(existing) == "" {
+		return buildXMP(info)
+	}
+	merged, err := mergeXMP(existing, info, previous, text)
+	if err != nil {
+		unicommon.Log.Info("Replacing invalid XMP metadata: %v", err)
+		return buildXMP(info)
+	}
+	return merged
+}
+
+// xmpEdit replaces bytes [start, end) of an XMP packet with `text`.
+type xmpEdit struct {
+	start, end int
+	text       string
+}
+
+// mergeXMP does the work of updateXMP.  The packet is edited in place: the replaced properties are cut out of it
+// and a new rdf:Description with the properties of `info` is added at the end of the rdf:RDF element.
+func mergeXMP(existing string, info, previous map[string]string, text func(string) string) (string, error) {
+	if text == nil {
+		text = func(s string) string { return s }
+	}
+	replaced := func(ns, local string) bool {
+		if ns == nsPDFX {
+			_, isNew := info[local]
+			_, isOld := previous[local]
+			return isNew || isOld
+		}
+		return xmpInfoProperties[ns][local]
+	}
+
+	type element struct {
+		name          xml.Name // The resolved name.
+		ns            map[string]string
+		isDescription bool // Is the element an rdf:Description, whose children are properties?
+	}
+	stack := []element{{ns: map[string]string{"xml": "http://www.w3.org/XML/1998/namespace"}}}
+
+	edits := []xmpEdit{}
+	skipDepth := 0 // Depth of the element being cut out, 0 if none.
+	about := ""
+	aboutFound := false
+	rdfEnd := -1
+
+	dec := xml.NewDecoder(strings.NewReader(existing))
+	offset := 0
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		start, end := offset, int(dec.InputOffset())
+		offset = end
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			parent := stack[len(stack)-1]
+			ns := parent.ns
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					ns = copyNamespaces(ns)
+					if attr.Name.Space == "" {
+						ns[""] = attr.Value
+					} else {
+						ns[attr.Name.Local] = attr.Value
+					}
+				}
+			}
+			name := xml.Name{Space: ns[t.Name.Space], Local: t.Name.Local}
+			isDescription := name.Space == nsRDF && name.Local == "Description"
+			stack = append(stack, element{name: name, ns: ns, isDescription: isDescription})
+
+			if skipDepth > 0 {
+				continue
+			}
+			if parent.isDescription && replaced(name.Space, name.Local) {
+				skipDepth = len(stack)
+				edits = append(edits, xmpEdit{start: start, end: start})
+				continue
+			}
+
+			// Properties can also be attributes of rdf:Description.
+			changed := false
+			attrs := []xml.Attr{}
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+					attrs = append(attrs, attr)
+					continue
+				}
+				attrNS := ns[attr.Name.Space]
+				if isDescription && attrNS == nsRDF && attr.Name.Local == "about" && !aboutFound {
+					about, aboutFound = attr.Value, true
+				}
+				if isDescription && replaced(attrNS, attr.Name.Local) {
+					changed = true
+					continue
+				}
+				if v := text(attr.Value); v != attr.Value {
+					attr.Value = v
+					changed = true
+				}
+				attrs = append(attrs, attr)
+			}
+			if changed {
+				selfClosing := strings.HasSuffix(existing[start:end], "/>")
+				edits = append(edits, xmpEdit{start: start, end: end, text: startTag(t.Name, attrs, selfClosing)})
+			}
+
+		case xml.EndElement:
+			if len(stack) < 2 {
+				return "", fmt.Errorf("Unexpected end element %s", t.Name.Local)
+			}
+			name := stack[len(stack)-1].name
+			if skipDepth == len(stack) {
+				edits[len(edits)-1].end = end
+				skipDepth = 0
+			} else if skipDepth == 0 && name.Space == nsRDF && name.Local == "RDF" && rdfEnd < 0 {
+				rdfEnd = start
+			}
+			stack = stack[:len(stack)-1]
+
+		case xml.CharData:
+			if skipDepth > 0 {
+				continue
+			}
+			if v := text(string(t)); v != string(t) {
+				edits = append(edits, xmpEdit{start: start, end: end, text: xmlEscape(v)})
+			}
+		}
+	}
+	if rdfEnd < 0 {
+		return "", errors.New("No rdf:RDF element")
+	}
+	if props := xmpProperties(info); props != "" {
+		edits = append(edits, xmpEdit{start: rdfEnd, end: rdfEnd, text: xmpDescription(about, props) + " "})
+	}
+
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	var out strings.Builder
+	pos := 0
+	for _, e := range edits {
+		out.WriteString(existing[pos:e.start])
+		out.WriteString(e.text)
+		pos = e.end
+	}
+	out.WriteString(existing[pos:])
+	return out.String(), nil
+}
+
+// copyNamespaces returns a copy of prefix to namespace map `ns`.
+func copyNamespaces(ns map[string]string) map[string]string {
+	c := make(map[string]string, len(ns)+1)
+	for prefix, uri := range ns {
+		c[prefix] = uri
+	}
+	return c
+}
+
+// startTag returns the start tag of an element with raw (prefixed) name `name` and attributes `attrs`.
+func startTag(name xml.Name, attrs []xml.Attr, selfClosing bool) string {
+	qname := func(n xml.Name) string {
+		if n.Space == "" {
+			return n.Local
+		}
+		return n.Space + ":" + n.Local
+	}
+
+	var tag strings.Builder
+	tag.WriteString("<" + qname(name))
+	for _, attr := range attrs {
+		fmt.Fprintf(&tag, " %s=\"%s\"", qname(attr.Name), xmlEscape(attr.Value))
+	}
+	if selfClosing {
+		tag.WriteString("/>")
+	} else {
+		tag.WriteString(">")
+	}
+	return tag.String()
+}